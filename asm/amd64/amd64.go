@@ -2,6 +2,7 @@ package amd64
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -14,9 +15,36 @@ var callConvArgs []Register = []Register{
 	R9,
 }
 
+// Floats are passed in their own registers, next to the integer registers
+var callConvFloatArgs []Register = []Register{
+	XMM0,
+	XMM1,
+	XMM2,
+	XMM3,
+	XMM4,
+	XMM5,
+	XMM6,
+	XMM7,
+}
+
 type Program struct {
 	Functions    []Function
 	MainFunction *Function
+	// Set if any function checks bounds, the error routine is only emitted in that case
+	HasBoundsChecks bool
+	// Set if any function panics, the panic routine is only emitted in that case
+	HasPanics bool
+	Data      []Data
+	// The global variables, they are stored in a writable section after the read-only data
+	Globals []Data
+	// The functions, which are defined in other object files
+	Externs []string
+}
+
+// Read-only data, which is emitted after the code
+type Data struct {
+	Name  string
+	Value []byte
 }
 
 func (p *Program) executableAsmHeader() string {
@@ -27,37 +55,123 @@ func (p *Program) executableAsmHeader() string {
 	return executableAsmHeaderNoReturnValue
 }
 
+// The program is emitted as an object file, so that it can be linked with other object files
+const objectAsmHeader = "format ELF64\n"
+
 // This calls the main function and uses it's return value to exit
-const executableAsmHeader = "format ELF64 executable\n" +
-	"segment readable executable\n" +
-	"entry _start\n" +
+const executableAsmHeader = "section \".text\" executable\n" +
+	"public _start\n" +
 	"_start:\n" +
 	"  call main\n" +
 	"  mov rdi, rax\n" +
 	"  mov rax, 60\n" +
 	"  syscall\n"
 
-const executableAsmHeaderNoReturnValue = "format ELF64 executable\n" +
-	"segment readable executable\n" +
-	"entry _start\n" +
+const executableAsmHeaderNoReturnValue = "section \".text\" executable\n" +
+	"public _start\n" +
 	"_start:\n" +
 	"  call main\n" +
 	"  mov rdi, 0\n" +
 	"  mov rax, 60\n" +
 	"  syscall\n"
 
+// Failed bounds checks jump here, it exits with the bounds error code
+const boundsErrorLabel = "tt.bounds_error"
+const boundsErrorRoutine = boundsErrorLabel + ":\n" +
+	"  mov rdi, 101\n" +
+	"  mov rax, 60\n" +
+	"  syscall\n"
+
+// Panics call this routine with the location in rdi and rsi and the message in rdx and rcx.
+// It writes them and a newline to the standard error and exits with the panic error code.
+const panicLabel = "tt.panic"
+const panicRoutine = panicLabel + ":\n" +
+	"  mov r12, rdx\n" +
+	"  mov r13, rcx\n" +
+	"  mov rdx, rsi\n" +
+	"  mov rsi, rdi\n" +
+	"  mov rdi, 2\n" +
+	"  mov rax, 1\n" +
+	"  syscall\n" +
+	"  mov rsi, r12\n" +
+	"  mov rdx, r13\n" +
+	"  mov rdi, 2\n" +
+	"  mov rax, 1\n" +
+	"  syscall\n" +
+	"  push 10\n" +
+	"  mov rsi, rsp\n" +
+	"  mov rdx, 1\n" +
+	"  mov rdi, 2\n" +
+	"  mov rax, 1\n" +
+	"  syscall\n" +
+	"  mov rdi, 102\n" +
+	"  mov rax, 60\n" +
+	"  syscall\n"
+
 func (p *Program) Emit() string {
 	var builder strings.Builder
+	builder.WriteString(objectAsmHeader)
+	for _, name := range p.Externs {
+		builder.WriteString(fmt.Sprintf("extrn %s\n", name))
+	}
 	builder.WriteString(p.executableAsmHeader())
+	if p.HasBoundsChecks {
+		builder.WriteString(boundsErrorRoutine)
+	}
+	if p.HasPanics {
+		builder.WriteString(panicRoutine)
+	}
 
 	for _, function := range p.Functions {
 		builder.WriteString(function.Emit())
 		builder.WriteString("\n")
 	}
 
+	if len(p.Data) > 0 {
+		builder.WriteString("section \".rodata\"\n")
+	}
+	for _, d := range p.Data {
+		bytes := []string{}
+		for _, b := range d.Value {
+			bytes = append(bytes, fmt.Sprintf("%d", b))
+		}
+		builder.WriteString(fmt.Sprintf("%s: db %s\n", d.Name, strings.Join(bytes, ", ")))
+	}
+
+	// Globals, which are only zeros, are only reserved in the bss section, so they do not take up space in the executable
+	if slices.ContainsFunc(p.Globals, func(g Data) bool { return !isZero(g.Value) }) {
+		builder.WriteString("section \".data\" writeable\n")
+	}
+	for _, g := range p.Globals {
+		if !isZero(g.Value) {
+			bytes := []string{}
+			for _, b := range g.Value {
+				bytes = append(bytes, fmt.Sprintf("%d", b))
+			}
+			builder.WriteString(fmt.Sprintf("align 8\n%s: db %s\n", g.Name, strings.Join(bytes, ", ")))
+		}
+	}
+	if slices.ContainsFunc(p.Globals, func(g Data) bool { return isZero(g.Value) }) {
+		builder.WriteString("section \".bss\" writeable\n")
+	}
+	for _, g := range p.Globals {
+		if isZero(g.Value) {
+			builder.WriteString(fmt.Sprintf("align 8\n%s: rb %d\n", g.Name, len(g.Value)))
+		}
+	}
+
 	return builder.String()
 }
 
+func isZero(value []byte) bool {
+	for _, b := range value {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 type Function struct {
 	StackOffset    int64
 	Name           string
@@ -86,6 +200,14 @@ const (
 	GreaterEqual CondCode = "ge"
 	Less         CondCode = "l"
 	LessEqual    CondCode = "le"
+	// Unsigned
+	Above      CondCode = "a"
+	AboveEqual CondCode = "ae"
+	Below      CondCode = "b"
+	BelowEqual CondCode = "be"
+	// Set by ucomisd if one of the floats is NaN
+	Parity    CondCode = "p"
+	NotParity CondCode = "np"
 )
 
 type Opcode string
@@ -98,16 +220,42 @@ const (
 	Sub  Opcode = "sub"
 	Imul Opcode = "imul"
 	Cmp  Opcode = "cmp"
+	And  Opcode = "and"
+	Or   Opcode = "or"
+	Xor  Opcode = "xor"
+	// The shifts take the count in cl
+	Shl Opcode = "shl"
+	Sar Opcode = "sar"
+	Shr Opcode = "shr"
+	// Floats, the lhs is an xmm register, except for movsd, which can also store one in memory
+	Movsd   Opcode = "movsd"
+	Movq    Opcode = "movq" // Moves the bits of a general purpose register into an xmm register
+	Addsd   Opcode = "addsd"
+	Subsd   Opcode = "subsd"
+	Mulsd   Opcode = "mulsd"
+	Divsd   Opcode = "divsd"
+	Ucomisd Opcode = "ucomisd"
+	// Converts a 64 bit integer into a float and back, truncating towards zero
+	Cvtsi2sd  Opcode = "cvtsi2sd"
+	Cvttsd2si Opcode = "cvttsd2si"
 
 	// One operand
 	Idiv Opcode = "idiv"
+	Div  Opcode = "div"
 	Push Opcode = "push"
+	Neg  Opcode = "neg"
+	Not  Opcode = "not"
 
 	// No operands
-	Ret Opcode = "ret"
-	Cdq Opcode = "cdq"
+	Ret     Opcode = "ret"
+	Cqo     Opcode = "cqo"
+	Syscall Opcode = "syscall"
 )
 
+func (o Opcode) isShift() bool {
+	return o == Shl || o == Sar || o == Shr
+}
+
 type Instruction interface {
 	InstructionString() string
 }
@@ -124,6 +272,15 @@ type SimpleInstruction struct {
 	Lhs Operand
 	// Src
 	Rhs Operand
+	// The size of the operands, Eight if not set
+	Size OperandSize
+}
+
+func (i *SimpleInstruction) size() OperandSize {
+	if i.Size == 0 {
+		return Eight
+	}
+	return i.Size
 }
 
 func (i *SimpleInstruction) InstructionString() string {
@@ -138,11 +295,47 @@ func (i *SimpleInstruction) InstructionString() string {
 
 	// One operand
 	if i.Rhs == nil {
-		return fmt.Sprintf("%s %s", i.Opcode, i.Lhs.OperandString(Eight))
+		return fmt.Sprintf("%s %s", i.Opcode, i.Lhs.OperandString(i.size()))
 	}
 
 	// Two operands
-	return fmt.Sprintf("%s %s, %s", i.Opcode, i.Lhs.OperandString(Eight), i.Rhs.OperandString(Eight))
+	if i.Opcode.isShift() {
+		return fmt.Sprintf("%s %s, %s", i.Opcode, i.Lhs.OperandString(i.size()), i.Rhs.OperandString(One))
+	}
+	return fmt.Sprintf("%s %s, %s", i.Opcode, i.Lhs.OperandString(i.size()), i.Rhs.OperandString(i.size()))
+}
+
+// Loads Src with the size SrcSize into the whole 64 bit register Dst,
+// the value is sign extended if Signed is set, otherwise it is zero extended
+type ExtendInstruction struct {
+	Signed  bool
+	Dst     Register
+	Src     Operand
+	SrcSize OperandSize
+}
+
+func (i *ExtendInstruction) InstructionString() string {
+	switch {
+	case i.SrcSize == Four && i.Signed:
+		return fmt.Sprintf("movsxd %s, %s", i.Dst.OperandString(Eight), i.Src.OperandString(Four))
+	case i.SrcSize == Four:
+		// Writing to the 32 bit register clears the upper half
+		return fmt.Sprintf("mov %s, %s", i.Dst.OperandString(Four), i.Src.OperandString(Four))
+	case i.Signed:
+		return fmt.Sprintf("movsx %s, %s", i.Dst.OperandString(Eight), i.Src.OperandString(i.SrcSize))
+	default:
+		return fmt.Sprintf("movzx %s, %s", i.Dst.OperandString(Eight), i.Src.OperandString(i.SrcSize))
+	}
+}
+
+// Loads the address of Src into Dst
+type LeaInstruction struct {
+	Dst Register
+	Src MemoryOperand
+}
+
+func (i *LeaInstruction) InstructionString() string {
+	return fmt.Sprintf("lea %s, %s", i.Dst.OperandString(Eight), i.Src.address())
 }
 
 type Label string
@@ -172,6 +365,15 @@ func (c Call) InstructionString() string {
 	return fmt.Sprintf("call %s", c)
 }
 
+// Calls the function at the address in the register or memory Target
+type CallIndirect struct {
+	Target Operand
+}
+
+func (c *CallIndirect) InstructionString() string {
+	return fmt.Sprintf("call %s", c.Target.OperandString(Eight))
+}
+
 type SetCCInstruction struct {
 	Cond CondCode
 	Dst  Operand
@@ -211,12 +413,30 @@ const (
 	R9
 	R10
 	R11
+	XMM0
+	XMM1
+	XMM2
+	XMM3
+	XMM4
+	XMM5
+	XMM6
+	XMM7
+	// Only used as scratch registers for float operations
+	XMM14
+	XMM15
 )
 
+// xmm registers hold floats, they have no smaller parts
+func (r Register) isXMM() bool {
+	return r >= XMM0
+}
+
+// The size in bytes
 const (
-	One OperandSize = iota
-	Four
-	Eight
+	One   OperandSize = 1
+	Two   OperandSize = 2
+	Four  OperandSize = 4
+	Eight OperandSize = 8
 )
 
 func (r Register) OperandString(size OperandSize) string {
@@ -225,6 +445,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "al"
+		case Two:
+			return "ax"
 		case Four:
 			return "eax"
 		}
@@ -233,6 +455,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "cl"
+		case Two:
+			return "cx"
 		case Four:
 			return "ecx"
 		}
@@ -241,6 +465,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "dl"
+		case Two:
+			return "dx"
 		case Four:
 			return "edx"
 		}
@@ -249,6 +475,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "dil"
+		case Two:
+			return "di"
 		case Four:
 			return "edi"
 		}
@@ -257,6 +485,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "sil"
+		case Two:
+			return "si"
 		case Four:
 			return "esi"
 		}
@@ -265,6 +495,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "r8b"
+		case Two:
+			return "r8w"
 		case Four:
 			return "r8d"
 		}
@@ -273,6 +505,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "r9b"
+		case Two:
+			return "r9w"
 		case Four:
 			return "r9d"
 		}
@@ -281,6 +515,8 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "r10b"
+		case Two:
+			return "r10w"
 		case Four:
 			return "r10d"
 		}
@@ -289,10 +525,18 @@ func (r Register) OperandString(size OperandSize) string {
 		switch size {
 		case One:
 			return "r11b"
+		case Two:
+			return "r11w"
 		case Four:
 			return "r11d"
 		}
 		return "r11"
+	case XMM0, XMM1, XMM2, XMM3, XMM4, XMM5, XMM6, XMM7:
+		return fmt.Sprintf("xmm%d", r-XMM0)
+	case XMM14:
+		return "xmm14"
+	case XMM15:
+		return "xmm15"
 	default:
 		panic(fmt.Sprintf("unexpected amd64.Register: %#v", r))
 	}
@@ -304,21 +548,70 @@ func (i Imm) OperandString(size OperandSize) string {
 	return fmt.Sprintf("%d", i)
 }
 
-type Stack int64
-
-func (s Stack) OperandString(size OperandSize) string {
+// An operand that is located in memory
+type MemoryOperand interface {
+	Operand
+	// The operand that is offset bytes after this one
+	at(offset int64) MemoryOperand
+	address() string
+}
 
-	var sizeString string
+func sizeString(size OperandSize) string {
 	switch size {
 	case One:
-		sizeString = "byte"
+		return "byte"
+	case Two:
+		return "word"
 	case Four:
-		sizeString = "dword"
+		return "dword"
 	case Eight:
-		sizeString = "qword"
+		return "qword"
 	}
+	return ""
+}
+
+type Stack int64
+
+func (s Stack) OperandString(size OperandSize) string {
+	return fmt.Sprintf("%s %s", sizeString(size), s.address())
+}
+func (s Stack) at(offset int64) MemoryOperand {
+	return s + Stack(offset)
+}
+func (s Stack) address() string {
+	return fmt.Sprintf("[rbp %+d]", s)
+}
+
+// The memory at the address in Base plus Offset
+type Indirect struct {
+	Base   Register
+	Offset int64
+}
+
+func (i Indirect) OperandString(size OperandSize) string {
+	return fmt.Sprintf("%s %s", sizeString(size), i.address())
+}
+func (i Indirect) at(offset int64) MemoryOperand {
+	return Indirect{Base: i.Base, Offset: i.Offset + offset}
+}
+func (i Indirect) address() string {
+	return fmt.Sprintf("[%s %+d]", i.Base.OperandString(Eight), i.Offset)
+}
+
+// The memory at the label Name plus Offset
+type Global struct {
+	Name   string
+	Offset int64
+}
 
-	return fmt.Sprintf("%s [rbp %+d]", sizeString, s)
+func (g Global) OperandString(size OperandSize) string {
+	return fmt.Sprintf("%s %s", sizeString(size), g.address())
+}
+func (g Global) at(offset int64) MemoryOperand {
+	return Global{Name: g.Name, Offset: g.Offset + offset}
+}
+func (g Global) address() string {
+	return fmt.Sprintf("[%s %+d]", g.Name, g.Offset)
 }
 
 type Pseudo string
@@ -326,3 +619,20 @@ type Pseudo string
 func (s Pseudo) OperandString(size OperandSize) string {
 	panic("Pseudo Operands cannot be represented in asm")
 }
+
+// The part of an aggregate pseudo, which is Size bytes large, starting at Offset
+type PseudoMem struct {
+	Name   string
+	Size   int64
+	Offset int64
+}
+
+func (p PseudoMem) OperandString(size OperandSize) string {
+	panic("Pseudo Operands cannot be represented in asm")
+}
+func (p PseudoMem) at(offset int64) MemoryOperand {
+	return PseudoMem{Name: p.Name, Size: p.Size, Offset: p.Offset + offset}
+}
+func (p PseudoMem) address() string {
+	panic("Pseudo Operands cannot be represented in asm")
+}
@@ -6,7 +6,11 @@ import (
 	"testing"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
+	"robaertschi.xyz/robaertschi/tt/lexer"
+	"robaertschi.xyz/robaertschi/tt/parser"
 	"robaertschi.xyz/robaertschi/tt/ttir"
+	"robaertschi.xyz/robaertschi/tt/typechecker"
+	"robaertschi.xyz/robaertschi/tt/types"
 )
 
 func expectProgram(t *testing.T, expected Program, actual Program) {
@@ -265,3 +269,528 @@ func TestEqualityOperators(t *testing.T) {
 		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", expectedTrimmed, actualTrimmed)
 	}
 }
+
+//go:embed unary_test.txt
+var unaryTest string
+
+func TestUnary(t *testing.T) {
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Unary{
+						Src:      &ttir.Constant{Value: 3},
+						Operator: ast.Negate,
+						Dst:      &ttir.Var{Value: "temp.1"},
+					},
+					&ttir.Unary{
+						Src:      &ttir.Var{Value: "temp.1"},
+						Operator: ast.Complement,
+						Dst:      &ttir.Var{Value: "temp.2"},
+					},
+					&ttir.Unary{
+						Src:      &ttir.Constant{Value: 0},
+						Operator: ast.Not,
+						Dst:      &ttir.Var{Value: "temp.3"},
+					},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.2"}},
+				},
+				HasReturnValue: true,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(unaryTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(unaryTest), trim(actual))
+	}
+}
+
+//go:embed division_test.txt
+var divisionTest string
+
+func TestDivisionAndShifts(t *testing.T) {
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Binary{
+						Lhs:      &ttir.Constant{Value: 17},
+						Rhs:      &ttir.Constant{Value: 5},
+						Operator: ast.Divide,
+						Dst:      &ttir.Var{Value: "temp.1"},
+					},
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.1"},
+						Rhs:      &ttir.Var{Value: "temp.1"},
+						Operator: ast.Modulo,
+						Dst:      &ttir.Var{Value: "temp.2"},
+					},
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.2"},
+						Rhs:      &ttir.Constant{Value: 3},
+						Operator: ast.ShiftRight,
+						Dst:      &ttir.Var{Value: "temp.3"},
+					},
+					// Immediates, which do not fit into 32 bits, have to be loaded into a register first
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.3"},
+						Rhs:      &ttir.Constant{Value: 5000000000},
+						Operator: ast.Add,
+						Dst:      &ttir.Var{Value: "temp.4"},
+					},
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.4"},
+						Rhs:      &ttir.Constant{Value: 5000000000},
+						Operator: ast.Multiply,
+						Dst:      &ttir.Var{Value: "temp.5"},
+					},
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.5"},
+						Rhs:      &ttir.Constant{Value: 0xff00000000},
+						Operator: ast.BitwiseAnd,
+						Dst:      &ttir.Var{Value: "temp.6"},
+					},
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.6"},
+						Rhs:      &ttir.Constant{Value: 5000000000},
+						Operator: ast.LessThan,
+						Dst:      &ttir.Var{Value: "temp.7", Type: types.Bool},
+					},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.6"}},
+				},
+				HasReturnValue: true,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(divisionTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(divisionTest), trim(actual))
+	}
+}
+
+//go:embed sized_test.txt
+var sizedTest string
+
+func TestSizedIntegers(t *testing.T) {
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Convert{
+						Src: &ttir.Constant{Value: 300},
+						Dst: &ttir.Var{Value: "temp.1", Type: types.U8},
+					},
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.1", Type: types.U8},
+						Rhs:      &ttir.Constant{Value: 3, Type: types.U8},
+						Operator: ast.Divide,
+						Dst:      &ttir.Var{Value: "temp.2", Type: types.U8},
+					},
+					&ttir.Binary{
+						Lhs:      &ttir.Var{Value: "temp.2", Type: types.U8},
+						Rhs:      &ttir.Var{Value: "temp.1", Type: types.U8},
+						Operator: ast.LessThan,
+						Dst:      &ttir.Var{Value: "temp.3", Type: types.Bool},
+					},
+					&ttir.Convert{
+						Src: &ttir.Var{Value: "temp.2", Type: types.U8},
+						Dst: &ttir.Var{Value: "temp.4", Type: types.I32},
+					},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.4", Type: types.I32}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I32,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(sizedTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(sizedTest), trim(actual))
+	}
+}
+
+//go:embed struct_test.txt
+var structTest string
+
+func TestStructs(t *testing.T) {
+	pair := types.NewStruct("Pair", []types.StructField{{Name: "x", Type: types.I64}, {Name: "y", Type: types.I64}})
+	p := &ttir.Var{Value: "p.0", Type: pair}
+
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name:      "swap",
+				Arguments: []*ttir.Var{p},
+				Instructions: []ttir.Instruction{
+					&ttir.Copy{
+						Src: &ttir.Memory{Base: p, Offset: 8, Type: types.I64},
+						Dst: &ttir.Memory{Base: &ttir.Var{Value: "temp.1", Type: pair}, Offset: 0, Type: types.I64},
+					},
+					&ttir.Copy{
+						Src: &ttir.Memory{Base: p, Offset: 0, Type: types.I64},
+						Dst: &ttir.Memory{Base: &ttir.Var{Value: "temp.1", Type: pair}, Offset: 8, Type: types.I64},
+					},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.1", Type: pair}},
+				},
+				HasReturnValue: true,
+				ReturnType:     pair,
+			},
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Copy{
+						Src: &ttir.Constant{Value: 1},
+						Dst: &ttir.Memory{Base: &ttir.Var{Value: "temp.2", Type: pair}, Offset: 0, Type: types.I64},
+					},
+					&ttir.Copy{
+						Src: &ttir.Constant{Value: 2},
+						Dst: &ttir.Memory{Base: &ttir.Var{Value: "temp.2", Type: pair}, Offset: 8, Type: types.I64},
+					},
+					&ttir.Call{
+						FunctionName: "swap",
+						Arguments:    []ttir.Operand{&ttir.Var{Value: "temp.2", Type: pair}},
+						ReturnValue:  &ttir.Var{Value: "temp.3", Type: pair},
+					},
+					&ttir.Copy{
+						Src: &ttir.Memory{Base: &ttir.Var{Value: "temp.3", Type: pair}, Offset: 0, Type: types.I64},
+						Dst: &ttir.Var{Value: "temp.4", Type: types.I64},
+					},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.4", Type: types.I64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(structTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(structTest), trim(actual))
+	}
+}
+
+//go:embed array_test.txt
+var arrayTest string
+
+func TestArrays(t *testing.T) {
+	slice := types.NewSlice(types.I32)
+	s := &ttir.Var{Value: "s.0", Type: slice}
+	i := &ttir.Var{Value: "i.1", Type: types.I64}
+
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name:      "main",
+				Arguments: []*ttir.Var{s, i},
+				Instructions: []ttir.Instruction{
+					&ttir.Copy{
+						Src: &ttir.Memory{Base: s, Offset: types.SlicePointerOffset, Type: types.I64},
+						Dst: &ttir.Var{Value: "temp.1", Type: types.I64},
+					},
+					&ttir.Copy{
+						Src: &ttir.Memory{Base: s, Offset: types.SliceLengthOffset, Type: types.I64},
+						Dst: &ttir.Var{Value: "temp.2", Type: types.I64},
+					},
+					&ttir.BoundsCheck{Index: i, Length: &ttir.Var{Value: "temp.2", Type: types.I64}},
+					&ttir.Binary{Operator: ast.Multiply, Lhs: i, Rhs: &ttir.Constant{Value: 4}, Dst: &ttir.Var{Value: "temp.3", Type: types.I64}},
+					&ttir.Binary{Operator: ast.Add, Lhs: &ttir.Var{Value: "temp.1", Type: types.I64}, Rhs: &ttir.Var{Value: "temp.3", Type: types.I64}, Dst: &ttir.Var{Value: "temp.4", Type: types.I64}},
+					&ttir.Load{Address: &ttir.Var{Value: "temp.4", Type: types.I64}, Dst: &ttir.Var{Value: "temp.5", Type: types.I32}},
+					&ttir.Store{Src: &ttir.Constant{Value: 7, Type: types.I32}, Address: &ttir.Var{Value: "temp.4", Type: types.I64}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.5", Type: types.I32}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I32,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(arrayTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(arrayTest), trim(actual))
+	}
+}
+
+//go:embed string_test.txt
+var stringTest string
+
+func TestStrings(t *testing.T) {
+	s := &ttir.Var{Value: "temp.1", Type: types.Str}
+
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Copy{Src: &ttir.DataAddress{Name: "string.1"}, Dst: &ttir.Memory{Base: s, Offset: types.SlicePointerOffset, Type: types.I64}},
+					&ttir.Copy{Src: &ttir.Constant{Value: 3}, Dst: &ttir.Memory{Base: s, Offset: types.SliceLengthOffset, Type: types.I64}},
+					&ttir.Copy{Src: &ttir.Memory{Base: s, Offset: types.SlicePointerOffset, Type: types.I64}, Dst: &ttir.Var{Value: "temp.2", Type: types.I64}},
+					&ttir.Syscall{Number: 1, Arguments: []ttir.Operand{&ttir.Constant{Value: 1}, &ttir.Var{Value: "temp.2", Type: types.I64}, &ttir.Constant{Value: 3}}},
+					&ttir.Ret{},
+				},
+				ReturnType: types.Unit,
+			},
+		},
+		Data: []*ttir.Data{{Name: "string.1", Value: []byte("hi\n\x00")}},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(stringTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(stringTest), trim(actual))
+	}
+}
+
+//go:embed pointer_test.txt
+var pointerTest string
+
+func TestPointers(t *testing.T) {
+	x := &ttir.Var{Value: "x.0", Type: types.I64}
+	p := &ttir.Var{Value: "p.1", Type: types.NewPointer(types.I64)}
+
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Copy{Src: &ttir.Constant{Value: 1}, Dst: &ttir.Memory{Base: x, Offset: 0, Type: types.I64}},
+					&ttir.AddressOf{Src: &ttir.Memory{Base: x, Offset: 0, Type: types.I64}, Dst: p},
+					&ttir.Load{Address: p, Dst: &ttir.Var{Value: "temp.1", Type: types.I64}},
+					&ttir.Binary{Operator: ast.Add, Lhs: &ttir.Var{Value: "temp.1", Type: types.I64}, Rhs: &ttir.Constant{Value: 1}, Dst: &ttir.Var{Value: "temp.2", Type: types.I64}},
+					&ttir.Store{Src: &ttir.Var{Value: "temp.2", Type: types.I64}, Address: p},
+					&ttir.Copy{Src: &ttir.Memory{Base: x, Offset: 0, Type: types.I64}, Dst: &ttir.Var{Value: "temp.3", Type: types.I64}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.3", Type: types.I64}},
+				},
+				ReturnType: types.I64,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(pointerTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(pointerTest), trim(actual))
+	}
+}
+
+//go:embed return_test.txt
+var returnTest string
+
+func TestEarlyReturn(t *testing.T) {
+	x := &ttir.Var{Value: "x.0", Type: types.I64}
+	cond := &ttir.Var{Value: "temp.1", Type: types.Bool}
+
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name:      "main",
+				Arguments: []*ttir.Var{x},
+				Instructions: []ttir.Instruction{
+					&ttir.Binary{Operator: ast.LessThan, Lhs: x, Rhs: &ttir.Constant{Value: 0}, Dst: cond},
+					&ttir.JumpIfZero{Value: cond, Label: "lbl.1"},
+					&ttir.Ret{Op: &ttir.Constant{Value: 0}},
+					ttir.Label("lbl.1"),
+					&ttir.Ret{Op: x},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(returnTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(returnTest), trim(actual))
+	}
+}
+
+//go:embed global_test.txt
+var globalTest string
+
+func TestGlobals(t *testing.T) {
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Copy{Src: &ttir.Global{Name: "count", Offset: 0, Type: types.I64}, Dst: &ttir.Var{Value: "temp.1", Type: types.I64}},
+					&ttir.Binary{Operator: ast.Add, Lhs: &ttir.Var{Value: "temp.1", Type: types.I64}, Rhs: &ttir.Constant{Value: 1}, Dst: &ttir.Var{Value: "temp.2", Type: types.I64}},
+					&ttir.Copy{Src: &ttir.Var{Value: "temp.2", Type: types.I64}, Dst: &ttir.Global{Name: "count", Offset: 0, Type: types.I64}},
+					&ttir.Copy{Src: &ttir.Global{Name: "flags", Offset: 1, Type: types.Bool}, Dst: &ttir.Global{Name: "flags", Offset: 0, Type: types.Bool}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.2", Type: types.I64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+		},
+		Globals: []*ttir.GlobalVariable{
+			{Name: "count", Type: types.I64, Value: []byte{2, 0, 0, 0, 0, 0, 0, 0}},
+			{Name: "flags", Type: types.NewArray(types.Bool, 2), Value: []byte{0, 0}},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(globalTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(globalTest), trim(actual))
+	}
+}
+
+//go:embed bss_test.txt
+var bssTest string
+
+func TestZeroGlobals(t *testing.T) {
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Ret{Op: &ttir.Global{Name: "counter", Offset: 0, Type: types.I64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+		},
+		Globals: []*ttir.GlobalVariable{
+			{Name: "counter", Type: types.I64, Value: []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+			{Name: "table", Type: types.NewArray(types.I64, 3), Value: make([]byte, 24)},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(bssTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(bssTest), trim(actual))
+	}
+}
+
+//go:embed extern_test.txt
+var externTest string
+
+func TestExterns(t *testing.T) {
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Call{FunctionName: "twice", Arguments: []ttir.Operand{&ttir.Constant{Value: 2}}, ReturnValue: &ttir.Var{Value: "temp.1", Type: types.I64}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.1", Type: types.I64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+		},
+		Externs: []string{"twice"},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(externTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(externTest), trim(actual))
+	}
+}
+
+//go:embed closure_test.txt
+var closureTest string
+
+func TestClosures(t *testing.T) {
+	env := &ttir.Var{Value: "temp.1", Type: types.I64}
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name:        "add",
+				Environment: env,
+				Arguments:   []*ttir.Var{{Value: "x.0", Type: types.I64}},
+				Instructions: []ttir.Instruction{
+					&ttir.Load{Address: env, Dst: &ttir.Var{Value: "temp.2", Type: types.I64}},
+					&ttir.Binary{Operator: ast.Add, Lhs: &ttir.Var{Value: "x.0", Type: types.I64}, Rhs: &ttir.Var{Value: "temp.2", Type: types.I64}, Dst: &ttir.Var{Value: "temp.3", Type: types.I64}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.3", Type: types.I64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Copy{Src: &ttir.FunctionAddress{Name: "add"}, Dst: &ttir.Var{Value: "temp.4", Type: types.I64}},
+					&ttir.CallIndirect{Code: &ttir.Var{Value: "temp.4", Type: types.I64}, Environment: &ttir.Constant{Value: 0, Type: types.I64}, Arguments: []ttir.Operand{&ttir.Constant{Value: 2, Type: types.I64}}, ReturnValue: &ttir.Var{Value: "temp.5", Type: types.I64}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.5", Type: types.I64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(closureTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(closureTest), trim(actual))
+	}
+}
+
+//go:embed float_test.txt
+var floatTest string
+
+func TestFloats(t *testing.T) {
+	x := &ttir.Var{Value: "x.0", Type: types.I64}
+	y := &ttir.Var{Value: "y.1", Type: types.F64}
+	program := &ttir.Program{
+		Functions: []*ttir.Function{
+			{
+				Name:      "scale",
+				Arguments: []*ttir.Var{x, y},
+				Instructions: []ttir.Instruction{
+					&ttir.Convert{Src: x, Dst: &ttir.Var{Value: "temp.1", Type: types.F64}},
+					&ttir.Binary{Operator: ast.Multiply, Lhs: &ttir.Var{Value: "temp.1", Type: types.F64}, Rhs: y, Dst: &ttir.Var{Value: "temp.2", Type: types.F64}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.2", Type: types.F64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.F64,
+			},
+			{
+				Name: "main",
+				Instructions: []ttir.Instruction{
+					&ttir.Call{FunctionName: "scale", Arguments: []ttir.Operand{&ttir.Constant{Value: 3, Type: types.I64}, ttir.FloatConstant(1.5)}, ReturnValue: &ttir.Var{Value: "temp.3", Type: types.F64}},
+					&ttir.Binary{Operator: ast.LessThan, Lhs: &ttir.Var{Value: "temp.3", Type: types.F64}, Rhs: ttir.FloatConstant(5), Dst: &ttir.Var{Value: "temp.4", Type: types.Bool}},
+					&ttir.Binary{Operator: ast.Equal, Lhs: &ttir.Var{Value: "temp.3", Type: types.F64}, Rhs: ttir.FloatConstant(4.5), Dst: &ttir.Var{Value: "temp.5", Type: types.Bool}},
+					&ttir.Convert{Src: &ttir.Var{Value: "temp.3", Type: types.F64}, Dst: &ttir.Var{Value: "temp.6", Type: types.I64}},
+					&ttir.Ret{Op: &ttir.Var{Value: "temp.6", Type: types.I64}},
+				},
+				HasReturnValue: true,
+				ReturnType:     types.I64,
+			},
+		},
+	}
+
+	actual := CgProgram(program).Emit()
+	if trim(actual) != trim(floatTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(floatTest), trim(actual))
+	}
+}
+
+//go:embed scope_test.txt
+var scopeTest string
+
+// Variables with the same name in sibling blocks can have different sizes, each needs its own stack slot
+func TestSiblingScopes(t *testing.T) {
+	input := "fn main(): i64 = { if true { a := 1; a } else { 0 }; if true { a := [1, 2, 3, 4, 5, 6]; a[5] } else { 0 } };"
+
+	l, err := lexer.New(input, "test.tt")
+	if err != nil {
+		t.Fatalf("lexer error: %q", err)
+	}
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if p.Errors() > 0 {
+		t.Fatalf("parser errors: %d", p.Errors())
+	}
+	tprogram, err := typechecker.New().CheckProgram(program)
+	if err != nil {
+		t.Fatalf("typechecker error: %q", err)
+	}
+
+	actual := CgProgram(ttir.EmitProgram(tprogram)).Emit()
+	if trim(actual) != trim(scopeTest) {
+		t.Errorf("Expected program to be:\n>>%s<<\nbut got:\n>>%s<<\n", trim(scopeTest), trim(actual))
+	}
+}
@@ -2,28 +2,247 @@ package amd64
 
 import (
 	"fmt"
+	"math"
 	"slices"
 	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
 	"robaertschi.xyz/robaertschi/tt/ttir"
+	"robaertschi.xyz/robaertschi/tt/types"
 )
 
 func comment(c string) Comment {
 	return Comment(strings.ReplaceAll(strings.TrimRight(c, "\n"), "\n", "\n  ; "))
 }
 
+// The registers for the arguments of a syscall, the fourth one differs from function calls
+var syscallArgs = []Register{DI, SI, DX, R10, R8, R9}
+
+// The pseudo which holds the address for struct return values, which are returned in memory
+const returnPointer Pseudo = "return.pointer"
+
 func toAsmOperand(op ttir.Operand) Operand {
 	switch op := op.(type) {
 	case *ttir.Constant:
 		return Imm(op.Value)
 	case *ttir.Var:
+		if types.IsAggregate(op.ValueType()) {
+			return PseudoMem{Name: op.Value, Size: op.ValueType().Size()}
+		}
 		return Pseudo(op.Value)
+	case *ttir.Memory:
+		return PseudoMem{Name: op.Base.Value, Size: op.Base.ValueType().Size(), Offset: op.Offset}
+	case *ttir.Global:
+		return Global{Name: op.Name, Offset: op.Offset}
 	default:
 		panic(fmt.Sprintf("unkown operand %T", op))
 	}
 }
 
+func sizeOf(op ttir.Operand) OperandSize {
+	return OperandSize(op.ValueType().Size())
+}
+
+// Loads the operand into the whole register, smaller values are sign or zero extended depending on the type
+func loadExtended(dst Register, op ttir.Operand) []Instruction {
+	if c, ok := op.(*ttir.Constant); ok {
+		return []Instruction{&SimpleInstruction{Opcode: Mov, Lhs: dst, Rhs: Imm(c.Value)}}
+	}
+
+	size := sizeOf(op)
+	if size == Eight {
+		return []Instruction{&SimpleInstruction{Opcode: Mov, Lhs: dst, Rhs: toAsmOperand(op)}}
+	}
+
+	return []Instruction{&ExtendInstruction{Signed: types.IsSigned(op.ValueType()), Dst: dst, Src: toAsmOperand(op), SrcSize: size}}
+}
+
+// Loads the operand into the register, floats are loaded into xmm registers
+func load(dst Register, op ttir.Operand) []Instruction {
+	if !dst.isXMM() {
+		return loadExtended(dst, op)
+	}
+
+	// There are no immediates for xmm registers, the bits of a constant go through r11
+	if c, ok := op.(*ttir.Constant); ok {
+		return []Instruction{
+			&SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: Imm(c.Value)},
+			&SimpleInstruction{Opcode: Movq, Lhs: dst, Rhs: R11},
+		}
+	}
+	return []Instruction{&SimpleInstruction{Opcode: Movsd, Lhs: dst, Rhs: toAsmOperand(op)}}
+}
+
+// Moves a value between a register and memory, xmm registers need movsd
+func move(dst Operand, src Operand, size OperandSize) Instruction {
+	dstRegister, dstOk := dst.(Register)
+	srcRegister, srcOk := src.(Register)
+	if (dstOk && dstRegister.isXMM()) || (srcOk && srcRegister.isXMM()) {
+		return &SimpleInstruction{Opcode: Movsd, Lhs: dst, Rhs: src}
+	}
+	return &SimpleInstruction{Opcode: Mov, Lhs: dst, Rhs: src, Size: size}
+}
+
+// Copies size bytes from src to dst through R10, using the largest possible moves
+func copyMemory(dst MemoryOperand, src MemoryOperand, size int64) []Instruction {
+	instructions := []Instruction{}
+
+	for offset := int64(0); offset < size; {
+		chunk := Eight
+		for int64(chunk) > size-offset {
+			chunk /= 2
+		}
+
+		instructions = append(instructions,
+			&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: src.at(offset), Size: chunk},
+			&SimpleInstruction{Opcode: Mov, Lhs: dst.at(offset), Rhs: R10, Size: chunk},
+		)
+		offset += int64(chunk)
+	}
+
+	return instructions
+}
+
+// Where a value is passed according to the System V ABI
+type location struct {
+	// A register for every eightbyte of the value, empty if the value is passed on the stack
+	registers []Register
+	// The offset from the start of the stack arguments
+	stackOffset int64
+}
+
+// Structs which are larger than two eightbytes are passed and returned in memory
+func inMemory(t types.Type) bool {
+	return t.Size() > 16
+}
+
+func eightbytes(t types.Type) int64 {
+	return (t.Size() + 7) / 8
+}
+
+// Returns for every eightbyte of t, if it only contains floats, those are passed in xmm registers
+func floatEightbytes(t types.Type) []bool {
+	floats := make([]bool, eightbytes(t))
+	for i := range floats {
+		floats[i] = true
+	}
+	markIntegers(t, 0, floats)
+	return floats
+}
+
+// Marks the eightbytes, which contain a part of a value that is not a float
+func markIntegers(t types.Type, offset int64, floats []bool) {
+	switch t := t.(type) {
+	case *types.StructType:
+		for _, field := range t.Fields {
+			markIntegers(field.Type, offset+field.Offset, floats)
+		}
+	case *types.ArrayType:
+		for i := range t.Length {
+			markIntegers(t.Element, offset+i*t.Element.Size(), floats)
+		}
+	case *types.EnumType:
+		// The payloads share the same memory, an eightbyte with an integer in any variant is an integer
+		markIntegers(types.I64, offset+types.EnumTagOffset, floats)
+		for _, variant := range t.Variants {
+			for i, field := range variant.Fields {
+				markIntegers(field, offset+variant.Offsets[i], floats)
+			}
+		}
+	default:
+		if types.IsFloat(t) {
+			return
+		}
+		for i := offset / 8; i < (offset+t.Size()+7)/8; i++ {
+			floats[i] = false
+		}
+	}
+}
+
+// Assigns a register to every eightbyte of t, the eightbytes with only floats take the float registers.
+// ok is false if there are not enough registers left.
+func assignRegisters(t types.Type, ints []Register, floats []Register) (registers []Register, remainingInts []Register, remainingFloats []Register, ok bool) {
+	registers = []Register{}
+	for _, float := range floatEightbytes(t) {
+		if float {
+			if len(floats) == 0 {
+				return nil, nil, nil, false
+			}
+			registers = append(registers, floats[0])
+			floats = floats[1:]
+		} else {
+			if len(ints) == 0 {
+				return nil, nil, nil, false
+			}
+			registers = append(registers, ints[0])
+			ints = ints[1:]
+		}
+	}
+	return registers, ints, floats, true
+}
+
+// The registers an aggregate, which is not returned in memory, is returned in
+func returnRegisters(t types.Type) []Register {
+	registers, _, _, _ := assignRegisters(t, []Register{AX, DX}, []Register{XMM0, XMM1})
+	return registers
+}
+
+// The register a scalar is returned in
+func returnRegister(op ttir.Operand) Register {
+	if ttir.ClassOf(op) == ttir.FloatClass {
+		return XMM0
+	}
+	return AX
+}
+
+// Returns the location of every argument and the size of the arguments on the stack.
+// If the return value is returned in memory, the first register holds the address for it.
+func classifyArguments(arguments []types.Type, returnType types.Type) ([]location, int64) {
+	available := callConvArgs
+	if inMemory(returnType) {
+		available = available[1:]
+	}
+	availableFloats := callConvFloatArgs
+
+	locations := []location{}
+	stackSize := int64(0)
+	for _, t := range arguments {
+		if !inMemory(t) {
+			if registers, ints, floats, ok := assignRegisters(t, available, availableFloats); ok {
+				locations = append(locations, location{registers: registers})
+				available, availableFloats = ints, floats
+				continue
+			}
+		}
+		locations = append(locations, location{stackOffset: stackSize})
+		stackSize += 8 * eightbytes(t)
+	}
+
+	return locations, stackSize
+}
+
+func operandTypes(operands []ttir.Operand) []types.Type {
+	t := []types.Type{}
+	for _, op := range operands {
+		t = append(t, op.ValueType())
+	}
+	return t
+}
+
+// Truncates the value to the size of t, the result is sign extended if t is signed
+func truncateConstant(value int64, t types.Type) int64 {
+	bits := t.Size() * 8
+	if bits >= 64 {
+		return value
+	}
+
+	value &= (1 << bits) - 1
+	if types.IsSigned(t) && value >= 1<<(bits-1) {
+		value -= 1 << bits
+	}
+	return value
+}
+
 func CgProgram(prog *ttir.Program) *Program {
 	funcs := make([]Function, 0)
 
@@ -37,6 +256,15 @@ func CgProgram(prog *ttir.Program) *Program {
 
 	newProgram = replacePseudo(newProgram)
 	newProgram = instructionFixup(newProgram)
+	newProgram.HasBoundsChecks = hasInstruction[*ttir.BoundsCheck](prog)
+	newProgram.HasPanics = hasInstruction[*ttir.Panic](prog)
+	for _, d := range prog.Data {
+		newProgram.Data = append(newProgram.Data, Data{Name: d.Name, Value: d.Value})
+	}
+	for _, g := range prog.Globals {
+		newProgram.Globals = append(newProgram.Globals, Data{Name: g.Name, Value: g.Value})
+	}
+	newProgram.Externs = prog.Externs
 
 	for i, f := range newProgram.Functions {
 		if f.Name == "main" {
@@ -47,24 +275,61 @@ func CgProgram(prog *ttir.Program) *Program {
 	return &newProgram
 }
 
+// Reports whether any function contains an instruction of type T
+func hasInstruction[T ttir.Instruction](prog *ttir.Program) bool {
+	for _, f := range prog.Functions {
+		for _, i := range f.Instructions {
+			if _, ok := i.(T); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func cgFunction(f *ttir.Function) Function {
 	newInstructions := []Instruction{comment(f.String())}
 
+	returnType := f.ReturnType
+	if returnType == nil {
+		returnType = types.Unit
+	}
+
+	// Closures get the address of their environment in rax
+	if f.Environment != nil {
+		newInstructions = append(newInstructions, &SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(f.Environment), Rhs: AX})
+	}
+
+	if inMemory(returnType) {
+		newInstructions = append(newInstructions, &SimpleInstruction{Opcode: Mov, Lhs: returnPointer, Rhs: callConvArgs[0]})
+	}
+
+	arguments := []ttir.Operand{}
+	for _, arg := range f.Arguments {
+		arguments = append(arguments, arg)
+	}
+	locations, _ := classifyArguments(operandTypes(arguments), returnType)
+
 	for i, arg := range f.Arguments {
-		if i < len(callConvArgs) {
+		loc := locations[i]
+		dst := toAsmOperand(arg)
+
+		if mem, ok := dst.(MemoryOperand); ok {
+			if len(loc.registers) == 0 {
+				newInstructions = append(newInstructions, copyMemory(mem, Stack(16+loc.stackOffset), arg.ValueType().Size())...)
+			}
+			for j, reg := range loc.registers {
+				newInstructions = append(newInstructions, move(mem.at(int64(8*j)), reg, Eight))
+			}
+		} else if len(loc.registers) == 0 {
 			newInstructions = append(newInstructions, &SimpleInstruction{
 				Opcode: Mov,
-				Lhs:    Pseudo(arg),
-				Rhs:    Register(callConvArgs[i]),
+				Lhs:    dst,
+				Rhs:    Stack(16 + loc.stackOffset),
+				Size:   sizeOf(arg),
 			})
 		} else {
-			newInstructions = append(newInstructions,
-				&SimpleInstruction{
-					Opcode: Mov,
-					Lhs:    Pseudo(arg),
-					Rhs:    Stack(16 + (8 * (i - len(callConvArgs)))),
-				},
-			)
+			newInstructions = append(newInstructions, move(dst, loc.registers[0], sizeOf(arg)))
 		}
 	}
 
@@ -83,24 +348,34 @@ func cgInstruction(i ttir.Instruction) []Instruction {
 	switch i := i.(type) {
 	case *ttir.Ret:
 		if i.Op != nil {
-			return []Instruction{
-				comment(i.String()),
-				&SimpleInstruction{
-					Opcode: Mov,
-					Lhs:    AX,
-					Rhs:    toAsmOperand(i.Op),
-				},
-				&SimpleInstruction{
-					Opcode: Ret,
-				},
+			instructions := []Instruction{comment(i.String())}
+			if src, ok := toAsmOperand(i.Op).(MemoryOperand); ok {
+				size := i.Op.ValueType().Size()
+				if inMemory(i.Op.ValueType()) {
+					// The caller expects the address of the struct in rax
+					instructions = append(instructions, &SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: returnPointer})
+					instructions = append(instructions, copyMemory(Indirect{Base: R11}, src, size)...)
+					instructions = append(instructions, &SimpleInstruction{Opcode: Mov, Lhs: AX, Rhs: R11})
+				} else {
+					for j, reg := range returnRegisters(i.Op.ValueType()) {
+						instructions = append(instructions, move(reg, src.at(int64(8*j)), Eight))
+					}
+				}
+			} else {
+				instructions = append(instructions, load(returnRegister(i.Op), i.Op)...)
 			}
+			return append(instructions, &SimpleInstruction{Opcode: Ret})
 		} else {
 			return []Instruction{&SimpleInstruction{Opcode: Ret},
 				comment(i.String()),
 			}
 		}
+	case *ttir.Unary:
+		return cgUnary(i)
 	case *ttir.Binary:
 		return cgBinary(i)
+	case *ttir.Convert:
+		return cgConvert(i)
 	case ttir.Label:
 		return []Instruction{comment(i.String()), Label(i)}
 	case *ttir.JumpIfZero:
@@ -110,6 +385,7 @@ func cgInstruction(i ttir.Instruction) []Instruction {
 				Opcode: Cmp,
 				Lhs:    toAsmOperand(i.Value),
 				Rhs:    Imm(0),
+				Size:   sizeOf(i.Value),
 			},
 			&JumpCCInstruction{
 				Cond: Equal,
@@ -123,6 +399,7 @@ func cgInstruction(i ttir.Instruction) []Instruction {
 				Opcode: Cmp,
 				Lhs:    toAsmOperand(i.Value),
 				Rhs:    Imm(0),
+				Size:   sizeOf(i.Value),
 			},
 			&JumpCCInstruction{
 				Cond: NotEqual,
@@ -132,78 +409,206 @@ func cgInstruction(i ttir.Instruction) []Instruction {
 	case ttir.Jump:
 		return []Instruction{comment(i.String()), JmpInstruction(i)}
 	case *ttir.Copy:
-		return []Instruction{comment(i.String()), &SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(i.Dst), Rhs: toAsmOperand(i.Src)}}
-	case *ttir.Call:
-		registerArgs := i.Arguments[:min(len(callConvArgs), len(i.Arguments))]
-		stackArgs := []ttir.Operand{}
-		if len(callConvArgs) < len(i.Arguments) {
-			stackArgs = i.Arguments[len(callConvArgs):len(i.Arguments)]
+		if src, ok := i.Src.(*ttir.DataAddress); ok {
+			return []Instruction{
+				comment(i.String()),
+				&LeaInstruction{Dst: R11, Src: Global{Name: src.Name}},
+				&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(i.Dst), Rhs: R11},
+			}
 		}
-
-		stackPadding := 0
-		if len(stackArgs)%2 != 0 {
-			stackPadding = 8
+		if src, ok := i.Src.(*ttir.FunctionAddress); ok {
+			return []Instruction{
+				comment(i.String()),
+				&LeaInstruction{Dst: R11, Src: Global{Name: src.Name}},
+				&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(i.Dst), Rhs: R11},
+			}
 		}
-
+		if types.IsAggregate(i.Dst.ValueType()) {
+			instructions := []Instruction{comment(i.String())}
+			return append(instructions, copyMemory(toAsmOperand(i.Dst).(MemoryOperand), toAsmOperand(i.Src).(MemoryOperand), i.Dst.ValueType().Size())...)
+		}
+		return []Instruction{comment(i.String()), &SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(i.Dst), Rhs: toAsmOperand(i.Src), Size: sizeOf(i.Dst)}}
+	case *ttir.AddressOf:
+		return []Instruction{
+			comment(i.String()),
+			&LeaInstruction{Dst: R11, Src: toAsmOperand(i.Src).(MemoryOperand)},
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(i.Dst), Rhs: R11},
+		}
+	case *ttir.Load:
+		instructions := []Instruction{comment(i.String()), &SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: toAsmOperand(i.Address)}}
+		if dst, ok := toAsmOperand(i.Dst).(MemoryOperand); ok {
+			return append(instructions, copyMemory(dst, Indirect{Base: R11}, i.Dst.ValueType().Size())...)
+		}
+		return append(instructions,
+			&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: Indirect{Base: R11}, Size: sizeOf(i.Dst)},
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(i.Dst), Rhs: R10, Size: sizeOf(i.Dst)},
+		)
+	case *ttir.Store:
+		instructions := []Instruction{comment(i.String()), &SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: toAsmOperand(i.Address)}}
+		if src, ok := toAsmOperand(i.Src).(MemoryOperand); ok && types.IsAggregate(i.Src.ValueType()) {
+			return append(instructions, copyMemory(Indirect{Base: R11}, src, i.Src.ValueType().Size())...)
+		}
+		return append(instructions,
+			&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: toAsmOperand(i.Src), Size: sizeOf(i.Src)},
+			&SimpleInstruction{Opcode: Mov, Lhs: Indirect{Base: R11}, Rhs: R10, Size: sizeOf(i.Src)},
+		)
+	case *ttir.BoundsCheck:
+		// The unsigned comparison also catches negative indices
+		cond := AboveEqual
+		if i.Inclusive {
+			cond = Above
+		}
+		return []Instruction{
+			comment(i.String()),
+			&SimpleInstruction{Opcode: Cmp, Lhs: toAsmOperand(i.Index), Rhs: toAsmOperand(i.Length)},
+			&JumpCCInstruction{Cond: cond, Dst: boundsErrorLabel},
+		}
+	case *ttir.Panic:
+		// The routine does not return, so the stack does not need to be aligned
+		instructions := []Instruction{comment(i.String())}
+		instructions = append(instructions, loadExtended(DI, i.Location)...)
+		instructions = append(instructions, loadExtended(SI, i.LocationLength)...)
+		instructions = append(instructions, loadExtended(DX, i.Message)...)
+		instructions = append(instructions, loadExtended(CX, i.MessageLength)...)
+		return append(instructions, Call(panicLabel))
+	case *ttir.Syscall:
 		instructions := []Instruction{comment(i.String())}
+		for j, arg := range i.Arguments {
+			instructions = append(instructions, loadExtended(syscallArgs[j], arg)...)
+		}
+		return append(instructions,
+			&SimpleInstruction{Opcode: Mov, Lhs: AX, Rhs: Imm(i.Number)},
+			&SimpleInstruction{Opcode: Syscall},
+		)
+	case *ttir.Call:
+		return cgCall(comment(i.String()), i.Arguments, i.ReturnValue, []Instruction{Call(i.FunctionName)})
+	case *ttir.CallIndirect:
+		// The environment is passed in rax, after the arguments are set up
+		return cgCall(comment(i.String()), i.Arguments, i.ReturnValue, []Instruction{
+			&SimpleInstruction{Opcode: Mov, Lhs: AX, Rhs: toAsmOperand(i.Environment)},
+			&CallIndirect{Target: toAsmOperand(i.Code)},
+		})
+	default:
+		panic(fmt.Sprintf("unexpected ttir.Instruction: %#v", i))
+	}
+
+}
+
+// Passes the arguments according to the System V ABI, emits the call and stores the return value
+func cgCall(c Comment, arguments []ttir.Operand, returnValue ttir.Operand, call []Instruction) []Instruction {
+	returnType := types.Type(types.Unit)
+	if returnValue != nil {
+		returnType = returnValue.ValueType()
+	}
+	locations, stackSize := classifyArguments(operandTypes(arguments), returnType)
+
+	stackPadding := int64(0)
+	if stackSize%16 != 0 {
+		stackPadding = 8
+	}
+
+	instructions := []Instruction{c}
 
-		if stackPadding > 0 {
-			instructions = append(instructions, AllocateStack(stackPadding))
+	if stackPadding > 0 {
+		instructions = append(instructions, AllocateStack(stackPadding))
+	}
+
+	for j, arg := range arguments {
+		for k, reg := range locations[j].registers {
+			if src, ok := toAsmOperand(arg).(MemoryOperand); ok {
+				instructions = append(instructions, move(reg, src.at(int64(8*k)), Eight))
+			} else {
+				instructions = append(instructions, load(reg, arg)...)
+			}
 		}
+	}
 
-		for i, arg := range registerArgs {
-			instructions = append(instructions,
-				&SimpleInstruction{
-					Opcode: Mov,
-					Rhs:    toAsmOperand(arg),
-					Lhs:    callConvArgs[i],
-				},
-			)
+	for j, arg := range slices.Backward(arguments) {
+		if len(locations[j].registers) != 0 {
+			continue
 		}
 
-		for _, arg := range slices.Backward(stackArgs) {
-			switch arg := toAsmOperand(arg).(type) {
-			case Imm:
-				instructions = append(instructions, &SimpleInstruction{Opcode: Push, Lhs: arg})
-			case Register:
-				instructions = append(instructions, &SimpleInstruction{Opcode: Push, Lhs: arg})
-			case Pseudo:
-				instructions = append(instructions,
-					&SimpleInstruction{Opcode: Mov, Rhs: arg, Lhs: AX},
-					&SimpleInstruction{Opcode: Push, Lhs: AX},
-				)
-			case Stack:
+		switch asmArg := toAsmOperand(arg).(type) {
+		case Imm:
+			instructions = append(instructions, &SimpleInstruction{Opcode: Push, Lhs: asmArg})
+		case Pseudo:
+			instructions = append(instructions, loadExtended(AX, arg)...)
+			instructions = append(instructions, &SimpleInstruction{Opcode: Push, Lhs: AX})
+		case PseudoMem:
+			// The eightbytes are pushed in reverse, so that the struct is in order on the stack
+			for k := eightbytes(arg.ValueType()) - 1; k >= 0; k-- {
 				instructions = append(instructions,
-					&SimpleInstruction{Opcode: Mov, Rhs: arg, Lhs: AX},
+					&SimpleInstruction{Opcode: Mov, Lhs: AX, Rhs: asmArg.at(8 * k)},
 					&SimpleInstruction{Opcode: Push, Lhs: AX},
 				)
-			default:
-				panic(fmt.Sprintf("unexpected amd64.Operand: %#v", arg))
 			}
+		default:
+			panic(fmt.Sprintf("unexpected amd64.Operand: %#v", asmArg))
 		}
+	}
+
+	if inMemory(returnType) {
+		instructions = append(instructions, &LeaInstruction{Dst: callConvArgs[0], Src: toAsmOperand(returnValue).(MemoryOperand)})
+	}
 
-		instructions = append(instructions, Call(i.FunctionName))
-		bytesToRemove := 8*len(stackArgs) + stackPadding
-		if bytesToRemove != 0 {
-			instructions = append(instructions, DeallocateStack(bytesToRemove))
+	instructions = append(instructions, call...)
+	bytesToRemove := stackSize + stackPadding
+	if bytesToRemove != 0 {
+		instructions = append(instructions, DeallocateStack(bytesToRemove))
+	}
+
+	if returnValue != nil && !inMemory(returnType) {
+		asmDst := toAsmOperand(returnValue)
+		if dst, ok := asmDst.(MemoryOperand); ok {
+			for k, reg := range returnRegisters(returnType) {
+				instructions = append(instructions, move(dst.at(int64(8*k)), reg, Eight))
+			}
+		} else {
+			instructions = append(instructions, move(asmDst, returnRegister(returnValue), sizeOf(returnValue)))
 		}
+	}
+
+	return instructions
+}
+
+func cgUnary(u *ttir.Unary) []Instruction {
+	if ttir.ClassOf(u.Src) == ttir.FloatClass {
+		return cgFloatUnary(u)
+	}
 
-		if i.ReturnValue != nil {
-			asmDst := toAsmOperand(i.ReturnValue)
-			instructions = append(instructions, &SimpleInstruction{Opcode: Mov, Rhs: AX, Lhs: asmDst})
+	switch u.Operator {
+	case ast.Negate, ast.Complement:
+		opcode := Neg
+		if u.Operator == ast.Complement {
+			opcode = Not
 		}
 
-		return instructions
-	default:
-		panic(fmt.Sprintf("unexpected ttir.Instruction: %#v", i))
+		return []Instruction{
+			comment(u.String()),
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(u.Dst), Rhs: toAsmOperand(u.Src), Size: sizeOf(u.Dst)},
+			&SimpleInstruction{Opcode: opcode, Lhs: toAsmOperand(u.Dst), Size: sizeOf(u.Dst)},
+		}
+	case ast.Not:
+		return []Instruction{
+			comment(u.String()),
+			&SimpleInstruction{Opcode: Cmp, Lhs: toAsmOperand(u.Src), Rhs: Imm(0), Size: sizeOf(u.Src)},
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(u.Dst), Rhs: Imm(0), Size: sizeOf(u.Dst)},
+			&SetCCInstruction{Cond: Equal, Dst: toAsmOperand(u.Dst)},
+		}
 	}
 
+	panic(fmt.Sprintf("unknown unary operator, %v", u))
 }
 
 func cgBinary(b *ttir.Binary) []Instruction {
+	if ttir.ClassOf(b.Lhs) == ttir.FloatClass {
+		return cgFloatBinary(b)
+	}
+
 	switch b.Operator {
 	case ast.Equal, ast.NotEqual, ast.GreaterThan, ast.GreaterThanEqual, ast.LessThan, ast.LessThanEqual:
 		var condCode CondCode
+		signed := types.IsSigned(b.Lhs.ValueType())
 
 		switch b.Operator {
 		case ast.Equal:
@@ -212,12 +617,24 @@ func cgBinary(b *ttir.Binary) []Instruction {
 			condCode = NotEqual
 		case ast.GreaterThan:
 			condCode = Greater
+			if !signed {
+				condCode = Above
+			}
 		case ast.GreaterThanEqual:
 			condCode = GreaterEqual
+			if !signed {
+				condCode = AboveEqual
+			}
 		case ast.LessThan:
 			condCode = Less
+			if !signed {
+				condCode = Below
+			}
 		case ast.LessThanEqual:
 			condCode = LessEqual
+			if !signed {
+				condCode = BelowEqual
+			}
 		}
 
 		return []Instruction{
@@ -226,18 +643,20 @@ func cgBinary(b *ttir.Binary) []Instruction {
 				Opcode: Cmp,
 				Lhs:    toAsmOperand(b.Lhs),
 				Rhs:    toAsmOperand(b.Rhs),
+				Size:   sizeOf(b.Lhs),
 			},
 			&SimpleInstruction{
 				Opcode: Mov,
 				Lhs:    toAsmOperand(b.Dst),
 				Rhs:    Imm(0),
+				Size:   sizeOf(b.Dst),
 			},
 			&SetCCInstruction{
 				Cond: condCode,
 				Dst:  toAsmOperand(b.Dst),
 			},
 		}
-	case ast.Add, ast.Subtract, ast.Multiply:
+	case ast.Add, ast.Subtract, ast.Multiply, ast.BitwiseAnd, ast.BitwiseOr, ast.BitwiseXor:
 		var opcode Opcode
 		switch b.Operator {
 		case ast.Add:
@@ -246,24 +665,241 @@ func cgBinary(b *ttir.Binary) []Instruction {
 			opcode = Sub
 		case ast.Multiply:
 			opcode = Imul
+		case ast.BitwiseAnd:
+			opcode = And
+		case ast.BitwiseOr:
+			opcode = Or
+		case ast.BitwiseXor:
+			opcode = Xor
+		}
+
+		size := sizeOf(b.Dst)
+		opSize := size
+		if opcode == Imul && size == One {
+			// There is no two operand imul for bytes, the lower byte of the result is the same
+			opSize = Four
 		}
 
 		return []Instruction{
 			comment(b.String()),
-			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(b.Dst), Rhs: toAsmOperand(b.Lhs)},
-			&SimpleInstruction{Opcode: opcode, Lhs: toAsmOperand(b.Dst), Rhs: toAsmOperand(b.Rhs)},
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(b.Dst), Rhs: toAsmOperand(b.Lhs), Size: size},
+			&SimpleInstruction{Opcode: opcode, Lhs: toAsmOperand(b.Dst), Rhs: toAsmOperand(b.Rhs), Size: opSize},
 		}
+	case ast.Divide, ast.Modulo:
+		// idiv and div divide rdx:rax, the quotient is in rax and the remainder in rdx
+		// Smaller values are extended to 64 bit first, so that the upper half is always in rdx
+		result := AX
+		if b.Operator == ast.Modulo {
+			result = DX
+		}
+
+		instructions := []Instruction{comment(b.String())}
+		instructions = append(instructions, loadExtended(AX, b.Lhs)...)
+
+		opcode := Idiv
+		if types.IsSigned(b.Lhs.ValueType()) {
+			instructions = append(instructions, &SimpleInstruction{Opcode: Cqo})
+		} else {
+			opcode = Div
+			instructions = append(instructions, &SimpleInstruction{Opcode: Mov, Lhs: DX, Rhs: Imm(0)})
+		}
+
+		if sizeOf(b.Rhs) == Eight {
+			instructions = append(instructions, &SimpleInstruction{Opcode: opcode, Lhs: toAsmOperand(b.Rhs)})
+		} else {
+			instructions = append(instructions, loadExtended(R10, b.Rhs)...)
+			instructions = append(instructions, &SimpleInstruction{Opcode: opcode, Lhs: R10})
+		}
+
+		return append(instructions, &SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(b.Dst), Rhs: result, Size: sizeOf(b.Dst)})
+	case ast.ShiftLeft, ast.ShiftRight, ast.ShiftRightLogical:
+		var opcode Opcode
+		switch b.Operator {
+		case ast.ShiftLeft:
+			opcode = Shl
+		case ast.ShiftRight:
+			// Unsigned values have no sign to keep
+			opcode = Sar
+			if !types.IsSigned(b.Lhs.ValueType()) {
+				opcode = Shr
+			}
+		case ast.ShiftRightLogical:
+			opcode = Shr
+		}
+
+		instructions := []Instruction{comment(b.String())}
+		instructions = append(instructions, loadExtended(CX, b.Rhs)...)
+		return append(instructions,
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(b.Dst), Rhs: toAsmOperand(b.Lhs), Size: sizeOf(b.Dst)},
+			&SimpleInstruction{Opcode: opcode, Lhs: toAsmOperand(b.Dst), Rhs: Register(CX), Size: sizeOf(b.Dst)},
+		)
+	}
+
+	panic(fmt.Sprintf("unknown binary operator, %v", b))
+}
+
+// The sign bit of a float, flipping it negates the float
+const floatSignBit Imm = math.MinInt64
+
+func cgFloatUnary(u *ttir.Unary) []Instruction {
+	if u.Operator != ast.Negate {
+		panic(fmt.Sprintf("unknown float unary operator, %v", u))
+	}
+
+	return []Instruction{
+		comment(u.String()),
+		&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(u.Dst), Rhs: toAsmOperand(u.Src)},
+		&SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: floatSignBit},
+		&SimpleInstruction{Opcode: Xor, Lhs: toAsmOperand(u.Dst), Rhs: R11},
+	}
+}
+
+// The float operations work on the scratch registers xmm14 and xmm15
+func cgFloatBinary(b *ttir.Binary) []Instruction {
+	instructions := []Instruction{comment(b.String())}
+
+	var opcode Opcode
+	switch b.Operator {
+	case ast.Add:
+		opcode = Addsd
+	case ast.Subtract:
+		opcode = Subsd
+	case ast.Multiply:
+		opcode = Mulsd
 	case ast.Divide:
+		opcode = Divsd
+	case ast.Equal, ast.NotEqual, ast.GreaterThan, ast.GreaterThanEqual, ast.LessThan, ast.LessThanEqual:
+		// ucomisd sets the flags like an unsigned comparison, if one side is NaN, zf, pf and cf are set.
+		// "a" and "ae" are false in that case, so the operands of < and <= are swapped to use them.
+		lhs, rhs := b.Lhs, b.Rhs
+		var condCode CondCode
+		switch b.Operator {
+		case ast.Equal:
+			condCode = Equal
+		case ast.NotEqual:
+			condCode = NotEqual
+		case ast.GreaterThan:
+			condCode = Above
+		case ast.GreaterThanEqual:
+			condCode = AboveEqual
+		case ast.LessThan:
+			condCode = Above
+			lhs, rhs = rhs, lhs
+		case ast.LessThanEqual:
+			condCode = AboveEqual
+			lhs, rhs = rhs, lhs
+		}
+
+		instructions = append(instructions, load(XMM14, lhs)...)
+		instructions = append(instructions, load(XMM15, rhs)...)
+		instructions = append(instructions,
+			&SimpleInstruction{Opcode: Ucomisd, Lhs: XMM14, Rhs: XMM15},
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(b.Dst), Rhs: Imm(0), Size: sizeOf(b.Dst)},
+			&SetCCInstruction{Cond: condCode, Dst: toAsmOperand(b.Dst)},
+		)
+
+		// NaN is not equal to anything, the parity flag has to be checked too
+		switch b.Operator {
+		case ast.Equal:
+			instructions = append(instructions,
+				&SetCCInstruction{Cond: NotParity, Dst: R11},
+				&SimpleInstruction{Opcode: And, Lhs: toAsmOperand(b.Dst), Rhs: R11, Size: One},
+			)
+		case ast.NotEqual:
+			instructions = append(instructions,
+				&SetCCInstruction{Cond: Parity, Dst: R11},
+				&SimpleInstruction{Opcode: Or, Lhs: toAsmOperand(b.Dst), Rhs: R11, Size: One},
+			)
+		}
+		return instructions
+	default:
+		panic(fmt.Sprintf("unknown float binary operator, %v", b))
+	}
+
+	instructions = append(instructions, load(XMM14, b.Lhs)...)
+	instructions = append(instructions, load(XMM15, b.Rhs)...)
+	return append(instructions,
+		&SimpleInstruction{Opcode: opcode, Lhs: XMM14, Rhs: XMM15},
+		&SimpleInstruction{Opcode: Movsd, Lhs: toAsmOperand(b.Dst), Rhs: XMM14},
+	)
+}
+
+// Converts between integers and floats, the conversion to an integer truncates towards zero
+func cgFloatConvert(c *ttir.Convert) []Instruction {
+	instructions := []Instruction{comment(c.String())}
+
+	if ttir.ClassOf(c.Dst) == ttir.IntegerClass {
+		if src, ok := c.Src.(*ttir.Constant); ok {
+			value := int64(src.Float())
+			if !types.IsSigned(c.Dst.ValueType()) {
+				value = int64(uint64(src.Float()))
+			}
+			return append(instructions, &SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(c.Dst), Rhs: Imm(truncateConstant(value, c.Dst.ValueType())), Size: sizeOf(c.Dst)})
+		}
+
+		instructions = append(instructions, load(XMM14, c.Src)...)
+		return append(instructions,
+			&SimpleInstruction{Opcode: Cvttsd2si, Lhs: R10, Rhs: XMM14},
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(c.Dst), Rhs: R10, Size: sizeOf(c.Dst)},
+		)
+	}
+
+	if src, ok := c.Src.(*ttir.Constant); ok {
+		value := float64(src.Value)
+		if !types.IsSigned(c.Src.ValueType()) {
+			value = float64(uint64(src.Value))
+		}
+		return append(instructions, &SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(c.Dst), Rhs: Imm(math.Float64bits(value))})
+	}
+
+	instructions = append(instructions, loadExtended(R10, c.Src)...)
+	if c.Src.ValueType().IsSameType(types.U64) {
+		// cvtsi2sd only converts signed integers, so the upper and lower half are converted separately.
+		// Both fit into a signed integer and the result is only rounded once, when they are added.
+		instructions = append(instructions,
+			&SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: R10},
+			&SimpleInstruction{Opcode: Shr, Lhs: R11, Rhs: Imm(32)},
+			&SimpleInstruction{Opcode: Cvtsi2sd, Lhs: XMM14, Rhs: R11},
+			&SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: Imm(math.Float64bits(1 << 32))},
+			&SimpleInstruction{Opcode: Movq, Lhs: XMM15, Rhs: R11},
+			&SimpleInstruction{Opcode: Mulsd, Lhs: XMM14, Rhs: XMM15},
+			// Writing the lower half clears the upper half
+			&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: R10, Size: Four},
+			&SimpleInstruction{Opcode: Cvtsi2sd, Lhs: XMM15, Rhs: R10},
+			&SimpleInstruction{Opcode: Addsd, Lhs: XMM14, Rhs: XMM15},
+		)
+	} else {
+		instructions = append(instructions, &SimpleInstruction{Opcode: Cvtsi2sd, Lhs: XMM14, Rhs: R10})
+	}
+	return append(instructions, &SimpleInstruction{Opcode: Movsd, Lhs: toAsmOperand(c.Dst), Rhs: XMM14})
+}
+
+func cgConvert(c *ttir.Convert) []Instruction {
+	if ttir.ClassOf(c.Src) == ttir.FloatClass || ttir.ClassOf(c.Dst) == ttir.FloatClass {
+		return cgFloatConvert(c)
+	}
+
+	srcSize := sizeOf(c.Src)
+	dstSize := sizeOf(c.Dst)
+
+	if src, ok := c.Src.(*ttir.Constant); ok {
 		return []Instruction{
-			comment(b.String()),
-			&SimpleInstruction{Opcode: Mov, Lhs: Register(AX), Rhs: toAsmOperand(b.Lhs)},
-			&SimpleInstruction{Opcode: Cdq},
-			&SimpleInstruction{Opcode: Idiv, Lhs: toAsmOperand(b.Rhs)},
-			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(b.Dst), Rhs: Register(AX)},
+			comment(c.String()),
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(c.Dst), Rhs: Imm(truncateConstant(src.Value, c.Dst.ValueType())), Size: dstSize},
 		}
 	}
 
-	panic(fmt.Sprintf("unknown binary operator, %v", b))
+	// Truncating only needs the lower part of the value
+	if dstSize <= srcSize {
+		return []Instruction{
+			comment(c.String()),
+			&SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(c.Dst), Rhs: toAsmOperand(c.Src), Size: dstSize},
+		}
+	}
+
+	instructions := []Instruction{comment(c.String())}
+	instructions = append(instructions, loadExtended(R10, c.Src)...)
+	return append(instructions, &SimpleInstruction{Opcode: Mov, Lhs: toAsmOperand(c.Dst), Rhs: R10, Size: dstSize})
 }
 
 // Second pass, replace all the pseudos with stack addresses
@@ -300,7 +936,7 @@ func rpInstruction(i Instruction, r *replacePseudoPass) Instruction {
 	switch i := i.(type) {
 	case *SimpleInstruction:
 
-		newInstruction := &SimpleInstruction{Opcode: i.Opcode}
+		newInstruction := &SimpleInstruction{Opcode: i.Opcode, Size: i.Size}
 		if i.Lhs != nil {
 			newInstruction.Lhs = pseudoToStack(i.Lhs, r)
 		}
@@ -314,6 +950,20 @@ func rpInstruction(i Instruction, r *replacePseudoPass) Instruction {
 			Cond: i.Cond,
 			Dst:  pseudoToStack(i.Dst, r),
 		}
+	case *ExtendInstruction:
+		return &ExtendInstruction{
+			Signed:  i.Signed,
+			Dst:     i.Dst,
+			Src:     pseudoToStack(i.Src, r),
+			SrcSize: i.SrcSize,
+		}
+	case *LeaInstruction:
+		return &LeaInstruction{
+			Dst: i.Dst,
+			Src: pseudoToStack(i.Src, r).(MemoryOperand),
+		}
+	case *CallIndirect:
+		return &CallIndirect{Target: pseudoToStack(i.Target, r)}
 	case *JumpCCInstruction, JmpInstruction, Label, AllocateStack, DeallocateStack, Call, Comment:
 		return i
 	default:
@@ -331,11 +981,26 @@ func pseudoToStack(op Operand, r *replacePseudoPass) Operand {
 			return Stack(r.currentOffset)
 		}
 	}
+	if pseudo, ok := op.(PseudoMem); ok {
+		offset, ok := r.identToOffset[pseudo.Name]
+		if !ok {
+			// Every struct gets a multiple of 8 bytes, so that it can always be accessed in eightbytes
+			r.currentOffset -= (pseudo.Size + 7) / 8 * 8
+			r.identToOffset[pseudo.Name] = r.currentOffset
+			offset = r.currentOffset
+		}
+		return Stack(offset + pseudo.Offset)
+	}
 	return op
 }
 
 // Third pass, fixup invalid instructions
 
+// Immediates are sign extended from 32 bits, except for a move into a register
+func fitsImm32(imm Imm) bool {
+	return imm >= math.MinInt32 && imm <= math.MaxInt32
+}
+
 func instructionFixup(prog Program) Program {
 	newFuncs := []Function{}
 
@@ -371,15 +1036,50 @@ func fixupInstruction(i Instruction) []Instruction {
 
 	switch i := i.(type) {
 	case *SimpleInstruction:
+		switch i.Opcode {
+		case Add, Sub, And, Or, Xor, Imul, Cmp:
+			// Like Mov, these take at most 32 bits as immediate, a bigger one is loaded into R10 first
+			if imm, ok := i.Rhs.(Imm); ok && !fitsImm32(imm) {
+				return append([]Instruction{
+					comment(fmt.Sprintf("FIXUP: Imm64 for %s", i.Opcode)),
+					comment(i.InstructionString()),
+					&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: imm},
+				}, fixupInstruction(&SimpleInstruction{Opcode: i.Opcode, Lhs: i.Lhs, Rhs: R10, Size: i.Size})...)
+			}
+		}
+
 		switch i.Opcode {
 		case Mov:
+			// Only a move into a register can take an immediate, which does not fit into 32 bits, like the bits of a float
+			if imm, ok := i.Rhs.(Imm); ok && !fitsImm32(imm) {
+				if _, ok := i.Lhs.(Register); !ok {
+					return []Instruction{
+						comment("FIXUP: Imm64 for Mov"),
+						comment(i.InstructionString()),
+						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: imm},
+						&SimpleInstruction{Opcode: Mov, Lhs: i.Lhs, Rhs: R10, Size: i.Size},
+					}
+				}
+			}
 			if dst, ok := i.Lhs.(Stack); ok {
 				if src, ok := i.Rhs.(Stack); ok {
 					return []Instruction{
 						comment("FIXUP: Stack and Stack for Mov"),
 						comment(i.InstructionString()),
-						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: src},
-						&SimpleInstruction{Opcode: Mov, Lhs: dst, Rhs: R10},
+						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: src, Size: i.Size},
+						&SimpleInstruction{Opcode: Mov, Lhs: dst, Rhs: R10, Size: i.Size},
+					}
+				}
+			}
+			_, dstGlobal := i.Lhs.(Global)
+			_, srcGlobal := i.Rhs.(Global)
+			if dst, ok := i.Lhs.(MemoryOperand); ok && (dstGlobal || srcGlobal) {
+				if src, ok := i.Rhs.(MemoryOperand); ok {
+					return []Instruction{
+						comment("FIXUP: Global and Memory for Mov"),
+						comment(i.InstructionString()),
+						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: src, Size: i.Size},
+						&SimpleInstruction{Opcode: Mov, Lhs: dst, Rhs: R10, Size: i.Size},
 					}
 				}
 			}
@@ -388,28 +1088,37 @@ func fixupInstruction(i Instruction) []Instruction {
 				return []Instruction{
 					comment("FIXUP: Stack as Dst for Imul"),
 					comment(i.InstructionString()),
-					&SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: lhs},
-					&SimpleInstruction{Opcode: Imul, Lhs: R11, Rhs: i.Rhs},
-					&SimpleInstruction{Opcode: Mov, Lhs: lhs, Rhs: R11},
+					&SimpleInstruction{Opcode: Mov, Lhs: R11, Rhs: lhs, Size: i.Size},
+					&SimpleInstruction{Opcode: Imul, Lhs: R11, Rhs: i.Rhs, Size: i.Size},
+					&SimpleInstruction{Opcode: Mov, Lhs: lhs, Rhs: R11, Size: i.Size},
 				}
 			}
 			fallthrough
-		case Add, Sub, Idiv /* Imul (fallthrough) */ :
+		case Add, Sub, And, Or, Xor, Idiv, Div /* Imul (fallthrough) */ :
 			if lhs, ok := i.Lhs.(Stack); ok {
 				if rhs, ok := i.Rhs.(Stack); ok {
 					return []Instruction{
 						comment("FIXUP: Stack and Stack for Binary"),
 						comment(i.InstructionString()),
-						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: rhs},
-						&SimpleInstruction{Opcode: i.Opcode, Lhs: lhs, Rhs: R10},
+						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: rhs, Size: i.Size},
+						&SimpleInstruction{Opcode: i.Opcode, Lhs: lhs, Rhs: R10, Size: i.Size},
 					}
 				}
-			} else if lhs, ok := i.Lhs.(Imm); ok && i.Opcode == Idiv {
+			} else if lhs, ok := i.Lhs.(Imm); ok && (i.Opcode == Idiv || i.Opcode == Div) {
 				return []Instruction{
 					comment("FIXUP: Imm as Dst for Idiv"),
 					comment(i.InstructionString()),
-					&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: lhs},
-					&SimpleInstruction{Opcode: Idiv, Lhs: R10},
+					&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: lhs, Size: i.Size},
+					&SimpleInstruction{Opcode: i.Opcode, Lhs: R10, Size: i.Size},
+				}
+			}
+		case Push:
+			if imm, ok := i.Lhs.(Imm); ok && !fitsImm32(imm) {
+				return []Instruction{
+					comment("FIXUP: Imm64 for Push"),
+					comment(i.InstructionString()),
+					&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: imm},
+					&SimpleInstruction{Opcode: Push, Lhs: R10},
 				}
 			}
 		case Cmp:
@@ -418,8 +1127,8 @@ func fixupInstruction(i Instruction) []Instruction {
 					return []Instruction{
 						comment("FIXUP: Stack and Stack for Cmp"),
 						comment(i.InstructionString()),
-						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: rhs},
-						&SimpleInstruction{Opcode: i.Opcode, Lhs: lhs, Rhs: R10},
+						&SimpleInstruction{Opcode: Mov, Lhs: R10, Rhs: rhs, Size: i.Size},
+						&SimpleInstruction{Opcode: i.Opcode, Lhs: lhs, Rhs: R10, Size: i.Size},
 					}
 				}
 			} else if lhs, ok := i.Lhs.(Imm); ok {
@@ -430,18 +1139,20 @@ func fixupInstruction(i Instruction) []Instruction {
 						Opcode: Mov,
 						Lhs:    R11,
 						Rhs:    lhs,
+						Size:   i.Size,
 					},
 					&SimpleInstruction{
 						Opcode: Cmp,
 						Lhs:    R11,
 						Rhs:    i.Rhs,
+						Size:   i.Size,
 					},
 				}
 			}
 		}
 
 		return []Instruction{i}
-	case *SetCCInstruction:
+	case *SetCCInstruction, *ExtendInstruction, *LeaInstruction, *CallIndirect:
 		return []Instruction{i}
 	case *JumpCCInstruction, JmpInstruction, Label, AllocateStack, DeallocateStack, Call, Comment:
 		return []Instruction{i}
@@ -7,6 +7,7 @@ import (
 
 	"robaertschi.xyz/robaertschi/tt/ast"
 	"robaertschi.xyz/robaertschi/tt/ttir"
+	"robaertschi.xyz/robaertschi/tt/types"
 
 	_ "embed"
 )
@@ -18,6 +19,13 @@ func extraLabel() string {
 	return fmt.Sprintf("qbe.extra.%d", extraLabelId)
 }
 
+var addressId int64 = 0
+
+func addressTemp() string {
+	addressId += 1
+	return fmt.Sprintf("%%qbe.addr.%d", addressId)
+}
+
 //go:embed qbe_stub.asm
 var Stub string
 
@@ -31,16 +39,240 @@ func emit(w io.Writer, content string) error {
 	return err
 }
 
+// Qbe only knows 32 bit (w) and 64 bit (l) integers, smaller values are stored as w.
+// Floats have their own class d.
+func class(t types.Type) string {
+	if types.IsFloat(t) {
+		return "d"
+	}
+	if t.Size() == 8 {
+		return "l"
+	}
+	return "w"
+}
+
+func classOf(op ttir.Operand) string {
+	return class(op.ValueType())
+}
+
+// The name of the aggregate type definition of t
+func typeName(t types.Type) string {
+	switch t := t.(type) {
+	case *types.ArrayType:
+		return fmt.Sprintf("array.%d.%s", t.Length, typeName(t.Element))
+	case *types.SliceType:
+		return "slice"
+	case *types.FunctionType:
+		return "function"
+	}
+	return t.Name()
+}
+
+// The class used for parameters, arguments and return values, aggregates are passed as aggregate types
+func abiClass(t types.Type) string {
+	if types.IsAggregate(t) {
+		return ":" + typeName(t)
+	}
+	return class(t)
+}
+
+// The type of a field in an aggregate type definition
+func fieldType(t types.Type) string {
+	if types.IsAggregate(t) {
+		return ":" + typeName(t)
+	}
+	if types.IsFloat(t) {
+		return "d"
+	}
+
+	switch t.Size() {
+	case 1:
+		return "b"
+	case 2:
+		return "h"
+	case 4:
+		return "w"
+	}
+	return "l"
+}
+
+// Emits the aggregate type definition of t, after the types it contains.
+// Types which are not aggregates do not need a definition.
+func emitType(w io.Writer, t types.Type, emitted map[string]bool) error {
+	if !types.IsAggregate(t) || emitted[typeName(t)] {
+		return nil
+	}
+	emitted[typeName(t)] = true
+
+	switch t := t.(type) {
+	case *types.StructType:
+		fields := []string{}
+		for _, field := range t.Fields {
+			if err := emitType(w, field.Type, emitted); err != nil {
+				return err
+			}
+			fields = append(fields, fieldType(field.Type))
+		}
+
+		return emitf(w, "type :%s = { %s }\n", typeName(t), strings.Join(fields, ", "))
+	case *types.EnumType:
+		// The payloads share their memory, so the enum is the tag followed by enough space for the largest one
+		for _, variant := range t.Variants {
+			for _, field := range variant.Fields {
+				if err := emitType(w, field, emitted); err != nil {
+					return err
+				}
+			}
+		}
+		return emitf(w, "type :%s = { l %d }\n", typeName(t), t.Size()/8)
+	case *types.ArrayType:
+		if err := emitType(w, t.Element, emitted); err != nil {
+			return err
+		}
+		return emitf(w, "type :%s = { %s %d }\n", typeName(t), fieldType(t.Element), t.Length)
+	case *types.SliceType, *types.StringType, *types.FunctionType:
+		return emitf(w, "type :%s = { l, l }\n", typeName(t))
+	}
+	return nil
+}
+
+// Emits the type definitions of the aggregates, that are passed to or returned from functions
+func emitFunctionTypes(w io.Writer, f *ttir.Function, emitted map[string]bool) error {
+	t := []types.Type{}
+	if f.ReturnType != nil {
+		t = append(t, f.ReturnType)
+	}
+	for _, arg := range f.Arguments {
+		t = append(t, arg.ValueType())
+	}
+	for _, i := range f.Instructions {
+		var arguments []ttir.Operand
+		var returnValue ttir.Operand
+		switch call := i.(type) {
+		case *ttir.Call:
+			arguments, returnValue = call.Arguments, call.ReturnValue
+		case *ttir.CallIndirect:
+			arguments, returnValue = call.Arguments, call.ReturnValue
+		default:
+			continue
+		}
+
+		if returnValue != nil {
+			t = append(t, returnValue.ValueType())
+		}
+		for _, arg := range arguments {
+			t = append(t, arg.ValueType())
+		}
+	}
+
+	for _, t := range t {
+		if err := emitType(w, t, emitted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadInstruction(t types.Type) string {
+	if types.IsFloat(t) {
+		return "loadd"
+	}
+	switch t.Size() {
+	case 1:
+		if types.IsSigned(t) {
+			return "loadsb"
+		}
+		return "loadub"
+	case 2:
+		if types.IsSigned(t) {
+			return "loadsh"
+		}
+		return "loaduh"
+	case 4:
+		return "loadw"
+	}
+	return "loadl"
+}
+
+func storeInstruction(t types.Type) string {
+	if types.IsFloat(t) {
+		return "stored"
+	}
+	switch t.Size() {
+	case 1:
+		return "storeb"
+	case 2:
+		return "storeh"
+	case 4:
+		return "storew"
+	}
+	return "storel"
+}
+
+func isMemory(op ttir.Operand) bool {
+	switch op.(type) {
+	case *ttir.Memory, *ttir.Global:
+		return true
+	}
+	return false
+}
+
+// Emits the address of an aggregate, a part of it or a global variable and returns it
+func emitAddress(w io.Writer, op ttir.Operand) (string, error) {
+	switch op := op.(type) {
+	case *ttir.Memory:
+		addr := addressTemp()
+		return addr, emitf(w, "\t%s =l add %s, %d\n", addr, emitOperand(op.Base), op.Offset)
+	case *ttir.Global:
+		addr := addressTemp()
+		return addr, emitf(w, "\t%s =l add $%s, %d\n", addr, op.Name, op.Offset)
+	}
+
+	// Aggregate values are already addresses
+	return emitOperand(op), nil
+}
+
+// Values smaller than 32 bit are always kept sign or zero extended to 32 bit,
+// this returns the instruction that does that after an operation or "" if t is not smaller
+func extension(t types.Type) string {
+	if !types.IsInteger(t) {
+		return ""
+	}
+
+	switch t.Size() {
+	case 1:
+		if types.IsSigned(t) {
+			return "extsb"
+		}
+		return "extub"
+	case 2:
+		if types.IsSigned(t) {
+			return "extsh"
+		}
+		return "extuh"
+	}
+	return ""
+}
+
+func emitExtension(w io.Writer, dst ttir.Operand) error {
+	if ext := extension(dst.ValueType()); ext != "" {
+		return emitf(w, "\t%s =w %s %s\n", emitOperand(dst), ext, emitOperand(dst))
+	}
+	return nil
+}
+
 func Emit(output io.Writer, input *ttir.Program) error {
 	if input.MainFunction.HasReturnValue {
+		c := class(input.MainFunction.ReturnType)
 		emitf(output, `
 export function $_start() {
 @start
-    %%result =l call $main()
-    call $syscall1(l 60, l %%result)
+    %%result =%s call $main()
+    call $syscall1(l 60, %s %%result)
     hlt
 }
             `,
+			c, c,
 		)
 	} else {
 		emitf(output, `
@@ -54,50 +286,177 @@ export function $_start() {
 		)
 	}
 
+	emitted := make(map[string]bool)
+	for _, st := range input.Structs {
+		if err := emitType(output, st, emitted); err != nil {
+			return err
+		}
+	}
+	for _, f := range input.Functions {
+		if err := emitFunctionTypes(output, f, emitted); err != nil {
+			return err
+		}
+	}
+
 	for _, f := range input.Functions {
 		err := emitFunction(output, f)
 		if err != nil {
 			return err
 		}
 	}
+
+	for _, d := range input.Data {
+		bytes := []string{}
+		for _, b := range d.Value {
+			bytes = append(bytes, fmt.Sprintf("b %d", b))
+		}
+		if err := emitf(output, "data $%s = { %s }\n", d.Name, strings.Join(bytes, ", ")); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range input.Globals {
+		// Globals, which are only zeros, are zero filled by qbe
+		if g.IsZero() {
+			if err := emitf(output, "data $%s = align 8 { z %d }\n", g.Name, len(g.Value)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		bytes := []string{}
+		for _, b := range g.Value {
+			bytes = append(bytes, fmt.Sprintf("b %d", b))
+		}
+		if err := emitf(output, "data $%s = align 8 { %s }\n", g.Name, strings.Join(bytes, ", ")); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func emitFunction(w io.Writer, f *ttir.Function) error {
 	emitf(w, "export function ")
 	if f.HasReturnValue {
-		if err := emitf(w, "l "); err != nil {
+		if err := emitf(w, "%s ", abiClass(f.ReturnType)); err != nil {
 			return err
 		}
 	}
 
 	b := strings.Builder{}
 
+	// The environment of a closure is passed as the env parameter
+	if f.Environment != nil {
+		b.WriteString("env " + emitOperand(f.Environment))
+		if len(f.Arguments) > 0 {
+			b.WriteString(", ")
+		}
+	}
 	for i, arg := range f.Arguments {
 		if i > 0 {
 			b.WriteString(", ")
 		}
-		b.WriteString("l %")
-		b.WriteString(arg)
+		b.WriteString(abiClass(arg.ValueType()) + " " + emitOperand(arg))
 	}
 
 	if err := emitf(w, "$%s(%v) {\n@start\n", f.Name, b.String()); err != nil {
 		return err
 	}
+
+	for _, v := range memoryVariables(f) {
+		if err := emitf(w, "\t%s =l alloc8 %d\n", emitOperand(v), v.ValueType().Size()); err != nil {
+			return err
+		}
+	}
+	var previous ttir.Instruction
 	for _, i := range f.Instructions {
+		// Qbe requires a label after a return, even if the code after an early return is not reachable
+		if _, ok := previous.(*ttir.Ret); ok {
+			if _, ok := i.(ttir.Label); !ok {
+				if err := emitf(w, "@%s\n", extraLabel()); err != nil {
+					return err
+				}
+			}
+		}
 		if err := emitInstruction(w, i); err != nil {
 			return err
 		}
+		previous = i
+	}
+
+	// The end of a function, whose body never produces a value, is not reachable, but qbe requires every block to end with a jump
+	if _, ok := previous.(*ttir.Ret); !ok {
+		if err := emitf(w, "\thlt\n"); err != nil {
+			return err
+		}
 	}
 	return emitf(w, "}\n")
 }
 
+// Returns the variables that need stack memory, these are aggregates and variables whose address is taken.
+// Parameters and the results of calls already point to memory, which is provided by qbe.
+func memoryVariables(f *ttir.Function) []*ttir.Var {
+	seen := make(map[string]bool)
+	for _, arg := range f.Arguments {
+		seen[arg.Value] = true
+	}
+	for _, i := range f.Instructions {
+		switch call := i.(type) {
+		case *ttir.Call:
+			if call.ReturnValue != nil {
+				seen[call.ReturnValue.(*ttir.Var).Value] = true
+			}
+		case *ttir.CallIndirect:
+			if call.ReturnValue != nil {
+				seen[call.ReturnValue.(*ttir.Var).Value] = true
+			}
+		}
+	}
+
+	vars := []*ttir.Var{}
+	for _, i := range f.Instructions {
+		var dst ttir.Operand
+		switch i := i.(type) {
+		case *ttir.Copy:
+			dst = i.Dst
+		case *ttir.Load:
+			dst = i.Dst
+		default:
+			continue
+		}
+
+		var v *ttir.Var
+		switch dst := dst.(type) {
+		case *ttir.Var:
+			if types.IsAggregate(dst.ValueType()) {
+				v = dst
+			}
+		case *ttir.Memory:
+			v = dst.Base
+		}
+
+		if v != nil && !seen[v.Value] {
+			seen[v.Value] = true
+			vars = append(vars, v)
+		}
+	}
+
+	return vars
+}
+
 func emitOperand(op ttir.Operand) string {
 	switch op := op.(type) {
 	case *ttir.Constant:
+		if ttir.ClassOf(op) == ttir.FloatClass {
+			return "d_" + op.String()
+		}
 		return fmt.Sprintf("%d", op.Value)
 	case *ttir.Var:
 		return "%" + op.Value
+	case *ttir.DataAddress:
+		return "$" + op.Name
+	case *ttir.FunctionAddress:
+		return "$" + op.Name
 	}
 	panic(fmt.Sprintf("invalid operand %T", op))
 }
@@ -110,63 +469,278 @@ func emitInstruction(w io.Writer, i ttir.Instruction) error {
 		} else {
 			return emitf(w, "\tret\n")
 		}
-	case *ttir.Binary:
-		var inst string
+	case *ttir.Unary:
 		switch i.Operator {
-		case ast.Add:
-			inst = "add"
-		case ast.Subtract:
-			inst = "sub"
-		case ast.Multiply:
-			inst = "mul"
-		case ast.Divide:
-			inst = "div"
-		case ast.Equal:
-			inst = "ceql"
-		case ast.NotEqual:
-			inst = "cnel"
-		case ast.GreaterThan:
-			inst = "csgtl"
-		case ast.GreaterThanEqual:
-			inst = "csgel"
-		case ast.LessThan:
-			inst = "csltl"
-		case ast.LessThanEqual:
-			inst = "cslel"
-		}
-		return emitf(w, "\t%s =l %s %s, %s\n", emitOperand(i.Dst), inst, emitOperand(i.Lhs), emitOperand(i.Rhs))
+		case ast.Negate:
+			if err := emitf(w, "\t%s =%s neg %s\n", emitOperand(i.Dst), classOf(i.Dst), emitOperand(i.Src)); err != nil {
+				return err
+			}
+			return emitExtension(w, i.Dst)
+		case ast.Not:
+			return emitf(w, "\t%s =%s ceq%s %s, 0\n", emitOperand(i.Dst), classOf(i.Dst), classOf(i.Src), emitOperand(i.Src))
+		case ast.Complement:
+			if err := emitf(w, "\t%s =%s xor %s, -1\n", emitOperand(i.Dst), classOf(i.Dst), emitOperand(i.Src)); err != nil {
+				return err
+			}
+			return emitExtension(w, i.Dst)
+		}
+		panic(fmt.Sprintf("unknown unary operator %v", i.Operator))
+	case *ttir.Binary:
+		return emitBinary(w, i)
+	case *ttir.Convert:
+		srcType := i.Src.ValueType()
+		if ttir.ClassOf(i.Src) == ttir.FloatClass || ttir.ClassOf(i.Dst) == ttir.FloatClass {
+			return emitFloatConvert(w, i)
+		}
+		inst := "copy"
+		// Using a l value as w takes the lower 32 bits, so only extending needs an instruction
+		if classOf(i.Dst) == "l" && classOf(i.Src) == "w" {
+			inst = "extuw"
+			if types.IsSigned(srcType) {
+				inst = "extsw"
+			}
+		}
+		if err := emitf(w, "\t%s =%s %s %s\n", emitOperand(i.Dst), classOf(i.Dst), inst, emitOperand(i.Src)); err != nil {
+			return err
+		}
+		return emitExtension(w, i.Dst)
 	case *ttir.Copy:
-		emitf(w, "\t%s =l copy %s\n", emitOperand(i.Dst), emitOperand(i.Src))
+		t := i.Dst.ValueType()
+		if types.IsAggregate(t) {
+			src, err := emitAddress(w, i.Src)
+			if err != nil {
+				return err
+			}
+			dst, err := emitAddress(w, i.Dst)
+			if err != nil {
+				return err
+			}
+			return emitf(w, "\tblit %s, %s, %d\n", src, dst, t.Size())
+		}
+
+		switch {
+		case isMemory(i.Src):
+			src, err := emitAddress(w, i.Src)
+			if err != nil {
+				return err
+			}
+			return emitf(w, "\t%s =%s %s %s\n", emitOperand(i.Dst), classOf(i.Dst), loadInstruction(t), src)
+		case isMemory(i.Dst):
+			dst, err := emitAddress(w, i.Dst)
+			if err != nil {
+				return err
+			}
+			return emitf(w, "\t%s %s, %s\n", storeInstruction(t), emitOperand(i.Src), dst)
+		}
+		emitf(w, "\t%s =%s copy %s\n", emitOperand(i.Dst), classOf(i.Dst), emitOperand(i.Src))
+	case *ttir.AddressOf:
+		src, err := emitAddress(w, i.Src)
+		if err != nil {
+			return err
+		}
+		return emitf(w, "\t%s =l copy %s\n", emitOperand(i.Dst), src)
+	case *ttir.Load:
+		t := i.Dst.ValueType()
+		if types.IsAggregate(t) {
+			return emitf(w, "\tblit %s, %s, %d\n", emitOperand(i.Address), emitOperand(i.Dst), t.Size())
+		}
+		return emitf(w, "\t%s =%s %s %s\n", emitOperand(i.Dst), classOf(i.Dst), loadInstruction(t), emitOperand(i.Address))
+	case *ttir.Store:
+		t := i.Src.ValueType()
+		if types.IsAggregate(t) {
+			src, err := emitAddress(w, i.Src)
+			if err != nil {
+				return err
+			}
+			return emitf(w, "\tblit %s, %s, %d\n", src, emitOperand(i.Address), t.Size())
+		}
+		return emitf(w, "\t%s %s, %s\n", storeInstruction(t), emitOperand(i.Src), emitOperand(i.Address))
+	case *ttir.BoundsCheck:
+		// The unsigned comparison also catches negative indices
+		inst := "cultl"
+		if i.Inclusive {
+			inst = "culel"
+		}
+		inBounds := "%" + extraLabel()
+		ok := extraLabel()
+		fail := extraLabel()
+		return emitf(w, "\t%s =w %s %s, %s\n\tjnz %s, @%s, @%s\n@%s\n\tcall $syscall1(l 60, l 101)\n\thlt\n@%s\n",
+			inBounds, inst, emitOperand(i.Index), emitOperand(i.Length),
+			inBounds, ok, fail,
+			fail,
+			ok,
+		)
+	case *ttir.Panic:
+		// The stub provides the panic routine, qbe requires a label after the hlt
+		after := extraLabel()
+		return emitf(w, "\tcall $tt.panic(l %s, l %s, l %s, l %s)\n\thlt\n@%s\n",
+			emitOperand(i.Location), emitOperand(i.LocationLength),
+			emitOperand(i.Message), emitOperand(i.MessageLength),
+			after,
+		)
 	case ttir.Label:
 		return emitf(w, "@%s\n", string(i))
 	case ttir.Jump:
-		return emitf(w, "\tjmp @%s\n", string(i))
+		// Qbe requires a label after a jump, even if it is not reachable
+		after := extraLabel()
+		return emitf(w, "\tjmp @%s\n@%s\n", string(i), after)
 	case *ttir.JumpIfNotZero:
 		after := extraLabel()
 		return emitf(w, "\tjnz %s, @%s, @%s\n@%s\n", emitOperand(i.Value), i.Label, after, after)
 	case *ttir.JumpIfZero:
 		after := extraLabel()
 		return emitf(w, "\tjnz %s, @%s, @%s\n@%s\n", emitOperand(i.Value), after, i.Label, after)
+	case *ttir.Syscall:
+		// The stub provides a function for every amount of arguments
+		args := []string{fmt.Sprintf("l %d", i.Number)}
+		for _, arg := range i.Arguments {
+			args = append(args, classOf(arg)+" "+emitOperand(arg))
+		}
+		return emitf(w, "\tcall $syscall%d(%s)\n", len(i.Arguments), strings.Join(args, ", "))
 	case *ttir.Call:
-		b := strings.Builder{}
-		b.WriteRune('\t')
-		if i.ReturnValue != nil {
-			b.WriteString(emitOperand(i.ReturnValue) + " =l ")
+		return emitCall(w, "$"+i.FunctionName, "", i.Arguments, i.ReturnValue)
+	case *ttir.CallIndirect:
+		// Qbe passes the environment in rax, functions without an env parameter ignore it
+		return emitCall(w, emitOperand(i.Code), emitOperand(i.Environment), i.Arguments, i.ReturnValue)
+	default:
+		panic("unkown instruction")
+	}
+
+	return nil
+}
+
+// Emits a call of function, environment is the env argument, if it is not empty
+func emitCall(w io.Writer, function string, environment string, arguments []ttir.Operand, returnValue ttir.Operand) error {
+	b := strings.Builder{}
+	b.WriteRune('\t')
+	if returnValue != nil {
+		b.WriteString(emitOperand(returnValue) + " =" + abiClass(returnValue.ValueType()) + " ")
+	}
+
+	args := []string{}
+	if environment != "" {
+		args = append(args, "env "+environment)
+	}
+	for _, arg := range arguments {
+		args = append(args, abiClass(arg.ValueType())+" "+emitOperand(arg))
+	}
+
+	b.WriteString("call " + function + "(" + strings.Join(args, ", ") + ")\n")
+	return emit(w, b.String())
+}
+
+// Converts between integers and floats, smaller integers are already extended to w
+func emitFloatConvert(w io.Writer, c *ttir.Convert) error {
+	var inst string
+	if ttir.ClassOf(c.Dst) == ttir.FloatClass {
+		inst = "swtof"
+		if !types.IsSigned(c.Src.ValueType()) {
+			inst = "uwtof"
+		}
+		if classOf(c.Src) == "l" {
+			inst = strings.Replace(inst, "w", "l", 1)
+		}
+	} else {
+		inst = "dtosi"
+		if !types.IsSigned(c.Dst.ValueType()) {
+			inst = "dtoui"
 		}
+	}
 
-		b.WriteString("call $" + i.FunctionName + "(")
-		for j, arg := range i.Arguments {
-			b.WriteString("l " + emitOperand(arg))
-			if j < (len(i.Arguments) - 1) {
-				b.WriteString(", ")
-			}
+	if err := emitf(w, "\t%s =%s %s %s\n", emitOperand(c.Dst), classOf(c.Dst), inst, emitOperand(c.Src)); err != nil {
+		return err
+	}
+	return emitExtension(w, c.Dst)
+}
+
+func emitBinary(w io.Writer, b *ttir.Binary) error {
+	t := b.Lhs.ValueType()
+	// Floats have no signed and unsigned operations
+	signed := types.IsSigned(t) || types.IsFloat(t)
+	c := class(t)
+
+	var inst string
+	switch b.Operator {
+	case ast.Add:
+		inst = "add"
+	case ast.Subtract:
+		inst = "sub"
+	case ast.Multiply:
+		inst = "mul"
+	case ast.Divide:
+		inst = "div"
+		if !signed {
+			inst = "udiv"
+		}
+	case ast.Modulo:
+		inst = "rem"
+		if !signed {
+			inst = "urem"
+		}
+	case ast.BitwiseAnd:
+		inst = "and"
+	case ast.BitwiseOr:
+		inst = "or"
+	case ast.BitwiseXor:
+		inst = "xor"
+	case ast.ShiftLeft:
+		inst = "shl"
+	case ast.ShiftRight:
+		inst = "sar"
+		if !signed {
+			inst = "shr"
+		}
+	case ast.ShiftRightLogical:
+		inst = "shr"
+	case ast.Equal:
+		inst = "ceq" + c
+	case ast.NotEqual:
+		inst = "cne" + c
+	case ast.GreaterThan:
+		inst = "csgt" + c
+		if !signed {
+			inst = "cugt" + c
+		}
+	case ast.GreaterThanEqual:
+		inst = "csge" + c
+		if !signed {
+			inst = "cuge" + c
+		}
+	case ast.LessThan:
+		inst = "cslt" + c
+		if !signed {
+			inst = "cult" + c
+		}
+	case ast.LessThanEqual:
+		inst = "csle" + c
+		if !signed {
+			inst = "cule" + c
 		}
+	}
+	if types.IsFloat(t) && b.Operator.IsBooleanOperator() {
+		// The float comparisons have no sign in their name, "csltd" is "cltd"
+		inst = strings.Replace(inst, "cs", "c", 1)
+	}
 
-		b.WriteString(")\n")
-		return emit(w, b.String())
-	default:
-		panic("unkown instruction")
+	if b.Operator.IsBooleanOperator() {
+		return emitf(w, "\t%s =%s %s %s, %s\n", emitOperand(b.Dst), classOf(b.Dst), inst, emitOperand(b.Lhs), emitOperand(b.Rhs))
 	}
 
-	return nil
+	lhs := emitOperand(b.Lhs)
+	if b.Operator == ast.ShiftRightLogical && signed && extension(t) != "" {
+		// The sign extended upper bits would be shifted in, so only use the actual bits of the value
+		zeroExtend := "extub"
+		if t.Size() == 2 {
+			zeroExtend = "extuh"
+		}
+		if err := emitf(w, "\t%s =w %s %s\n", emitOperand(b.Dst), zeroExtend, lhs); err != nil {
+			return err
+		}
+		lhs = emitOperand(b.Dst)
+	}
+
+	if err := emitf(w, "\t%s =%s %s %s, %s\n", emitOperand(b.Dst), c, inst, lhs, emitOperand(b.Rhs)); err != nil {
+		return err
+	}
+	return emitExtension(w, b.Dst)
 }
@@ -8,6 +8,7 @@ section ".text" executable
     public syscall1
     public syscall2
     public syscall3
+    public tt.panic
     ; rdi => Syscall number, rsi => argument
 syscall1:
     mov rax, rdi
@@ -30,3 +31,28 @@ syscall3:
     mov rdx, rcx
     syscall
     ret
+
+    ; rdi, rsi => Location, rdx, rcx => Message
+    ; Writes the location, the message and a newline to stderr and exits with the panic error code
+tt.panic:
+    mov r12, rdx
+    mov r13, rcx
+    mov rdx, rsi
+    mov rsi, rdi
+    mov rdi, 2
+    mov rax, 1
+    syscall
+    mov rsi, r12
+    mov rdx, r13
+    mov rdi, 2
+    mov rax, 1
+    syscall
+    push 10
+    mov rsi, rsp
+    mov rdx, 1
+    mov rdi, 2
+    mov rax, 1
+    syscall
+    mov rdi, 102
+    mov rax, 60
+    syscall
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/token"
@@ -25,6 +26,9 @@ type Expression interface {
 
 type Program struct {
 	Declarations []Declaration
+	// The file and the source code the program was parsed from, errors use them to show the line
+	File   string
+	Source string
 }
 
 func (p *Program) TokenLiteral() string {
@@ -52,19 +56,89 @@ func (p *Program) String() string {
 	return builder.String()
 }
 
+// A file of the program, every file is a module
+type Module struct {
+	// The path of the module from the directory of the main module, for "a/b.tt" it is ["a", "b"], the main module has an empty path
+	Path    []string
+	Program *Program
+}
+
+// Names of other modules and types in them are qualified with the name of the module, like "module.name"
 type Type string
 
+// Returns the type of a function value, "fn(T1,T2): R"
+func FunctionType(parameters []Type, returnType Type) Type {
+	params := []string{}
+	for _, param := range parameters {
+		params = append(params, string(param))
+	}
+	return Type("fn(" + strings.Join(params, ",") + "): " + string(returnType))
+}
+
+// Splits the type of a function value into the types of its parameters and its return type
+func SplitFunctionType(t Type) ([]Type, Type, bool) {
+	s, ok := strings.CutPrefix(string(t), "fn(")
+	if !ok {
+		return nil, "", false
+	}
+
+	// Function types can contain other function types, only the commas outside of their parentheses separate the parameters
+	parameters := []Type{}
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ',':
+			if depth == 0 {
+				parameters = append(parameters, Type(s[start:i]))
+				start = i + 1
+			}
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if i > start {
+				parameters = append(parameters, Type(s[start:i]))
+			}
+			returnType, ok := strings.CutPrefix(s[i+1:], ": ")
+			return parameters, Type(returnType), ok
+		}
+	}
+	return nil, "", false
+}
+
 type Parameter struct {
-	Name string
-	Type Type
+	Token token.Token // The name of the parameter
+	Name  string
+	Type  Type
 }
 
 type FunctionDeclaration struct {
-	Token      token.Token // The token.FN
-	Body       Expression
-	Name       string
-	Parameters []Parameter
-	ReturnType Type
+	Token token.Token // The token.FN
+	Body  Expression
+	Name  string
+	// The names of the type parameters of a generic function, they can be used as types in the function
+	TypeParameters []string
+	Parameters     []Parameter
+	ReturnType     Type
+	// Public declarations can be used by other modules
+	Public bool
+}
+
+func typeParametersString(typeParams []string) string {
+	if len(typeParams) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(typeParams, ", ") + "]"
+}
+
+func publicString(public bool) string {
+	if public {
+		return "pub "
+	}
+	return ""
 }
 
 func ParamsToString(args []Parameter) string {
@@ -81,7 +155,140 @@ func (fd *FunctionDeclaration) declarationNode()     {}
 func (fd *FunctionDeclaration) TokenLiteral() string { return fd.Token.Literal }
 func (fd *FunctionDeclaration) Tok() token.Token     { return fd.Token }
 func (fd *FunctionDeclaration) String() string {
-	return fmt.Sprintf("fn %v(%v): %v = %v;", fd.Name, ParamsToString(fd.Parameters), fd.ReturnType, fd.Body.String())
+	return fmt.Sprintf("%sfn %v%v(%v): %v = %v;", publicString(fd.Public), fd.Name, typeParametersString(fd.TypeParameters), ParamsToString(fd.Parameters), fd.ReturnType, fd.Body.String())
+}
+
+// A function, which is defined outside of the program, like in a C object file. It keeps its name in every module.
+type ExternFunctionDeclaration struct {
+	Token      token.Token // The token.EXTERN
+	Name       string
+	Parameters []Parameter
+	ReturnType Type
+	Public     bool
+}
+
+func (ed *ExternFunctionDeclaration) declarationNode()     {}
+func (ed *ExternFunctionDeclaration) TokenLiteral() string { return ed.Token.Literal }
+func (ed *ExternFunctionDeclaration) Tok() token.Token     { return ed.Token }
+func (ed *ExternFunctionDeclaration) String() string {
+	return fmt.Sprintf("%sextern fn %v(%v): %v;", publicString(ed.Public), ed.Name, ParamsToString(ed.Parameters), ed.ReturnType)
+}
+
+type ConstDeclaration struct {
+	Token  token.Token // The token.CONST
+	Name   string
+	Type   Type
+	Value  Expression
+	Public bool
+}
+
+func (cd *ConstDeclaration) declarationNode()     {}
+func (cd *ConstDeclaration) TokenLiteral() string { return cd.Token.Literal }
+func (cd *ConstDeclaration) Tok() token.Token     { return cd.Token }
+func (cd *ConstDeclaration) String() string {
+	return fmt.Sprintf("%sconst %v: %v = %v;", publicString(cd.Public), cd.Name, cd.Type, cd.Value.String())
+}
+
+type GlobalDeclaration struct {
+	Token  token.Token // The token.VAR
+	Name   string
+	Type   Type
+	Value  Expression
+	Public bool
+}
+
+func (gd *GlobalDeclaration) declarationNode()     {}
+func (gd *GlobalDeclaration) TokenLiteral() string { return gd.Token.Literal }
+func (gd *GlobalDeclaration) Tok() token.Token     { return gd.Token }
+func (gd *GlobalDeclaration) String() string {
+	return fmt.Sprintf("%svar %v: %v = %v;", publicString(gd.Public), gd.Name, gd.Type, gd.Value.String())
+}
+
+// Declares the name of the module, it has to be the first declaration of the file
+type ModuleDeclaration struct {
+	Token token.Token // The token.MOD
+	Name  string
+}
+
+func (md *ModuleDeclaration) declarationNode()     {}
+func (md *ModuleDeclaration) TokenLiteral() string { return md.Token.Literal }
+func (md *ModuleDeclaration) Tok() token.Token     { return md.Token }
+func (md *ModuleDeclaration) String() string {
+	return fmt.Sprintf("mod %s;", md.Name)
+}
+
+// Makes the public declarations of another module available as "name.declaration", the name is the last part of the path
+type ImportDeclaration struct {
+	Token token.Token // The token.IMPORT
+	Path  []string
+}
+
+func (id *ImportDeclaration) Name() string { return id.Path[len(id.Path)-1] }
+
+func (id *ImportDeclaration) declarationNode()     {}
+func (id *ImportDeclaration) TokenLiteral() string { return id.Token.Literal }
+func (id *ImportDeclaration) Tok() token.Token     { return id.Token }
+func (id *ImportDeclaration) String() string {
+	return fmt.Sprintf("import %s;", strings.Join(id.Path, "."))
+}
+
+type StructField struct {
+	Name string
+	Type Type
+}
+
+type StructDeclaration struct {
+	Token  token.Token // The token.STRUCT
+	Name   string
+	Fields []StructField
+	Public bool
+}
+
+func (sd *StructDeclaration) declarationNode()     {}
+func (sd *StructDeclaration) TokenLiteral() string { return sd.Token.Literal }
+func (sd *StructDeclaration) Tok() token.Token     { return sd.Token }
+func (sd *StructDeclaration) String() string {
+	var b strings.Builder
+
+	for _, field := range sd.Fields {
+		b.WriteString(fmt.Sprintf(" %s: %s,", field.Name, field.Type))
+	}
+
+	return fmt.Sprintf("%sstruct %s {%s }", publicString(sd.Public), sd.Name, b.String())
+}
+
+type EnumVariant struct {
+	Name string
+	// The types of the payload, empty if the variant has none
+	Fields []Type
+}
+
+type EnumDeclaration struct {
+	Token    token.Token // The token.ENUM
+	Name     string
+	Variants []EnumVariant
+	Public   bool
+}
+
+func (ed *EnumDeclaration) declarationNode()     {}
+func (ed *EnumDeclaration) TokenLiteral() string { return ed.Token.Literal }
+func (ed *EnumDeclaration) Tok() token.Token     { return ed.Token }
+func (ed *EnumDeclaration) String() string {
+	var b strings.Builder
+
+	for _, variant := range ed.Variants {
+		b.WriteString(" " + variant.Name)
+		if len(variant.Fields) > 0 {
+			fields := []string{}
+			for _, field := range variant.Fields {
+				fields = append(fields, string(field))
+			}
+			b.WriteString("(" + strings.Join(fields, ", ") + ")")
+		}
+		b.WriteRune(',')
+	}
+
+	return fmt.Sprintf("%senum %s {%s }", publicString(ed.Public), ed.Name, b.String())
 }
 
 // Represents a Expression that we failed to parse
@@ -97,6 +304,8 @@ func (e *ErrorExpression) String() string       { return "<ERROR EXPR>" }
 type IntegerExpression struct {
 	Token token.Token // The token.INT
 	Value int64
+	// The suffix of the literal like u8, empty if it has none
+	Type Type
 }
 
 func (ie *IntegerExpression) expressionNode()      {}
@@ -104,6 +313,27 @@ func (ie *IntegerExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IntegerExpression) Tok() token.Token     { return ie.Token }
 func (ie *IntegerExpression) String() string       { return ie.Token.Literal }
 
+type FloatExpression struct {
+	Token token.Token // The token.FLOAT
+	Value float64
+}
+
+func (fe *FloatExpression) expressionNode()      {}
+func (fe *FloatExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *FloatExpression) Tok() token.Token     { return fe.Token }
+func (fe *FloatExpression) String() string       { return fe.Token.Literal }
+
+type StringExpression struct {
+	Token token.Token // The token.STRING
+	// The content of the string with the escape sequences replaced
+	Value string
+}
+
+func (se *StringExpression) expressionNode()      {}
+func (se *StringExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *StringExpression) Tok() token.Token     { return se.Token }
+func (se *StringExpression) String() string       { return strconv.Quote(se.Value) }
+
 type BooleanExpression struct {
 	Token token.Token // The token.TRUE or token.FALSE
 	Value bool
@@ -114,6 +344,81 @@ func (be *BooleanExpression) TokenLiteral() string { return be.Token.Literal }
 func (be *BooleanExpression) Tok() token.Token     { return be.Token }
 func (be *BooleanExpression) String() string       { return be.Token.Literal }
 
+//go:generate stringer -type=UnaryOperator
+type UnaryOperator int
+
+const (
+	Negate UnaryOperator = iota
+	Not
+	Complement
+)
+
+func (uo UnaryOperator) SymbolString() string {
+	switch uo {
+	case Negate:
+		return "-"
+	case Not:
+		return "!"
+	case Complement:
+		return "~"
+	}
+	return "<INVALID UNARY OPERATOR>"
+}
+
+type UnaryExpression struct {
+	Token    token.Token // The operator
+	Operand  Expression
+	Operator UnaryOperator
+}
+
+func (ue *UnaryExpression) expressionNode()      {}
+func (ue *UnaryExpression) TokenLiteral() string { return ue.Token.Literal }
+func (ue *UnaryExpression) Tok() token.Token     { return ue.Token }
+func (ue *UnaryExpression) String() string {
+	return fmt.Sprintf("(%s%s)", ue.Operator.SymbolString(), ue.Operand)
+}
+
+type AddressOfExpression struct {
+	Token      token.Token // The '&' token
+	Expression Expression
+	// Set by the variable resolution, if the expression is a part of a variable declared without mut.
+	// The typechecker decides with the types, if the pointer really points into the variable.
+	Immutable   bool
+	Declaration token.Token // The declaration of the immutable variable
+}
+
+func (ae *AddressOfExpression) expressionNode()      {}
+func (ae *AddressOfExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AddressOfExpression) Tok() token.Token     { return ae.Token }
+func (ae *AddressOfExpression) String() string {
+	return fmt.Sprintf("(&%s)", ae.Expression)
+}
+
+type DereferenceExpression struct {
+	Token      token.Token // The '*' token
+	Expression Expression
+}
+
+func (de *DereferenceExpression) expressionNode()      {}
+func (de *DereferenceExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DereferenceExpression) Tok() token.Token     { return de.Token }
+func (de *DereferenceExpression) String() string {
+	return fmt.Sprintf("(*%s)", de.Expression)
+}
+
+type CastExpression struct {
+	Token      token.Token // The 'as' token
+	Expression Expression
+	Type       Type
+}
+
+func (ce *CastExpression) expressionNode()      {}
+func (ce *CastExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CastExpression) Tok() token.Token     { return ce.Token }
+func (ce *CastExpression) String() string {
+	return fmt.Sprintf("(%s as %s)", ce.Expression, ce.Type)
+}
+
 //go:generate stringer -type=BinaryOperator
 type BinaryOperator int
 
@@ -128,10 +433,26 @@ const (
 	LessThanEqual
 	GreaterThan
 	GreaterThanEqual
+	LogicalAnd
+	LogicalOr
+	Modulo
+	BitwiseAnd
+	BitwiseOr
+	BitwiseXor
+	ShiftLeft
+	// Arithmetic shift, keeps the sign
+	ShiftRight
+	// Logical shift, fills with zeros
+	ShiftRightLogical
 )
 
 func (bo BinaryOperator) IsBooleanOperator() bool {
-	return bo == Equal || bo == NotEqual || bo == LessThan || bo == LessThanEqual || bo == GreaterThan || bo == GreaterThanEqual
+	return bo == Equal || bo == NotEqual || bo == LessThan || bo == LessThanEqual || bo == GreaterThan || bo == GreaterThanEqual || bo.IsLogicalOperator()
+}
+
+// Logical operators only evaluate the rhs if the lhs does not decide the result
+func (bo BinaryOperator) IsLogicalOperator() bool {
+	return bo == LogicalAnd || bo == LogicalOr
 }
 
 func (bo BinaryOperator) SymbolString() string {
@@ -156,6 +477,24 @@ func (bo BinaryOperator) SymbolString() string {
 		return ">"
 	case GreaterThanEqual:
 		return ">="
+	case LogicalAnd:
+		return "&&"
+	case LogicalOr:
+		return "||"
+	case Modulo:
+		return "%"
+	case BitwiseAnd:
+		return "&"
+	case BitwiseOr:
+		return "|"
+	case BitwiseXor:
+		return "^"
+	case ShiftLeft:
+		return "<<"
+	case ShiftRight:
+		return ">>"
+	case ShiftRightLogical:
+		return ">>>"
 	}
 	return "<INVALID BINARY OPERATOR>"
 }
@@ -233,12 +572,17 @@ type VariableDeclaration struct {
 	InitializingExpression Expression
 	Type                   Type
 	Identifier             string
+	// Only variables declared with mut can be assigned to
+	Mutable bool
 }
 
 func (vd *VariableDeclaration) expressionNode()      {}
 func (vd *VariableDeclaration) TokenLiteral() string { return vd.Token.Literal }
 func (vd *VariableDeclaration) Tok() token.Token     { return vd.Token }
 func (vd *VariableDeclaration) String() string {
+	if vd.Mutable {
+		return fmt.Sprintf("mut %s : %v = %s", vd.Identifier, vd.Type, vd.InitializingExpression)
+	}
 	return fmt.Sprintf("%s : %v = %s", vd.Identifier, vd.Type, vd.InitializingExpression)
 }
 
@@ -255,15 +599,25 @@ func (vr *VariableReference) String() string {
 }
 
 type AssignmentExpression struct {
-	Token token.Token // The Equal
+	Token token.Token // The Equal or the compound operator like +=
 	Lhs   Expression
 	Rhs   Expression
+	// Set for compound assignments, "a += b" assigns "a + b" but evaluates a only once
+	Compound bool
+	Operator BinaryOperator
+	// Set by the variable resolution, if the lhs is a part of a variable declared without mut.
+	// The typechecker decides with the types, if the assignment really changes the variable.
+	Immutable   bool
+	Declaration token.Token // The declaration of the immutable variable
 }
 
 func (ae *AssignmentExpression) expressionNode()      {}
 func (ae *AssignmentExpression) TokenLiteral() string { return ae.Token.Literal }
 func (ae *AssignmentExpression) Tok() token.Token     { return ae.Token }
 func (ae *AssignmentExpression) String() string {
+	if ae.Compound {
+		return fmt.Sprintf("%s %s= %s", ae.Lhs.String(), ae.Operator.SymbolString(), ae.Rhs.String())
+	}
 	return fmt.Sprintf("%s = %s", ae.Lhs.String(), ae.Rhs.String())
 }
 
@@ -294,3 +648,270 @@ func (fc *FunctionCall) String() string {
 
 	return b.String()
 }
+
+// A call of a function value, which is not called by its name, like "make()(1)"
+type CallExpression struct {
+	Token     token.Token // The '(' token
+	Function  Expression
+	Arguments []Expression
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Tok() token.Token     { return ce.Token }
+func (ce *CallExpression) String() string {
+	args := []string{}
+	for _, arg := range ce.Arguments {
+		args = append(args, arg.String())
+	}
+	return fmt.Sprintf("(%s)(%s)", ce.Function, strings.Join(args, ","))
+}
+
+// An anonymous function, which can use the variables around it
+type ClosureExpression struct {
+	Token      token.Token // The 'fn' token
+	Parameters []Parameter
+	ReturnType Type
+	Body       Expression
+	// The variables of the surrounding functions, which the closure uses, they are set by the variable resolution
+	Captures []string
+}
+
+func (ce *ClosureExpression) expressionNode()      {}
+func (ce *ClosureExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ClosureExpression) Tok() token.Token     { return ce.Token }
+func (ce *ClosureExpression) String() string {
+	return fmt.Sprintf("fn(%v): %v = %v", ParamsToString(ce.Parameters), ce.ReturnType, ce.Body)
+}
+
+type WhileExpression struct {
+	Token     token.Token // The 'while' token
+	Condition Expression
+	Body      Expression
+	// NOTE: Can be nil
+	//
+	// Evaluated when the condition becomes false, a loop can only break with a value if it has an else
+	Else Expression
+}
+
+func (we *WhileExpression) expressionNode()      {}
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WhileExpression) Tok() token.Token     { return we.Token }
+func (we *WhileExpression) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("(while %s\n\t", we.Condition.String()))
+	builder.WriteString(we.Body.String())
+
+	if we.Else != nil {
+		builder.WriteString(" else in ")
+		builder.WriteString(we.Else.String())
+	}
+	builder.WriteString(")")
+
+	return builder.String()
+}
+
+type BreakExpression struct {
+	Token token.Token // The 'break' token
+	// NOTE: Nullable
+	Value Expression
+}
+
+func (be *BreakExpression) expressionNode()      {}
+func (be *BreakExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BreakExpression) Tok() token.Token     { return be.Token }
+func (be *BreakExpression) String() string {
+	if be.Value != nil {
+		return fmt.Sprintf("break %s", be.Value.String())
+	}
+	return "break"
+}
+
+type ContinueExpression struct {
+	Token token.Token // The 'continue' token
+}
+
+func (ce *ContinueExpression) expressionNode()      {}
+func (ce *ContinueExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ContinueExpression) Tok() token.Token     { return ce.Token }
+func (ce *ContinueExpression) String() string       { return "continue" }
+
+type ReturnExpression struct {
+	Token token.Token // The 'return' token
+	// NOTE: Nullable
+	Value Expression
+}
+
+func (re *ReturnExpression) expressionNode()      {}
+func (re *ReturnExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *ReturnExpression) Tok() token.Token     { return re.Token }
+func (re *ReturnExpression) String() string {
+	if re.Value != nil {
+		return fmt.Sprintf("return %s", re.Value.String())
+	}
+	return "return"
+}
+
+// for identifier in start..end { ... }
+type ForExpression struct {
+	Token      token.Token // The 'for' token
+	Identifier string
+	Start      Expression
+	End        Expression
+	// If the range includes the end, written as '..='
+	Inclusive bool
+	Body      Expression
+}
+
+func (fe *ForExpression) expressionNode()      {}
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForExpression) Tok() token.Token     { return fe.Token }
+func (fe *ForExpression) String() string {
+	rangeOp := ".."
+	if fe.Inclusive {
+		rangeOp = "..="
+	}
+	return fmt.Sprintf("(for %s in %s%s%s\n\t%s)", fe.Identifier, fe.Start, rangeOp, fe.End, fe.Body)
+}
+
+type StructExpressionField struct {
+	Token token.Token // The name of the field
+	Name  string
+	Value Expression
+}
+
+type StructExpression struct {
+	Token  token.Token // The name of the struct
+	Name   string
+	Fields []StructExpressionField
+}
+
+func (se *StructExpression) expressionNode()      {}
+func (se *StructExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *StructExpression) Tok() token.Token     { return se.Token }
+func (se *StructExpression) String() string {
+	var b strings.Builder
+
+	for _, field := range se.Fields {
+		b.WriteString(fmt.Sprintf(" %s: %s,", field.Name, field.Value))
+	}
+
+	return fmt.Sprintf("%s {%s }", se.Name, b.String())
+}
+
+type EnumExpression struct {
+	Token     token.Token // The name of the enum
+	Enum      string
+	Variant   string
+	Arguments []Expression
+}
+
+func (ee *EnumExpression) expressionNode()      {}
+func (ee *EnumExpression) TokenLiteral() string { return ee.Token.Literal }
+func (ee *EnumExpression) Tok() token.Token     { return ee.Token }
+func (ee *EnumExpression) String() string {
+	args := []string{}
+	for _, arg := range ee.Arguments {
+		args = append(args, arg.String())
+	}
+	return fmt.Sprintf("%s::%s(%s)", ee.Enum, ee.Variant, strings.Join(args, ", "))
+}
+
+type MatchArm struct {
+	Token token.Token // The name of the variant
+	// The wildcard "_" matches every variant
+	Variant string
+	// The names for the payload of the variant, "_" ignores a value
+	Bindings []string
+	Body     Expression
+}
+
+type MatchExpression struct {
+	Token      token.Token // The 'match' token
+	Expression Expression
+	Arms       []MatchArm
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Tok() token.Token     { return me.Token }
+func (me *MatchExpression) String() string {
+	var b strings.Builder
+
+	for _, arm := range me.Arms {
+		b.WriteString("\n\t" + arm.Variant)
+		if len(arm.Bindings) > 0 {
+			b.WriteString("(" + strings.Join(arm.Bindings, ", ") + ")")
+		}
+		b.WriteString(" => " + arm.Body.String() + ",")
+	}
+
+	return fmt.Sprintf("(match %s {%s\n})", me.Expression, b.String())
+}
+
+type FieldAccessExpression struct {
+	Token      token.Token // The '.' token
+	Expression Expression
+	Field      string
+}
+
+func (fae *FieldAccessExpression) expressionNode()      {}
+func (fae *FieldAccessExpression) TokenLiteral() string { return fae.Token.Literal }
+func (fae *FieldAccessExpression) Tok() token.Token     { return fae.Token }
+func (fae *FieldAccessExpression) String() string {
+	return fmt.Sprintf("%s.%s", fae.Expression, fae.Field)
+}
+
+type ArrayExpression struct {
+	Token    token.Token // The '[' token
+	Elements []Expression
+}
+
+func (ae *ArrayExpression) expressionNode()      {}
+func (ae *ArrayExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *ArrayExpression) Tok() token.Token     { return ae.Token }
+func (ae *ArrayExpression) String() string {
+	elements := []string{}
+	for _, e := range ae.Elements {
+		elements = append(elements, e.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+}
+
+type IndexExpression struct {
+	Token      token.Token // The '[' token
+	Expression Expression
+	Index      Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Tok() token.Token     { return ie.Token }
+func (ie *IndexExpression) String() string {
+	return fmt.Sprintf("%s[%s]", ie.Expression, ie.Index)
+}
+
+// Creates a slice from Start to End, excluding End
+type SliceExpression struct {
+	Token      token.Token // The '[' token
+	Expression Expression
+	// Nullable, the slice starts at 0 if it is not set
+	Start Expression
+	// Nullable, the slice ends at the length of the expression if it is not set
+	End Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Tok() token.Token     { return se.Token }
+func (se *SliceExpression) String() string {
+	var start, end string
+	if se.Start != nil {
+		start = se.Start.String()
+	}
+	if se.End != nil {
+		end = se.End.String()
+	}
+	return fmt.Sprintf("%s[%s..%s]", se.Expression, start, end)
+}
@@ -18,11 +18,20 @@ func _() {
 	_ = x[LessThanEqual-7]
 	_ = x[GreaterThan-8]
 	_ = x[GreaterThanEqual-9]
+	_ = x[LogicalAnd-10]
+	_ = x[LogicalOr-11]
+	_ = x[Modulo-12]
+	_ = x[BitwiseAnd-13]
+	_ = x[BitwiseOr-14]
+	_ = x[BitwiseXor-15]
+	_ = x[ShiftLeft-16]
+	_ = x[ShiftRight-17]
+	_ = x[ShiftRightLogical-18]
 }
 
-const _BinaryOperator_name = "AddSubtractMultiplyDivideEqualNotEqualLessThanLessThanEqualGreaterThanGreaterThanEqual"
+const _BinaryOperator_name = "AddSubtractMultiplyDivideEqualNotEqualLessThanLessThanEqualGreaterThanGreaterThanEqualLogicalAndLogicalOrModuloBitwiseAndBitwiseOrBitwiseXorShiftLeftShiftRightShiftRightLogical"
 
-var _BinaryOperator_index = [...]uint8{0, 3, 11, 19, 25, 30, 38, 46, 59, 70, 86}
+var _BinaryOperator_index = [...]uint8{0, 3, 11, 19, 25, 30, 38, 46, 59, 70, 86, 96, 105, 111, 121, 130, 140, 149, 159, 176}
 
 func (i BinaryOperator) String() string {
 	if i < 0 || i >= BinaryOperator(len(_BinaryOperator_index)-1) {
@@ -29,7 +29,7 @@ type SourceProgram struct {
 	// This file could be extended by different backends
 	// .asm is for fasm, .S for gas
 	InputAssemblies []string
-	// Additional object files, will also include the generated object files
+	// Additional object files, which are linked with the program
 	ObjectFiles []string
 	// The linked executable
 	OutputFile string
@@ -105,18 +105,61 @@ func (sp *SourceProgram) buildFasm(addRootNode func(task) int, addNode func(task
 	}))
 
 	if !emitAsmOnly {
-		task := NewProcessTask(fasmPath, mainAsmOutput, sp.OutputFile)
+		// Only linking needs ld, the assembly can be emitted without it
+		ldPath, err := exec.LookPath("ld")
+		if err != nil {
+			return fmt.Errorf("could not find the system `ld` linker, please install it using your systems package manager")
+		}
+
+		mainObject := strings.TrimSuffix(mainAsmOutput, filepath.Ext(mainAsmOutput)) + ".o"
+		task := NewProcessTask(fasmPath, mainAsmOutput, mainObject)
 		task.WithName("assembling " + mainAsmOutput)
 		fasmTask := addNode(task, asmFile)
 
+		inputTasks, inputObjects, err := sp.assembleInputs(addRootNode, fasmPath)
+		if err != nil {
+			return err
+		}
+		// The object files, which are generated and removed after linking, the object files of the user are kept
+		generatedObjects := append([]string{mainObject}, inputObjects...)
+
+		ldTask := NewProcessTask(ldPath, append(append([]string{"-o", sp.OutputFile}, generatedObjects...), sp.ObjectFiles...)...)
+		ldTaskId := addNode(ldTask, append([]int{fasmTask}, inputTasks...)...)
+
 		// Cleanup
 
-		addNode(NewRemoveFileTask(mainAsmOutput), fasmTask)
+		for _, object := range generatedObjects {
+			addNode(NewRemoveFileTask(object), ldTaskId)
+		}
+		addNode(NewRemoveFileTask(mainAsmOutput), ldTaskId)
 	}
 
 	return nil
 }
 
+// Adds the tasks, which assemble the additional assembly files, and returns them and the object files they generate
+func (sp *SourceProgram) assembleInputs(addRootNode func(task) int, fasmPath string) (tasks []int, objects []string, err error) {
+	for _, asmFile := range sp.InputAssemblies {
+		outputFile := strings.TrimSuffix(asmFile, filepath.Ext(asmFile)) + ".o"
+
+		if filepath.Ext(asmFile) == ".asm" {
+			tasks = append(tasks, addRootNode(NewProcessTask(fasmPath, asmFile, outputFile)))
+		} else if filepath.Ext(asmFile) == ".S" {
+			asPath, err := exec.LookPath("as")
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not find the system `as` assembler, please install it using your systems package manager")
+			}
+			tasks = append(tasks, addRootNode(NewProcessTask(asPath, asmFile, "-o", outputFile)))
+		} else {
+			panic(fmt.Sprintf("unkown asm file extension %q", filepath.Ext(asmFile)))
+		}
+
+		objects = append(objects, outputFile)
+	}
+
+	return tasks, objects, nil
+}
+
 func (sp *SourceProgram) buildQbe(addRootNode func(task) int, addNode func(task, ...int) int, emitAsmOnly bool, toPrint ToPrintFlags) error {
 	fasmPath, err := exec.LookPath("fasm")
 	if err != nil {
@@ -149,38 +192,36 @@ func (sp *SourceProgram) buildQbe(addRootNode func(task) int, addNode func(task,
 	if !emitAsmOnly {
 
 		objectFileTasks := []int{}
+		// The object files, which are generated and removed after linking, the object files of the user are kept
+		generatedObjects := []string{}
 		qbeStubAsm := "qbe_stub.asm"
 		qbeStubO := "qbe_stub.o"
 		generatedAsmFile := addRootNode(NewCreateFileTask(qbeStubAsm, qbe.Stub))
 		id := addNode(NewProcessTask(fasmPath, qbeStubAsm, qbeStubO), generatedAsmFile)
 		objectFileTasks = append(objectFileTasks, id)
-		sp.ObjectFiles = append(sp.ObjectFiles, qbeStubO)
+		generatedObjects = append(generatedObjects, qbeStubO)
 
 		qbeOutput := strings.TrimSuffix(mainAsmOutput, filepath.Ext(mainAsmOutput)) + ".S"
 		task := NewProcessTask(qbePath, mainAsmOutput, "-o", qbeOutput)
 		task.WithName("running qbe on " + mainAsmOutput)
 		qbeTask := addNode(task, asmFile)
-		sp.InputAssemblies = append(sp.InputAssemblies, qbeOutput)
-
-		for _, asmFile := range sp.InputAssemblies {
-			outputFile := strings.TrimSuffix(asmFile, filepath.Ext(asmFile)) + ".o"
-
-			if filepath.Ext(asmFile) == ".asm" {
-				id := addRootNode(NewProcessTask(fasmPath, asmFile, outputFile))
-				objectFileTasks = append(objectFileTasks, id)
-			} else if filepath.Ext(asmFile) == ".S" {
-				id := addRootNode(NewProcessTask(asPath, asmFile, "-o", outputFile))
-				objectFileTasks = append(objectFileTasks, id)
-			} else {
-				panic(fmt.Sprintf("unkown asm file extension %q", filepath.Ext(asmFile)))
-			}
 
-			sp.ObjectFiles = append(sp.ObjectFiles, outputFile)
+		qbeObject := strings.TrimSuffix(qbeOutput, filepath.Ext(qbeOutput)) + ".o"
+		id = addNode(NewProcessTask(asPath, qbeOutput, "-o", qbeObject), qbeTask)
+		objectFileTasks = append(objectFileTasks, id)
+		generatedObjects = append(generatedObjects, qbeObject)
+
+		inputTasks, inputObjects, err := sp.assembleInputs(addRootNode, fasmPath)
+		if err != nil {
+			return err
 		}
-		ldTask := NewProcessTask(ldPath, append([]string{"-o", sp.OutputFile}, sp.ObjectFiles...)...)
+		objectFileTasks = append(objectFileTasks, inputTasks...)
+		generatedObjects = append(generatedObjects, inputObjects...)
+
+		ldTask := NewProcessTask(ldPath, append(append([]string{"-o", sp.OutputFile}, generatedObjects...), sp.ObjectFiles...)...)
 		ldTaskId := addNode(ldTask, append([]int{generatedAsmFile}, objectFileTasks...)...)
 
-		for _, object := range sp.ObjectFiles {
+		for _, object := range generatedObjects {
 			// Cleanup object files
 			addNode(NewRemoveFileTask(object), ldTaskId)
 		}
@@ -0,0 +1,116 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"robaertschi.xyz/robaertschi/tt/ast"
+	"robaertschi.xyz/robaertschi/tt/lexer"
+	"robaertschi.xyz/robaertschi/tt/parser"
+	"robaertschi.xyz/robaertschi/tt/token"
+)
+
+// The extension of tt source files
+const sourceExtension = ".tt"
+
+func parseFile(input string) (*ast.Program, error) {
+	file, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file %q because: %v", input, err)
+	}
+	defer file.Close()
+
+	inputText, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read file %q because: %v", input, err)
+	}
+
+	l, err := lexer.New(string(inputText), input)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating lexer: %v", err)
+	}
+
+	l.WithErrorCallback(func(l token.Loc, s string, a ...any) {
+		fmt.Printf("%s:%d:%d: %s\n", l.File, l.Line, l.Col, fmt.Sprintf(s, a...))
+	})
+
+	p := parser.New(l)
+	p.WithErrorCallback(func(t token.Token, s string, a ...any) {
+		loc := t.Loc
+		fmt.Printf("%s:%d:%d: %s\n", loc.File, loc.Line, loc.Col, fmt.Sprintf(s, a...))
+	})
+
+	program := p.ParseProgram()
+	if p.Errors() > 0 {
+		return nil, fmt.Errorf("parser encountered 1 or more errors")
+	}
+	return program, nil
+}
+
+// Returns the file of a module, the module a.b is the file "a/b.tt" in the directory of the main module
+func modulePath(root string, path []string) string {
+	return filepath.Join(root, filepath.Join(path...)) + sourceExtension
+}
+
+// Parses the main module and every module it imports directly or indirectly, the main module is the first module
+func loadModules(input string) ([]*ast.Module, error) {
+	program, err := parseFile(input)
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.Dir(input)
+	modules := []*ast.Module{{Program: program}}
+	loaded := make(map[string]bool)
+
+	for i := 0; i < len(modules); i++ {
+		for _, decl := range modules[i].Program.Declarations {
+			decl, ok := decl.(*ast.ImportDeclaration)
+			if !ok {
+				continue
+			}
+
+			name := strings.Join(decl.Path, ".")
+			if loaded[name] {
+				continue
+			}
+			loaded[name] = true
+
+			file := modulePath(root, decl.Path)
+			if _, err := os.Stat(file); err != nil {
+				loc := decl.Token.Loc
+				return nil, fmt.Errorf("%s:%d:%d the module %q was not found, expected it in the file %q", loc.File, loc.Line, loc.Col, name, file)
+			}
+
+			program, err := parseFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkModuleDeclaration(file, decl.Name(), program); err != nil {
+				return nil, err
+			}
+
+			modules = append(modules, &ast.Module{Path: decl.Path, Program: program})
+		}
+	}
+
+	return modules, nil
+}
+
+// Checks that an imported module starts with "mod name;", where name is the name of the file
+func checkModuleDeclaration(file string, name string, program *ast.Program) error {
+	if len(program.Declarations) > 0 {
+		if decl, ok := program.Declarations[0].(*ast.ModuleDeclaration); ok {
+			if decl.Name == name {
+				return nil
+			}
+			loc := decl.Token.Loc
+			return fmt.Errorf("%s:%d:%d the module is declared as %q, but its file is called %q", loc.File, loc.Line, loc.Col, decl.Name, name+sourceExtension)
+		}
+	}
+
+	return fmt.Errorf("%s: the module has to start with the declaration \"mod %s;\"", file, name)
+}
@@ -13,9 +13,6 @@ import (
 	"robaertschi.xyz/robaertschi/tt/asm"
 	"robaertschi.xyz/robaertschi/tt/asm/amd64"
 	"robaertschi.xyz/robaertschi/tt/asm/qbe"
-	"robaertschi.xyz/robaertschi/tt/lexer"
-	"robaertschi.xyz/robaertschi/tt/parser"
-	"robaertschi.xyz/robaertschi/tt/token"
 	"robaertschi.xyz/robaertschi/tt/ttir"
 	"robaertschi.xyz/robaertschi/tt/typechecker"
 	"robaertschi.xyz/robaertschi/tt/utils"
@@ -154,42 +151,18 @@ func build(outputWriter io.Writer, input string, output string, toPrint ToPrintF
 		}
 	}()
 
-	file, err := os.Open(input)
+	modules, err := loadModules(input)
 	if err != nil {
-		return fmt.Errorf("could not open file %q because: %v", input, err)
-	}
-	defer file.Close()
-
-	inputText, err := io.ReadAll(file)
-	if err != nil {
-		return fmt.Errorf("Could not read file %q because: %v", input, err)
-	}
-
-	l, err := lexer.New(string(inputText), input)
-	if err != nil {
-		return fmt.Errorf("error while creating lexer: %v", err)
-	}
-
-	l.WithErrorCallback(func(l token.Loc, s string, a ...any) {
-		fmt.Printf("%s:%d:%d: %s\n", l.File, l.Line, l.Col, fmt.Sprintf(s, a...))
-	})
-
-	p := parser.New(l)
-	p.WithErrorCallback(func(t token.Token, s string, a ...any) {
-		loc := t.Loc
-		fmt.Printf("%s:%d:%d: %s\n", loc.File, loc.Line, loc.Col, fmt.Sprintf(s, a...))
-	})
-
-	program := p.ParseProgram()
-	if p.Errors() > 0 {
-		return fmt.Errorf("parser encountered 1 or more errors")
+		return err
 	}
 	if (toPrint & PrintAst) != 0 {
-		io.WriteString(outputWriter,
-			fmt.Sprintf("AST:\n%s\n%+#v\n", program.String(), program))
+		for _, module := range modules {
+			io.WriteString(outputWriter,
+				fmt.Sprintf("AST:\n%s\n%+#v\n", module.Program.String(), module.Program))
+		}
 	}
 
-	tprogram, err := typechecker.New().CheckProgram(program)
+	tprogram, err := typechecker.New().CheckModules(modules)
 	if err != nil {
 		return err
 	}
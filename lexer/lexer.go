@@ -3,6 +3,7 @@ package lexer
 import (
 	"fmt"
 	"iter"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -34,6 +35,11 @@ func New(input string, file string) (*Lexer, error) {
 	return l, nil
 }
 
+// Returns the file name and the source code the lexer reads
+func (l *Lexer) Source() (string, string) {
+	return l.file, l.input
+}
+
 func (l *Lexer) Iter() iter.Seq[token.Token] {
 	return func(yield func(token.Token) bool) {
 		for {
@@ -68,7 +74,19 @@ func (l *Lexer) NextToken() token.Token {
 	case ';':
 		tok = l.newToken(token.Semicolon)
 	case ':':
+		if l.peekByte() == ':' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.DoubleColon
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
 		tok = l.newToken(token.Colon)
+	case '[':
+		tok = l.newToken(token.OpenSquare)
+	case ']':
+		tok = l.newToken(token.CloseSquare)
 	case '=':
 		if l.peekByte() == '=' {
 			pos := l.position
@@ -77,6 +95,13 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.DoubleEqual
 			tok.Literal = l.input[pos:l.position]
 			return tok
+		} else if l.peekByte() == '>' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.FatArrow
+			tok.Literal = l.input[pos:l.position]
+			return tok
 		}
 		tok = l.newToken(token.Equal)
 	case '<':
@@ -87,6 +112,13 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LessThanEqual
 			tok.Literal = l.input[pos:l.position]
 			return tok
+		} else if l.peekByte() == '<' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.ShiftLeft
+			tok.Literal = l.input[pos:l.position]
+			return tok
 		}
 		tok = l.newToken(token.LessThan)
 	case '>':
@@ -97,17 +129,67 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.GreaterThanEqual
 			tok.Literal = l.input[pos:l.position]
 			return tok
+		} else if l.peekByte() == '>' {
+			pos := l.position
+			l.readChar()
+			tok.Type = token.ShiftRight
+			if l.peekByte() == '>' {
+				l.readChar()
+				tok.Type = token.TripleGreater
+			}
+			l.readChar()
+			tok.Literal = l.input[pos:l.position]
+			return tok
 		}
 		tok = l.newToken(token.GreaterThan)
+	case '.':
+		if l.peekByte() == '.' {
+			pos := l.position
+			l.readChar()
+			if l.peekByte() == '=' {
+				l.readChar()
+				tok.Type = token.DotDotEqual
+			} else {
+				tok.Type = token.DotDot
+			}
+			l.readChar()
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
+		tok = l.newToken(token.Dot)
 	case '(':
 		tok = l.newToken(token.OpenParen)
 	case ')':
 		tok = l.newToken(token.CloseParen)
 	case '+':
+		if l.peekByte() == '=' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.PlusEqual
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
 		tok = l.newToken(token.Plus)
 	case '-':
+		if l.peekByte() == '=' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.MinusEqual
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
 		tok = l.newToken(token.Minus)
 	case '*':
+		if l.peekByte() == '=' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.AsteriskEqual
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
 		tok = l.newToken(token.Asterisk)
 	case '/':
 		if l.peekByte() == '/' {
@@ -115,6 +197,13 @@ func (l *Lexer) NextToken() token.Token {
 				l.readChar()
 			}
 			return l.NextToken()
+		} else if l.peekByte() == '=' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.SlashEqual
+			tok.Literal = l.input[pos:l.position]
+			return tok
 		}
 		tok = l.newToken(token.Slash)
 	case '{':
@@ -130,16 +219,60 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = l.input[pos:l.position]
 			return tok
 		}
-		tok = l.newToken(token.Illegal)
+		tok = l.newToken(token.Bang)
+	case '~':
+		tok = l.newToken(token.Tilde)
+	case '&':
+		if l.peekByte() == '&' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.DoubleAmpersand
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
+		tok = l.newToken(token.Ampersand)
+	case '|':
+		if l.peekByte() == '|' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.DoublePipe
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
+		if l.peekByte() == '>' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.PipeGreater
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
+		tok = l.newToken(token.Pipe)
+	case '^':
+		tok = l.newToken(token.Caret)
+	case '%':
+		if l.peekByte() == '=' {
+			pos := l.position
+			l.readChar()
+			l.readChar()
+			tok.Type = token.PercentEqual
+			tok.Literal = l.input[pos:l.position]
+			return tok
+		}
+		tok = l.newToken(token.Percent)
+	case '"':
+		tok.Type = token.String
+		tok.Literal = l.readString()
 	case -1:
 		tok.Literal = ""
 		tok.Type = token.Eof
 	default:
 		if isNumber(l.ch) {
-			tok.Literal = l.readInteger()
-			tok.Type = token.Int
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
-		} else if unicode.IsLetter(l.ch) {
+		} else if unicode.IsLetter(l.ch) || l.ch == '_' {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupKeyword(tok.Literal)
 			return tok
@@ -200,6 +333,14 @@ func (l *Lexer) peekByte() byte {
 	}
 }
 
+// Returns the byte offset bytes after the next one
+func (l *Lexer) peekByteAt(offset int) byte {
+	if l.readPosition+offset < len(l.input) {
+		return l.input[l.readPosition+offset]
+	}
+	return 0
+}
+
 func (l *Lexer) readIdentifier() string {
 	startPos := l.position
 
@@ -210,26 +351,134 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[startPos:l.position]
 }
 
-func (l *Lexer) readInteger() string {
+// Reads an integer or a float, a float has a fraction after a '.' or an exponent. Integers can have a base prefix,
+// '_' between the digits and a type suffix like 0xff_ffu16, the parser checks the digits and the suffix.
+// A '.' without a digit after it is not part of the number, so that "0..10" is still a range.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	startPos := l.position
+	t := token.Int
+
+	if next := l.peekByte(); l.ch == '0' && (next == 'x' || next == 'o' || next == 'b') {
+		l.readChar()
+		l.readChar()
+		for isHexNumber(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+	} else {
+		l.readDigits()
+		if l.ch == '.' && isNumber(rune(l.peekByte())) {
+			t = token.Float
+			l.readChar()
+			l.readDigits()
+		}
+
+		if l.ch == 'e' || l.ch == 'E' {
+			next := l.peekByteAt(0)
+			if next == '+' || next == '-' {
+				next = l.peekByteAt(1)
+			}
+			if isNumber(rune(next)) {
+				t = token.Float
+				l.readChar()
+				if l.ch == '+' || l.ch == '-' {
+					l.readChar()
+				}
+				l.readDigits()
+			}
+		}
+	}
 
-	for isNumber(l.ch) {
+	if t == token.Int && unicode.IsLetter(l.ch) {
+		l.readIdentifier()
+	}
+
+	return l.input[startPos:l.position], t
+}
+
+// Reads digits and the "_" between them
+func (l *Lexer) readDigits() {
+	for isNumber(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
+}
 
-	return l.input[startPos:l.position]
+// Reads a string literal and returns its content with the escape sequences replaced,
+// afterwards l.ch is the closing quote
+func (l *Lexer) readString() string {
+	start := l.loc()
+	var builder strings.Builder
+
+	l.readChar()
+	for l.ch != '"' {
+		switch l.ch {
+		case -1, '\n':
+			l.error(start, "unterminated string literal")
+			return builder.String()
+		case '\\':
+			loc := l.loc()
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			case 'r':
+				builder.WriteByte('\r')
+			case '0':
+				builder.WriteByte(0)
+			case '\\', '"':
+				builder.WriteRune(l.ch)
+			default:
+				l.error(loc, "unknown escape sequence \\%c", l.ch)
+			}
+		default:
+			builder.WriteRune(l.ch)
+		}
+		l.readChar()
+	}
+
+	return builder.String()
 }
 
 func isNumber(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexNumber(ch rune) bool {
+	return isNumber(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
 func (l *Lexer) skipWhitespace() {
 	for unicode.IsSpace(l.ch) {
 		l.readChar()
 	}
 }
 
+// Returns the line of loc with carets under the length bytes starting at loc, to point at a part of the line in an error
+func (l *Lexer) Caret(loc token.Loc, length int) string {
+	return Caret(l.input, loc, length)
+}
+
+// Returns the line of loc in the input with carets under the length bytes starting at loc
+func Caret(input string, loc token.Loc, length int) string {
+	start := strings.LastIndexByte(input[:loc.Pos], '\n') + 1
+	end := strings.IndexByte(input[loc.Pos:], '\n')
+	if end < 0 {
+		end = len(input)
+	} else {
+		end += loc.Pos
+	}
+
+	// Keep the tabs, so that the carets line up with the line
+	indent := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, input[start:loc.Pos])
+	return fmt.Sprintf("%s\n%s%s", input[start:end], indent, strings.Repeat("^", max(length, 1)))
+}
+
 func (l *Lexer) error(loc token.Loc, format string, args ...any) {
 	if l.errorCallback != nil {
 		l.errorCallback(loc, format, args...)
@@ -57,3 +57,196 @@ func TestBasicFunctionality(t *testing.T) {
 		},
 	})
 }
+
+func TestRanges(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "for i in 0..10 1..=n",
+		expectedToken: []token.Token{
+			{Type: token.For, Literal: "for"},
+			{Type: token.Ident, Literal: "i"},
+			{Type: token.In, Literal: "in"},
+			{Type: token.Int, Literal: "0"},
+			{Type: token.DotDot, Literal: ".."},
+			{Type: token.Int, Literal: "10"},
+			{Type: token.Int, Literal: "1"},
+			{Type: token.DotDotEqual, Literal: "..="},
+			{Type: token.Ident, Literal: "n"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestUnaryOperators(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "-a != !b ~c",
+		expectedToken: []token.Token{
+			{Type: token.Minus, Literal: "-"},
+			{Type: token.Ident, Literal: "a"},
+			{Type: token.NotEqual, Literal: "!="},
+			{Type: token.Bang, Literal: "!"},
+			{Type: token.Ident, Literal: "b"},
+			{Type: token.Tilde, Literal: "~"},
+			{Type: token.Ident, Literal: "c"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestLogicalOperators(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "a && b || c",
+		expectedToken: []token.Token{
+			{Type: token.Ident, Literal: "a"},
+			{Type: token.DoubleAmpersand, Literal: "&&"},
+			{Type: token.Ident, Literal: "b"},
+			{Type: token.DoublePipe, Literal: "||"},
+			{Type: token.Ident, Literal: "c"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestPipeOperator(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "a |> f(|) || b | c",
+		expectedToken: []token.Token{
+			{Type: token.Ident, Literal: "a"},
+			{Type: token.PipeGreater, Literal: "|>"},
+			{Type: token.Ident, Literal: "f"},
+			{Type: token.OpenParen, Literal: "("},
+			{Type: token.Pipe, Literal: "|"},
+			{Type: token.CloseParen, Literal: ")"},
+			{Type: token.DoublePipe, Literal: "||"},
+			{Type: token.Ident, Literal: "b"},
+			{Type: token.Pipe, Literal: "|"},
+			{Type: token.Ident, Literal: "c"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestCompoundAssignments(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "a += b -= c *= d /= e %= f",
+		expectedToken: []token.Token{
+			{Type: token.Ident, Literal: "a"},
+			{Type: token.PlusEqual, Literal: "+="},
+			{Type: token.Ident, Literal: "b"},
+			{Type: token.MinusEqual, Literal: "-="},
+			{Type: token.Ident, Literal: "c"},
+			{Type: token.AsteriskEqual, Literal: "*="},
+			{Type: token.Ident, Literal: "d"},
+			{Type: token.SlashEqual, Literal: "/="},
+			{Type: token.Ident, Literal: "e"},
+			{Type: token.PercentEqual, Literal: "%="},
+			{Type: token.Ident, Literal: "f"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "a % b & c | d ^ e << f >> g >>> h >= i <= j",
+		expectedToken: []token.Token{
+			{Type: token.Ident, Literal: "a"},
+			{Type: token.Percent, Literal: "%"},
+			{Type: token.Ident, Literal: "b"},
+			{Type: token.Ampersand, Literal: "&"},
+			{Type: token.Ident, Literal: "c"},
+			{Type: token.Pipe, Literal: "|"},
+			{Type: token.Ident, Literal: "d"},
+			{Type: token.Caret, Literal: "^"},
+			{Type: token.Ident, Literal: "e"},
+			{Type: token.ShiftLeft, Literal: "<<"},
+			{Type: token.Ident, Literal: "f"},
+			{Type: token.ShiftRight, Literal: ">>"},
+			{Type: token.Ident, Literal: "g"},
+			{Type: token.TripleGreater, Literal: ">>>"},
+			{Type: token.Ident, Literal: "h"},
+			{Type: token.GreaterThanEqual, Literal: ">="},
+			{Type: token.Ident, Literal: "i"},
+			{Type: token.LessThanEqual, Literal: "<="},
+			{Type: token.Ident, Literal: "j"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestNumbers(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "12 1.5 2e10 3.25E-2 0..10 1.x",
+		expectedToken: []token.Token{
+			{Type: token.Int, Literal: "12"},
+			{Type: token.Float, Literal: "1.5"},
+			{Type: token.Float, Literal: "2e10"},
+			{Type: token.Float, Literal: "3.25E-2"},
+			{Type: token.Int, Literal: "0"},
+			{Type: token.DotDot, Literal: ".."},
+			{Type: token.Int, Literal: "10"},
+			{Type: token.Int, Literal: "1"},
+			{Type: token.Dot, Literal: "."},
+			{Type: token.Ident, Literal: "x"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestIntegerLiterals(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "0xff_FFu16 0o17 0b1010 1_000i32 0..0x10 7u8.x",
+		expectedToken: []token.Token{
+			{Type: token.Int, Literal: "0xff_FFu16"},
+			{Type: token.Int, Literal: "0o17"},
+			{Type: token.Int, Literal: "0b1010"},
+			{Type: token.Int, Literal: "1_000i32"},
+			{Type: token.Int, Literal: "0"},
+			{Type: token.DotDot, Literal: ".."},
+			{Type: token.Int, Literal: "0x10"},
+			{Type: token.Int, Literal: "7u8"},
+			{Type: token.Dot, Literal: "."},
+			{Type: token.Ident, Literal: "x"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestStrings(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: `print("hello\tworld\n") "\"\\\0" ""`,
+		expectedToken: []token.Token{
+			{Type: token.Ident, Literal: "print"},
+			{Type: token.OpenParen, Literal: "("},
+			{Type: token.String, Literal: "hello\tworld\n"},
+			{Type: token.CloseParen, Literal: ")"},
+			{Type: token.String, Literal: "\"\\\x00"},
+			{Type: token.String, Literal: ""},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
+
+func TestEnums(t *testing.T) {
+	runLexerTest(t, lexerTest{
+		input: "enum match Shape::Circle(r) => r _ => x := 1",
+		expectedToken: []token.Token{
+			{Type: token.Enum, Literal: "enum"},
+			{Type: token.Match, Literal: "match"},
+			{Type: token.Ident, Literal: "Shape"},
+			{Type: token.DoubleColon, Literal: "::"},
+			{Type: token.Ident, Literal: "Circle"},
+			{Type: token.OpenParen, Literal: "("},
+			{Type: token.Ident, Literal: "r"},
+			{Type: token.CloseParen, Literal: ")"},
+			{Type: token.FatArrow, Literal: "=>"},
+			{Type: token.Ident, Literal: "r"},
+			{Type: token.Ident, Literal: "_"},
+			{Type: token.FatArrow, Literal: "=>"},
+			{Type: token.Ident, Literal: "x"},
+			{Type: token.Colon, Literal: ":"},
+			{Type: token.Equal, Literal: "="},
+			{Type: token.Int, Literal: "1"},
+			{Type: token.Eof, Literal: ""},
+		},
+	})
+}
@@ -22,7 +22,7 @@ func main() {
 	// defer term.LeaveRawMode()
 
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s [flags] input\nPossible flags:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s [flags] input [files]\nThe files are linked with the program, they can be object files (.o), fasm (.asm) or gas (.S) assembly files\nPossible flags:\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
@@ -66,7 +66,20 @@ func main() {
 		backend = asm.Qbe
 	}
 
-	err := build.NewSourceProgram(input, output).Build(backend, *emitAsmOnly, build.ToPrintFlags(toPrint))
+	sp := build.NewSourceProgram(input, output)
+	for _, file := range flag.Args()[1:] {
+		switch filepath.Ext(file) {
+		case ".o":
+			sp.ObjectFiles = append(sp.ObjectFiles, file)
+		case ".asm", ".S":
+			sp.InputAssemblies = append(sp.InputAssemblies, file)
+		default:
+			os.Stderr.WriteString(fmt.Sprintf("can not link the file %q, only object files (.o) and assembly files (.asm, .S) are supported\n", file))
+			term.Exit(1)
+		}
+	}
+
+	err := sp.Build(backend, *emitAsmOnly, build.ToPrintFlags(toPrint))
 	if err != nil {
 		os.Stderr.WriteString(fmt.Sprintf("%v\n", err.Error()))
 		term.Exit(1)
@@ -1,8 +1,13 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
 	"robaertschi.xyz/robaertschi/tt/lexer"
@@ -13,10 +18,20 @@ type precedence int
 
 const (
 	PrecLowest precedence = iota
+	PrecAssignment
+	PrecPipe
+	PrecOr
+	PrecAnd
 	PrecComparison
+	PrecBitwiseOr
+	PrecBitwiseXor
+	PrecBitwiseAnd
+	PrecShift
 	PrecSum
 	PrecProduct
-	PrecAssignment
+	PrecCast
+	PrecPrefix
+	PrecField
 )
 
 var precedences = map[token.TokenType]precedence{
@@ -24,13 +39,32 @@ var precedences = map[token.TokenType]precedence{
 	token.Minus:            PrecSum,
 	token.Asterisk:         PrecProduct,
 	token.Slash:            PrecProduct,
+	token.Percent:          PrecProduct,
+	token.Pipe:             PrecBitwiseOr,
+	token.Caret:            PrecBitwiseXor,
+	token.Ampersand:        PrecBitwiseAnd,
+	token.ShiftLeft:        PrecShift,
+	token.ShiftRight:       PrecShift,
+	token.TripleGreater:    PrecShift,
 	token.DoubleEqual:      PrecComparison,
 	token.NotEqual:         PrecComparison,
 	token.GreaterThan:      PrecComparison,
 	token.GreaterThanEqual: PrecComparison,
 	token.LessThan:         PrecComparison,
 	token.LessThanEqual:    PrecComparison,
+	token.DoubleAmpersand:  PrecAnd,
+	token.DoublePipe:       PrecOr,
+	token.PipeGreater:      PrecPipe,
+	token.As:               PrecCast,
+	token.Dot:              PrecField,
+	token.OpenSquare:       PrecField,
+	token.OpenParen:        PrecField,
 	token.Equal:            PrecAssignment,
+	token.PlusEqual:        PrecAssignment,
+	token.MinusEqual:       PrecAssignment,
+	token.AsteriskEqual:    PrecAssignment,
+	token.SlashEqual:       PrecAssignment,
+	token.PercentEqual:     PrecAssignment,
 }
 
 type ErrorCallback func(token.Token, string, ...any)
@@ -47,33 +81,83 @@ type Parser struct {
 	l              *lexer.Lexer
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// Set while parsing a condition or a range, where a '{' after a name starts the body and not a struct
+	noStructLiteral bool
+	// The names of the imported modules, "module.name" refers to a declaration of them
+	modules map[string]bool
+	// Set if the next integer literal is negated, -128i8 is in range even though 128i8 is not
+	negated bool
+	// Counts the variables, which store a piped value until the call
+	pipes int
+}
+
+// The integer types, which can be the suffix of an integer literal, and their size in bits
+var integerSuffixes = map[string]int{
+	"i8": 8, "i16": 16, "i32": 32, "i64": 64,
+	"u8": 8, "u16": 16, "u32": 32, "u64": 64,
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l}
+	p := &Parser{l: l, modules: make(map[string]bool)}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefixFn(token.Int, p.parseIntegerExpression)
+	p.registerPrefixFn(token.Float, p.parseFloatExpression)
+	p.registerPrefixFn(token.String, p.parseStringExpression)
 	p.registerPrefixFn(token.True, p.parseBooleanExpression)
 	p.registerPrefixFn(token.False, p.parseBooleanExpression)
 	p.registerPrefixFn(token.OpenParen, p.parseGroupedExpression)
 	p.registerPrefixFn(token.OpenBrack, p.parseBlockExpression)
 	p.registerPrefixFn(token.If, p.parseIfExpression)
+	p.registerPrefixFn(token.While, p.parseWhileExpression)
+	p.registerPrefixFn(token.For, p.parseForExpression)
+	p.registerPrefixFn(token.Match, p.parseMatchExpression)
+	p.registerPrefixFn(token.Break, p.parseBreakExpression)
+	p.registerPrefixFn(token.Continue, p.parseContinueExpression)
+	p.registerPrefixFn(token.Return, p.parseReturnExpression)
 	p.registerPrefixFn(token.Ident, p.parseVariable)
+	p.registerPrefixFn(token.Mut, p.parseMutableVariableDeclaration)
+	p.registerPrefixFn(token.Minus, p.parseUnaryExpression)
+	p.registerPrefixFn(token.Bang, p.parseUnaryExpression)
+	p.registerPrefixFn(token.Tilde, p.parseUnaryExpression)
+	p.registerPrefixFn(token.Ampersand, p.parseAddressOfExpression)
+	p.registerPrefixFn(token.Asterisk, p.parseDereferenceExpression)
+	p.registerPrefixFn(token.OpenSquare, p.parseArrayExpression)
+	p.registerPrefixFn(token.Fn, p.parseClosureExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfixFn(token.Plus, p.parseBinaryExpression)
 	p.registerInfixFn(token.Minus, p.parseBinaryExpression)
 	p.registerInfixFn(token.Asterisk, p.parseBinaryExpression)
 	p.registerInfixFn(token.Slash, p.parseBinaryExpression)
+	p.registerInfixFn(token.Percent, p.parseBinaryExpression)
+	p.registerInfixFn(token.Ampersand, p.parseBinaryExpression)
+	p.registerInfixFn(token.Pipe, p.parseBinaryExpression)
+	p.registerInfixFn(token.Caret, p.parseBinaryExpression)
+	p.registerInfixFn(token.ShiftLeft, p.parseBinaryExpression)
+	p.registerInfixFn(token.ShiftRight, p.parseBinaryExpression)
+	p.registerInfixFn(token.TripleGreater, p.parseBinaryExpression)
 	p.registerInfixFn(token.DoubleEqual, p.parseBinaryExpression)
 	p.registerInfixFn(token.NotEqual, p.parseBinaryExpression)
 	p.registerInfixFn(token.GreaterThan, p.parseBinaryExpression)
 	p.registerInfixFn(token.GreaterThanEqual, p.parseBinaryExpression)
 	p.registerInfixFn(token.LessThan, p.parseBinaryExpression)
 	p.registerInfixFn(token.LessThanEqual, p.parseBinaryExpression)
-
+	p.registerInfixFn(token.DoubleAmpersand, p.parseBinaryExpression)
+	p.registerInfixFn(token.DoublePipe, p.parseBinaryExpression)
+	p.registerInfixFn(token.PipeGreater, p.parsePipeExpression)
+
+	p.registerInfixFn(token.As, p.parseCastExpression)
+	p.registerInfixFn(token.Dot, p.parseFieldAccessExpression)
+	p.registerInfixFn(token.OpenSquare, p.parseIndexExpression)
+	p.registerInfixFn(token.OpenParen, p.parseCallExpression)
 	p.registerInfixFn(token.Equal, p.parseAssignmentExpression)
+	p.registerInfixFn(token.PlusEqual, p.parseAssignmentExpression)
+	p.registerInfixFn(token.MinusEqual, p.parseAssignmentExpression)
+	p.registerInfixFn(token.AsteriskEqual, p.parseAssignmentExpression)
+	p.registerInfixFn(token.SlashEqual, p.parseAssignmentExpression)
+	p.registerInfixFn(token.PercentEqual, p.parseAssignmentExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -144,6 +228,11 @@ func (p *Parser) exprError(invalidToken token.Token, format string, args ...any)
 	}
 }
 
+// Like exprError, but also shows the line of the token with carets under it
+func (p *Parser) caretError(invalidToken token.Token, format string, args ...any) ast.Expression {
+	return p.exprError(invalidToken, "%s\n%s", fmt.Sprintf(format, args...), p.l.Caret(invalidToken.Loc, len(invalidToken.Literal)))
+}
+
 func (p *Parser) expect(tt token.TokenType) (bool, ast.Expression) {
 	if p.curToken.Type != tt {
 		p.error(p.curToken, "expected %q, got %q", tt, p.curToken.Type)
@@ -168,22 +257,130 @@ func (p *Parser) ParseProgram() *ast.Program {
 	for p.curToken.Type != token.Eof {
 		decl := p.parseDeclaration()
 		if decl != nil {
+			switch decl.(type) {
+			case *ast.ModuleDeclaration:
+				if len(decls) > 0 {
+					p.error(decl.Tok(), "the module declaration has to be the first declaration of the file")
+				}
+			case *ast.ImportDeclaration:
+				// The names of the modules have to be known before they are used
+				for _, other := range decls {
+					switch other.(type) {
+					case *ast.ModuleDeclaration, *ast.ImportDeclaration:
+						continue
+					}
+					p.error(decl.Tok(), "imports have to be before all other declarations")
+					break
+				}
+			}
 			decls = append(decls, decl)
 		}
 		p.nextToken()
 	}
 
+	file, source := p.l.Source()
 	return &ast.Program{
 		Declarations: decls,
+		File:         file,
+		Source:       source,
 	}
 }
 
+// Parses a type, arrays, slices and pointers are represented as "[N]T", "[]T" and "*T"
 func (p *Parser) parseType() (t ast.Type, ok bool) {
+	if p.curTokenIs(token.Fn) {
+		return p.parseFunctionType()
+	}
+
+	if p.curTokenIs(token.Asterisk) {
+		p.nextToken()
+		pointee, ok := p.parseType()
+		if !ok {
+			return "", false
+		}
+		return ast.Type("*" + string(pointee)), true
+	}
+
+	if p.curTokenIs(token.OpenSquare) {
+		length := ""
+		if p.peekTokenIs(token.Int) {
+			p.nextToken()
+			value, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+			if err != nil || value <= 0 {
+				p.error(p.curToken, "the length of an array has to be a positive integer, got %q", p.curToken.Literal)
+				return "", false
+			}
+			length = strconv.FormatInt(value, 10)
+		}
+
+		if ok, _ := p.expectPeek(token.CloseSquare); !ok {
+			return "", false
+		}
+
+		p.nextToken()
+		element, ok := p.parseType()
+		if !ok {
+			return "", false
+		}
+		return ast.Type("[" + length + "]" + string(element)), true
+	}
+
 	if ok, _ := p.expect(token.Ident); !ok {
 		return "", false
 	}
 
-	return ast.Type(p.curToken.Literal), true
+	name, ok := p.parseQualifiedName()
+	return ast.Type(name), ok
+}
+
+// Parses the type of a function value "fn(types): type"
+func (p *Parser) parseFunctionType() (ast.Type, bool) {
+	if ok, _ := p.expectPeek(token.OpenParen); !ok {
+		return "", false
+	}
+
+	params := []ast.Type{}
+	for !p.peekTokenIs(token.CloseParen) {
+		p.nextToken()
+		t, ok := p.parseType()
+		if !ok {
+			return "", false
+		}
+		params = append(params, t)
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if ok, _ := p.expectPeek(token.CloseParen); !ok {
+		return "", false
+	}
+	if ok, _ := p.expectPeek(token.Colon); !ok {
+		return "", false
+	}
+	p.nextToken()
+	returnType, ok := p.parseType()
+	if !ok {
+		return "", false
+	}
+
+	return ast.FunctionType(params, returnType), true
+}
+
+// Parses a name, which is qualified with the name of a module if it starts with an imported module
+func (p *Parser) parseQualifiedName() (string, bool) {
+	name := p.curToken.Literal
+	if !p.modules[name] || !p.peekTokenIs(token.Dot) {
+		return name, true
+	}
+
+	p.nextToken()
+	if ok, _ := p.expectPeek(token.Ident); !ok {
+		return "", false
+	}
+	return name + "." + p.curToken.Literal, true
 }
 
 func (p *Parser) parseParameterList() ([]ast.Parameter, bool) {
@@ -191,7 +388,7 @@ func (p *Parser) parseParameterList() ([]ast.Parameter, bool) {
 
 	for p.peekTokenIs(token.Ident) {
 		p.nextToken()
-		name := p.curToken.Literal
+		nameTok := p.curToken
 		if ok, _ := p.expectPeek(token.Colon); !ok {
 			return parameters, false
 		}
@@ -202,7 +399,7 @@ func (p *Parser) parseParameterList() ([]ast.Parameter, bool) {
 			return parameters, false
 		}
 
-		parameters = append(parameters, ast.Parameter{Type: t, Name: name})
+		parameters = append(parameters, ast.Parameter{Token: nameTok, Type: t, Name: nameTok.Literal})
 
 		if !p.peekTokenIs(token.Comma) {
 			break
@@ -214,246 +411,899 @@ func (p *Parser) parseParameterList() ([]ast.Parameter, bool) {
 }
 
 func (p *Parser) parseDeclaration() ast.Declaration {
-	if ok, _ := p.expect(token.Fn); !ok {
+	switch p.curToken.Type {
+	case token.Struct:
+		return p.parseStructDeclaration()
+	case token.Enum:
+		return p.parseEnumDeclaration()
+	case token.Const:
+		return p.parseConstDeclaration()
+	case token.Var:
+		return p.parseGlobalDeclaration()
+	case token.Mod:
+		return p.parseModuleDeclaration()
+	case token.Import:
+		return p.parseImportDeclaration()
+	case token.Pub:
+		return p.parsePublicDeclaration()
+	case token.Extern:
+		return p.parseExternFunctionDeclaration()
+	}
+	return p.parseFunctionDeclaration()
+}
+
+func (p *Parser) parseModuleDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Mod); !ok {
 		return nil
 	}
 	tok := p.curToken
+
 	if ok, _ := p.expectPeek(token.Ident); !ok {
 		return nil
 	}
-
 	name := p.curToken.Literal
-	if ok, _ := p.expectPeek(token.OpenParen); !ok {
+
+	if ok, _ := p.expectPeek(token.Semicolon); !ok {
 		return nil
 	}
 
-	params, ok := p.parseParameterList()
+	return &ast.ModuleDeclaration{Token: tok, Name: name}
+}
 
-	if !ok {
+// Parses "import a.b;", the path is separated by dots
+func (p *Parser) parseImportDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Import); !ok {
 		return nil
 	}
+	decl := &ast.ImportDeclaration{Token: p.curToken}
 
-	if ok, _ := p.expectPeek(token.CloseParen); !ok {
-		return nil
-	}
-	if ok, _ := p.expectPeek(token.Colon); !ok {
-		return nil
-	}
-	p.nextToken()
-	t, ok := p.parseType()
-	if !ok {
-		return nil
-	}
-	if ok, _ := p.expectPeek(token.Equal); !ok {
-		return nil
+	for {
+		if ok, _ := p.expectPeek(token.Ident); !ok {
+			return nil
+		}
+		decl.Path = append(decl.Path, p.curToken.Literal)
+
+		if !p.peekTokenIs(token.Dot) {
+			break
+		}
+		p.nextToken()
 	}
 
-	p.nextToken()
-	expr := p.parseExpression(PrecLowest)
 	if ok, _ := p.expectPeek(token.Semicolon); !ok {
 		return nil
 	}
 
-	return &ast.FunctionDeclaration{
-		Token:      tok,
-		Name:       name,
-		Body:       expr,
-		Parameters: params,
-		ReturnType: t,
+	if p.modules[decl.Name()] {
+		p.error(decl.Token, "a module with the name %q is already imported", decl.Name())
 	}
+	p.modules[decl.Name()] = true
+
+	return decl
 }
 
-func (p *Parser) parseExpression(precedence precedence) ast.Expression {
-	prefix := p.prefixParseFns[p.curToken.Type]
-	if prefix == nil {
-		return p.exprError(p.curToken, "could not parse invalid token in expression %s", p.curToken.Type)
+// Parses a declaration after 'pub', which can be used by other modules
+func (p *Parser) parsePublicDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Pub); !ok {
+		return nil
 	}
+	tok := p.curToken
+	p.nextToken()
 
-	leftExpr := prefix()
-
-	for !p.peekTokenIs(token.Semicolon) && precedence < p.peekPrecedence() {
-		infix := p.infixParseFns[p.peekToken.Type]
-
-		if infix == nil {
-			return leftExpr
-		}
-
-		p.nextToken()
-
-		leftExpr = infix(leftExpr)
+	decl := p.parseDeclaration()
+	switch decl := decl.(type) {
+	case nil:
+		return nil
+	case *ast.FunctionDeclaration:
+		decl.Public = true
+	case *ast.ExternFunctionDeclaration:
+		decl.Public = true
+	case *ast.ConstDeclaration:
+		decl.Public = true
+	case *ast.GlobalDeclaration:
+		decl.Public = true
+	case *ast.StructDeclaration:
+		decl.Public = true
+	case *ast.EnumDeclaration:
+		decl.Public = true
+	default:
+		p.error(tok, "only functions, constants, global variables, structs and enums can be public")
 	}
 
-	return leftExpr
+	return decl
 }
 
-func (p *Parser) parseIntegerExpression() ast.Expression {
-	if ok, errExpr := p.expect(token.Int); !ok {
-		return errExpr
-	}
-
-	int := &ast.IntegerExpression{
-		Token: p.curToken,
+func (p *Parser) parseConstDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Const); !ok {
+		return nil
 	}
+	tok := p.curToken
 
-	value, err := strconv.ParseInt(int.Token.Literal, 0, 64)
-	if err != nil {
-		return p.exprError(int.Token, "invalid integer literal: %v", err)
+	name, t, value, ok := p.parseTopLevelValue()
+	if !ok {
+		return nil
 	}
 
-	int.Value = value
-	return int
+	return &ast.ConstDeclaration{Token: tok, Name: name, Type: t, Value: value}
 }
 
-func (p *Parser) parseBooleanExpression() ast.Expression {
-	var value bool
-	switch p.curToken.Type {
-	case token.True:
-		value = true
-	case token.False:
-		value = false
-	default:
-		return p.exprError(p.curToken, "invalid token for boolean expression %s", p.curToken.Type)
+func (p *Parser) parseGlobalDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Var); !ok {
+		return nil
 	}
+	tok := p.curToken
 
-	return &ast.BooleanExpression{
-		Token: p.curToken,
-		Value: value,
+	name, t, value, ok := p.parseTopLevelValue()
+	if !ok {
+		return nil
 	}
+
+	return &ast.GlobalDeclaration{Token: tok, Name: name, Type: t, Value: value}
 }
 
-func (p *Parser) parseGroupedExpression() ast.Expression {
-	p.expect(token.OpenParen)
+// Parses "name: type = value;" after a const or var
+func (p *Parser) parseTopLevelValue() (name string, t ast.Type, value ast.Expression, ok bool) {
+	if ok, _ := p.expectPeek(token.Ident); !ok {
+		return "", "", nil, false
+	}
+	name = p.curToken.Literal
 
+	if ok, _ := p.expectPeek(token.Colon); !ok {
+		return "", "", nil, false
+	}
 	p.nextToken()
-	expr := p.parseExpression(PrecLowest)
-
-	if ok, errExpr := p.expectPeek(token.CloseParen); !ok {
-		return errExpr
+	t, ok = p.parseType()
+	if !ok {
+		return "", "", nil, false
 	}
 
-	return expr
-}
-
-func (p *Parser) parseBlockExpression() ast.Expression {
-	if ok, errExpr := p.expect(token.OpenBrack); !ok {
-		return errExpr
+	if ok, _ := p.expectPeek(token.Equal); !ok {
+		return "", "", nil, false
 	}
-	block := &ast.BlockExpression{Token: p.curToken}
 
 	p.nextToken()
-	for !p.curTokenIs(token.CloseBrack) {
-		expr := p.parseExpression(PrecLowest)
-		if p.peekTokenIs(token.Semicolon) {
-			block.Expressions = append(block.Expressions, expr)
-			p.nextToken()
-			p.nextToken()
-		} else if p.peekTokenIs(token.CloseBrack) {
-			block.ReturnExpression = expr
-			p.nextToken()
-		} else {
-			return p.exprError(p.peekToken, "expected a ';' or '}' to either end the current expression or block, but got %q instead.", p.peekToken.Type)
-		}
+	value = p.parseExpression(PrecLowest)
+	if ok, _ := p.expectPeek(token.Semicolon); !ok {
+		return "", "", nil, false
 	}
 
-	return block
+	return name, t, value, true
 }
 
-func (p *Parser) parseIfExpression() ast.Expression {
-	if ok, errExpr := p.expect(token.If); !ok {
-		return errExpr
+func (p *Parser) parseStructDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Struct); !ok {
+		return nil
 	}
+	decl := &ast.StructDeclaration{Token: p.curToken}
 
-	ifExpr := &ast.IfExpression{Token: p.curToken}
+	if ok, _ := p.expectPeek(token.Ident); !ok {
+		return nil
+	}
+	decl.Name = p.curToken.Literal
 
-	p.nextToken()
-	ifExpr.Condition = p.parseExpression(PrecLowest)
+	if ok, _ := p.expectPeek(token.OpenBrack); !ok {
+		return nil
+	}
 
-	if p.peekTokenIs(token.OpenBrack) {
+	for p.peekTokenIs(token.Ident) {
 		p.nextToken()
-		ifExpr.Then = p.parseBlockExpression()
-	} else {
-		if ok, errExpr := p.expectPeek(token.In); !ok {
-			return errExpr
+		name := p.curToken.Literal
+		if ok, _ := p.expectPeek(token.Colon); !ok {
+			return nil
+		}
+		p.nextToken()
+		t, ok := p.parseType()
+		if !ok {
+			return nil
 		}
 
+		decl.Fields = append(decl.Fields, ast.StructField{Name: name, Type: t})
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
 		p.nextToken()
-		ifExpr.Then = p.parseExpression(PrecLowest)
 	}
 
-	if p.peekTokenIs(token.Else) {
-		p.nextToken()
+	if ok, _ := p.expectPeek(token.CloseBrack); !ok {
+		return nil
+	}
+
+	// The ';' after a struct is optional
+	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
-		ifExpr.Else = p.parseExpression(PrecLowest)
-	} else {
-		ifExpr.Else = nil
 	}
 
-	return ifExpr
+	return decl
 }
 
-func (p *Parser) parseVariable() ast.Expression {
-	if ok, errExpr := p.expect(token.Ident); !ok {
-		return errExpr
+func (p *Parser) parseEnumDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Enum); !ok {
+		return nil
 	}
+	decl := &ast.EnumDeclaration{Token: p.curToken}
 
-	switch p.peekToken.Type {
-	case token.Colon:
-		return p.parseVariableDeclaration()
-	case token.OpenParen:
-		return p.parseFunctionCall()
-	default:
-		return &ast.VariableReference{
-			Token:      p.curToken,
-			Identifier: p.curToken.Literal,
-		}
+	if ok, _ := p.expectPeek(token.Ident); !ok {
+		return nil
 	}
+	decl.Name = p.curToken.Literal
 
-	// FIXME(Robin): Add variable references
-	// Lets panic about deez nuts of yours
-	panic("deez nuts")
-}
-
-func (p *Parser) parseVariableDeclaration() ast.Expression {
-	if ok, errExpr := p.expect(token.Ident); !ok {
-		return errExpr
+	if ok, _ := p.expectPeek(token.OpenBrack); !ok {
+		return nil
 	}
 
-	variable := &ast.VariableDeclaration{Token: p.curToken, Identifier: p.curToken.Literal}
-	if ok, errExpr := p.expectPeek(token.Colon); !ok {
-		return errExpr
-	}
+	for p.peekTokenIs(token.Ident) {
+		p.nextToken()
+		variant := ast.EnumVariant{Name: p.curToken.Literal}
+
+		if p.peekTokenIs(token.OpenParen) {
+			p.nextToken()
+			for !p.peekTokenIs(token.CloseParen) {
+				p.nextToken()
+				t, ok := p.parseType()
+				if !ok {
+					return nil
+				}
+				variant.Fields = append(variant.Fields, t)
+
+				if !p.peekTokenIs(token.Comma) {
+					break
+				}
+				p.nextToken()
+			}
+
+			if ok, _ := p.expectPeek(token.CloseParen); !ok {
+				return nil
+			}
+		}
+
+		decl.Variants = append(decl.Variants, variant)
 
-	if p.peekTokenIs(token.Ident) {
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
 		p.nextToken()
-		variable.Type = ast.Type(p.curToken.Literal)
 	}
 
-	if ok, errExpr := p.expectPeek(token.Equal); !ok {
-		return errExpr
+	if ok, _ := p.expectPeek(token.CloseBrack); !ok {
+		return nil
 	}
 
-	p.nextToken()
-	variable.InitializingExpression = p.parseExpression(PrecLowest)
+	// The ';' after an enum is optional
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return decl
+}
+
+// Parses "extern fn name(params): type;", the function has no body
+func (p *Parser) parseExternFunctionDeclaration() ast.Declaration {
+	if ok, _ := p.expect(token.Extern); !ok {
+		return nil
+	}
+	tok := p.curToken
+	p.nextToken()
+
+	name, typeParams, params, t, ok := p.parseFunctionSignature()
+	if !ok {
+		return nil
+	}
+	if len(typeParams) > 0 {
+		p.error(tok, "the extern function %q can not have type parameters", name)
+		return nil
+	}
+	if ok, _ := p.expectPeek(token.Semicolon); !ok {
+		return nil
+	}
+
+	return &ast.ExternFunctionDeclaration{Token: tok, Name: name, Parameters: params, ReturnType: t}
+}
+
+// Parses "fn name[type params](params): type" of a function declaration, the type parameters are optional
+func (p *Parser) parseFunctionSignature() (name string, typeParams []string, params []ast.Parameter, t ast.Type, ok bool) {
+	if ok, _ := p.expect(token.Fn); !ok {
+		return "", nil, nil, "", false
+	}
+	if ok, _ := p.expectPeek(token.Ident); !ok {
+		return "", nil, nil, "", false
+	}
+
+	name = p.curToken.Literal
+	if p.peekTokenIs(token.OpenSquare) {
+		p.nextToken()
+		typeParams, ok = p.parseTypeParameterList()
+		if !ok {
+			return "", nil, nil, "", false
+		}
+	}
+
+	if ok, _ := p.expectPeek(token.OpenParen); !ok {
+		return "", nil, nil, "", false
+	}
+
+	params, ok = p.parseParameterList()
+
+	if !ok {
+		return "", nil, nil, "", false
+	}
+
+	if ok, _ := p.expectPeek(token.CloseParen); !ok {
+		return "", nil, nil, "", false
+	}
+	if ok, _ := p.expectPeek(token.Colon); !ok {
+		return "", nil, nil, "", false
+	}
+	p.nextToken()
+	t, ok = p.parseType()
+	if !ok {
+		return "", nil, nil, "", false
+	}
+
+	return name, typeParams, params, t, true
+}
+
+// Parses the type parameters "[T, U]" of a generic function, the current token is the '['
+func (p *Parser) parseTypeParameterList() ([]string, bool) {
+	tok := p.curToken
+	typeParams := []string{}
+
+	for p.peekTokenIs(token.Ident) {
+		p.nextToken()
+		name := p.curToken.Literal
+		if slices.Contains(typeParams, name) {
+			p.error(p.curToken, "duplicate type parameter %q", name)
+			return typeParams, false
+		}
+		typeParams = append(typeParams, name)
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if ok, _ := p.expectPeek(token.CloseSquare); !ok {
+		return typeParams, false
+	}
+	if len(typeParams) == 0 {
+		p.error(tok, "a generic function needs at least one type parameter")
+		return typeParams, false
+	}
+
+	return typeParams, true
+}
+
+func (p *Parser) parseFunctionDeclaration() ast.Declaration {
+	tok := p.curToken
+	name, typeParams, params, t, ok := p.parseFunctionSignature()
+	if !ok {
+		return nil
+	}
+	if ok, _ := p.expectPeek(token.Equal); !ok {
+		return nil
+	}
+
+	p.nextToken()
+	expr := p.parseExpression(PrecLowest)
+	if ok, _ := p.expectPeek(token.Semicolon); !ok {
+		return nil
+	}
+
+	return &ast.FunctionDeclaration{
+		Token:          tok,
+		Name:           name,
+		TypeParameters: typeParams,
+		Body:           expr,
+		Parameters:     params,
+		ReturnType:     t,
+	}
+}
+
+func (p *Parser) parseExpression(precedence precedence) ast.Expression {
+	prefix := p.prefixParseFns[p.curToken.Type]
+	if prefix == nil {
+		return p.exprError(p.curToken, "could not parse invalid token in expression %s", p.curToken.Type)
+	}
+
+	leftExpr := prefix()
+
+	for !p.peekTokenIs(token.Semicolon) && precedence < p.peekPrecedence() {
+		infix := p.infixParseFns[p.peekToken.Type]
+
+		if infix == nil {
+			return leftExpr
+		}
+
+		p.nextToken()
+
+		leftExpr = infix(leftExpr)
+	}
+
+	return leftExpr
+}
+
+func (p *Parser) parseIntegerExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.Int); !ok {
+		return errExpr
+	}
+
+	int := &ast.IntegerExpression{
+		Token: p.curToken,
+	}
+	negated := p.negated
+	p.negated = false
+
+	digits, base := int.Token.Literal, 10
+	if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 10 {
+			digits = digits[2:]
+		}
+	}
+
+	// The suffix starts after the digits, hex digits can be letters too
+	if end := strings.IndexFunc(digits, func(r rune) bool {
+		if base == 16 {
+			return !strings.ContainsRune("0123456789abcdefABCDEF_", r)
+		}
+		return unicode.IsLetter(r)
+	}); end >= 0 {
+		digits, int.Type = digits[:end], ast.Type(digits[end:])
+	}
+
+	bits := 64
+	if int.Type != "" {
+		var ok bool
+		bits, ok = integerSuffixes[string(int.Type)]
+		if !ok {
+			return p.caretError(int.Token, "unknown integer literal suffix %q, only integer types like u8 or i32 can be a suffix", int.Type)
+		}
+	}
+
+	if digits == "" {
+		return p.caretError(int.Token, "the integer literal %s has no digits", int.Token.Literal)
+	}
+	for i, ch := range digits {
+		if ch == '_' && (i == 0 || i == len(digits)-1 || digits[i+1] == '_') {
+			return p.caretError(int.Token, "a \"_\" in the integer literal %s has to be between two digits", int.Token.Literal)
+		}
+	}
+
+	value, err := strconv.ParseUint(strings.ReplaceAll(digits, "_", ""), base, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return p.caretError(int.Token, "the integer literal %s has invalid digits for base %d", int.Token.Literal, base)
+	}
+
+	if err != nil {
+		return p.caretError(int.Token, "the integer literal %s does not fit into 64 bits", int.Token.Literal)
+	}
+	// The type of a literal without a suffix is inferred, the typechecker checks if it fits into that type
+	if int.Type != "" {
+		limit := uint64(math.MaxUint64) >> (64 - bits)
+		if int.Type[0] == 'i' {
+			limit >>= 1
+			if negated {
+				limit += 1
+			}
+		}
+		if value > limit {
+			return p.caretError(int.Token, "the integer literal %s does not fit into %s", int.Token.Literal, int.Type)
+		}
+	}
+
+	// The values above the maximum of i64 wrap around and keep their bits
+	int.Value = int64(value)
+	return int
+}
+
+func (p *Parser) parseFloatExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.Float); !ok {
+		return errExpr
+	}
+
+	float := &ast.FloatExpression{
+		Token: p.curToken,
+	}
+
+	value, err := strconv.ParseFloat(float.Token.Literal, 64)
+	if err != nil {
+		return p.exprError(float.Token, "invalid float literal: %v", err)
+	}
+
+	float.Value = value
+	return float
+}
+
+func (p *Parser) parseStringExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.String); !ok {
+		return errExpr
+	}
+
+	return &ast.StringExpression{
+		Token: p.curToken,
+		Value: p.curToken.Literal,
+	}
+}
+
+func (p *Parser) parseBooleanExpression() ast.Expression {
+	var value bool
+	switch p.curToken.Type {
+	case token.True:
+		value = true
+	case token.False:
+		value = false
+	default:
+		return p.exprError(p.curToken, "invalid token for boolean expression %s", p.curToken.Type)
+	}
+
+	return &ast.BooleanExpression{
+		Token: p.curToken,
+		Value: value,
+	}
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.expect(token.OpenParen)
+
+	p.nextToken()
+	expr := p.parseNestedExpression()
+
+	if ok, errExpr := p.expectPeek(token.CloseParen); !ok {
+		return errExpr
+	}
+
+	return expr
+}
+
+// Parses an expression where a '{' after a name starts a body instead of a struct,
+// like the condition of an if
+func (p *Parser) parseCondition() ast.Expression {
+	noStructLiteral := p.noStructLiteral
+	p.noStructLiteral = true
+	expr := p.parseExpression(PrecLowest)
+	p.noStructLiteral = noStructLiteral
+	return expr
+}
+
+// Parses an expression that is enclosed by delimiters, so struct literals are allowed again
+func (p *Parser) parseNestedExpression() ast.Expression {
+	noStructLiteral := p.noStructLiteral
+	p.noStructLiteral = false
+	expr := p.parseExpression(PrecLowest)
+	p.noStructLiteral = noStructLiteral
+	return expr
+}
+
+func (p *Parser) parseBlockExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.OpenBrack); !ok {
+		return errExpr
+	}
+	block := &ast.BlockExpression{Token: p.curToken}
+
+	p.nextToken()
+	for !p.curTokenIs(token.CloseBrack) {
+		expr := p.parseNestedExpression()
+		if p.peekTokenIs(token.Semicolon) {
+			block.Expressions = append(block.Expressions, expr)
+			p.nextToken()
+			p.nextToken()
+		} else if p.peekTokenIs(token.CloseBrack) {
+			block.ReturnExpression = expr
+			p.nextToken()
+		} else {
+			return p.exprError(p.peekToken, "expected a ';' or '}' to either end the current expression or block, but got %q instead.", p.peekToken.Type)
+		}
+	}
+
+	return block
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.If); !ok {
+		return errExpr
+	}
+
+	ifExpr := &ast.IfExpression{Token: p.curToken}
+
+	p.nextToken()
+	ifExpr.Condition = p.parseCondition()
+
+	if p.peekTokenIs(token.OpenBrack) {
+		p.nextToken()
+		ifExpr.Then = p.parseBlockExpression()
+	} else {
+		if ok, errExpr := p.expectPeek(token.In); !ok {
+			return errExpr
+		}
+
+		p.nextToken()
+		ifExpr.Then = p.parseExpression(PrecLowest)
+	}
+
+	if p.peekTokenIs(token.Else) {
+		p.nextToken()
+		p.nextToken()
+		ifExpr.Else = p.parseExpression(PrecLowest)
+	} else {
+		ifExpr.Else = nil
+	}
+
+	return ifExpr
+}
+
+func (p *Parser) parseWhileExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.While); !ok {
+		return errExpr
+	}
+
+	whileExpr := &ast.WhileExpression{Token: p.curToken}
+
+	p.nextToken()
+	whileExpr.Condition = p.parseCondition()
+
+	if ok, errExpr := p.expectPeek(token.OpenBrack); !ok {
+		return errExpr
+	}
+	whileExpr.Body = p.parseBlockExpression()
+
+	if p.peekTokenIs(token.Else) {
+		p.nextToken()
+		p.nextToken()
+		whileExpr.Else = p.parseExpression(PrecLowest)
+	}
+
+	return whileExpr
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.Match); !ok {
+		return errExpr
+	}
+
+	matchExpr := &ast.MatchExpression{Token: p.curToken}
+
+	p.nextToken()
+	matchExpr.Expression = p.parseCondition()
+
+	if ok, errExpr := p.expectPeek(token.OpenBrack); !ok {
+		return errExpr
+	}
+
+	for p.peekTokenIs(token.Ident) {
+		p.nextToken()
+		arm := ast.MatchArm{Token: p.curToken, Variant: p.curToken.Literal}
+
+		if p.peekTokenIs(token.OpenParen) {
+			p.nextToken()
+			for p.peekTokenIs(token.Ident) {
+				p.nextToken()
+				arm.Bindings = append(arm.Bindings, p.curToken.Literal)
+
+				if !p.peekTokenIs(token.Comma) {
+					break
+				}
+				p.nextToken()
+			}
+
+			if ok, errExpr := p.expectPeek(token.CloseParen); !ok {
+				return errExpr
+			}
+		}
+
+		if ok, errExpr := p.expectPeek(token.FatArrow); !ok {
+			return errExpr
+		}
+
+		p.nextToken()
+		arm.Body = p.parseNestedExpression()
+		matchExpr.Arms = append(matchExpr.Arms, arm)
+
+		// The ',' is optional after a block
+		if p.peekTokenIs(token.Comma) {
+			p.nextToken()
+		} else if _, ok := arm.Body.(*ast.BlockExpression); !ok {
+			break
+		}
+	}
+
+	if ok, errExpr := p.expectPeek(token.CloseBrack); !ok {
+		return errExpr
+	}
+
+	return matchExpr
+}
+
+func (p *Parser) parseForExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.For); !ok {
+		return errExpr
+	}
+
+	forExpr := &ast.ForExpression{Token: p.curToken}
+
+	if ok, errExpr := p.expectPeek(token.Ident); !ok {
+		return errExpr
+	}
+	forExpr.Identifier = p.curToken.Literal
+
+	if ok, errExpr := p.expectPeek(token.In); !ok {
+		return errExpr
+	}
+
+	p.nextToken()
+	forExpr.Start = p.parseCondition()
+
+	switch p.peekToken.Type {
+	case token.DotDot:
+	case token.DotDotEqual:
+		forExpr.Inclusive = true
+	default:
+		return p.exprError(p.peekToken, "expected %q or %q, got %q", token.DotDot, token.DotDotEqual, p.peekToken.Type)
+	}
+	p.nextToken()
+
+	p.nextToken()
+	forExpr.End = p.parseCondition()
+
+	if ok, errExpr := p.expectPeek(token.OpenBrack); !ok {
+		return errExpr
+	}
+	forExpr.Body = p.parseBlockExpression()
+
+	return forExpr
+}
+
+// Checks if the peek token can not start a new expression, which means that a preceding
+// break or return has no value
+func (p *Parser) peekEndsExpression() bool {
+	switch p.peekToken.Type {
+	case token.Semicolon, token.CloseBrack, token.CloseParen, token.Comma, token.Else, token.Eof:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseBreakExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.Break); !ok {
+		return errExpr
+	}
+
+	breakExpr := &ast.BreakExpression{Token: p.curToken}
+
+	if !p.peekEndsExpression() {
+		p.nextToken()
+		breakExpr.Value = p.parseExpression(PrecLowest)
+	}
+
+	return breakExpr
+}
 
-	return variable
+func (p *Parser) parseContinueExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.Continue); !ok {
+		return errExpr
+	}
+
+	return &ast.ContinueExpression{Token: p.curToken}
+}
+
+func (p *Parser) parseReturnExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.Return); !ok {
+		return errExpr
+	}
+
+	returnExpr := &ast.ReturnExpression{Token: p.curToken}
+
+	if !p.peekEndsExpression() {
+		p.nextToken()
+		returnExpr.Value = p.parseExpression(PrecLowest)
+	}
+
+	return returnExpr
+}
+
+func (p *Parser) parseVariable() ast.Expression {
+	if ok, errExpr := p.expect(token.Ident); !ok {
+		return errExpr
+	}
+
+	if p.peekTokenIs(token.Colon) {
+		return p.parseVariableDeclaration()
+	}
+
+	tok := p.curToken
+	name, ok := p.parseQualifiedName()
+	if !ok {
+		return &ast.ErrorExpression{InvalidToken: p.curToken}
+	}
+
+	switch p.peekToken.Type {
+	case token.OpenParen:
+		return p.parseFunctionCall(tok, name)
+	case token.DoubleColon:
+		return p.parseEnumExpression(tok, name)
+	case token.OpenBrack:
+		if !p.noStructLiteral {
+			return p.parseStructExpression(tok, name)
+		}
+		fallthrough
+	default:
+		return &ast.VariableReference{
+			Token:      tok,
+			Identifier: name,
+		}
+	}
+}
+
+func (p *Parser) parseMutableVariableDeclaration() ast.Expression {
+	if ok, errExpr := p.expect(token.Mut); !ok {
+		return errExpr
+	}
+	if ok, errExpr := p.expectPeek(token.Ident); !ok {
+		return errExpr
+	}
+
+	expr := p.parseVariableDeclaration()
+	if variable, ok := expr.(*ast.VariableDeclaration); ok {
+		variable.Mutable = true
+	}
+	return expr
 }
 
-func (p *Parser) parseFunctionCall() ast.Expression {
+func (p *Parser) parseVariableDeclaration() ast.Expression {
 	if ok, errExpr := p.expect(token.Ident); !ok {
 		return errExpr
 	}
 
-	funcCall := &ast.FunctionCall{Token: p.curToken, Identifier: p.curToken.Literal}
+	variable := &ast.VariableDeclaration{Token: p.curToken, Identifier: p.curToken.Literal}
+	if ok, errExpr := p.expectPeek(token.Colon); !ok {
+		return errExpr
+	}
+
+	if p.peekTokenIs(token.Ident) || p.peekTokenIs(token.OpenSquare) || p.peekTokenIs(token.Asterisk) || p.peekTokenIs(token.Fn) {
+		p.nextToken()
+		t, ok := p.parseType()
+		if !ok {
+			return &ast.ErrorExpression{InvalidToken: p.curToken}
+		}
+		variable.Type = t
+	}
+
+	if ok, errExpr := p.expectPeek(token.Equal); !ok {
+		return errExpr
+	}
+
+	p.nextToken()
+	variable.InitializingExpression = p.parseExpression(PrecLowest)
+
+	return variable
+}
+
+// Parses the arguments of a call of the function name, the current token is the last token of the name
+func (p *Parser) parseFunctionCall(tok token.Token, name string) ast.Expression {
+	funcCall := &ast.FunctionCall{Token: tok, Identifier: name}
 	if ok, errExpr := p.expectPeek(token.OpenParen); !ok {
 		return errExpr
 	}
 
+	funcCall.Arguments = p.parseArguments()
+
+	return funcCall
+}
+
+// Parses the arguments of a call, the current token is the '(' and the last token is the ')'
+func (p *Parser) parseArguments() []ast.Expression {
 	args := []ast.Expression{}
 
 	for !p.peekTokenIs(token.CloseParen) {
 		p.nextToken()
 
-		args = append(args, p.parseExpression(PrecLowest))
+		args = append(args, p.parseNestedExpression())
 		if !p.peekTokenIs(token.Comma) {
 			break
 		}
@@ -463,9 +1313,303 @@ func (p *Parser) parseFunctionCall() ast.Expression {
 	// Move onto the ')'
 	p.nextToken()
 
-	funcCall.Arguments = args
+	return args
+}
 
-	return funcCall
+// Parses "value |> function(args)" into a call of function. The value is the first argument, unless one argument
+// is the placeholder '|', which is replaced by the value. The value is always evaluated before the arguments, so
+// if it is placed after another argument, it is stored in a variable first: "{ pipe.N := value; function(args) }".
+func (p *Parser) parsePipeExpression(lhs ast.Expression) ast.Expression {
+	tok := p.curToken
+	if ok, errExpr := p.expectPeek(token.Ident); !ok {
+		return errExpr
+	}
+	name, ok := p.parseQualifiedName()
+	if !ok {
+		return &ast.ErrorExpression{InvalidToken: p.curToken}
+	}
+	if ok, errExpr := p.expectPeek(token.OpenParen); !ok {
+		return errExpr
+	}
+
+	args := []ast.Expression{}
+	placeholder := -1
+	for !p.peekTokenIs(token.CloseParen) {
+		p.nextToken()
+
+		if p.curTokenIs(token.Pipe) && (p.peekTokenIs(token.Comma) || p.peekTokenIs(token.CloseParen)) {
+			if placeholder >= 0 {
+				p.error(p.curToken, "the piped value can only be placed once")
+			}
+			placeholder = len(args)
+			args = append(args, lhs)
+		} else {
+			args = append(args, p.parseNestedExpression())
+		}
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if ok, errExpr := p.expectPeek(token.CloseParen); !ok {
+		return errExpr
+	}
+
+	if placeholder < 0 {
+		args = append([]ast.Expression{lhs}, args...)
+	}
+
+	call := &ast.FunctionCall{Token: tok, Identifier: name, Arguments: args}
+	// Literals have no side effects, they are kept in place, so that integers still get their type from the parameter
+	if placeholder <= 0 || isLiteral(lhs) {
+		return call
+	}
+
+	p.pipes += 1
+	variable := fmt.Sprintf("pipe.%d", p.pipes)
+	args[placeholder] = &ast.VariableReference{Token: tok, Identifier: variable}
+	return &ast.BlockExpression{
+		Token:            tok,
+		Expressions:      []ast.Expression{&ast.VariableDeclaration{Token: tok, Identifier: variable, InitializingExpression: lhs}},
+		ReturnExpression: call,
+	}
+}
+
+// Checks if the expression is a literal or a negated literal
+func isLiteral(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntegerExpression, *ast.FloatExpression, *ast.BooleanExpression, *ast.StringExpression:
+		return true
+	case *ast.UnaryExpression:
+		return isLiteral(expr.Operand)
+	}
+	return false
+}
+
+// Parses the call of a function value, functions called by their name are parsed by parseVariable
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	return &ast.CallExpression{Token: p.curToken, Function: function, Arguments: p.parseArguments()}
+}
+
+// Parses an anonymous function "fn(params): type = body"
+func (p *Parser) parseClosureExpression() ast.Expression {
+	closure := &ast.ClosureExpression{Token: p.curToken}
+	if ok, errExpr := p.expectPeek(token.OpenParen); !ok {
+		return errExpr
+	}
+
+	params, ok := p.parseParameterList()
+	if !ok {
+		return &ast.ErrorExpression{InvalidToken: p.curToken}
+	}
+	closure.Parameters = params
+
+	if ok, errExpr := p.expectPeek(token.CloseParen); !ok {
+		return errExpr
+	}
+	if ok, errExpr := p.expectPeek(token.Colon); !ok {
+		return errExpr
+	}
+	p.nextToken()
+	t, ok := p.parseType()
+	if !ok {
+		return &ast.ErrorExpression{InvalidToken: p.curToken}
+	}
+	closure.ReturnType = t
+
+	if ok, errExpr := p.expectPeek(token.Equal); !ok {
+		return errExpr
+	}
+	p.nextToken()
+	closure.Body = p.parseExpression(PrecLowest)
+
+	return closure
+}
+
+// Parses a variant of an enum `Enum::Variant(args)`, the arguments are optional
+func (p *Parser) parseEnumExpression(tok token.Token, name string) ast.Expression {
+	enumExpr := &ast.EnumExpression{Token: tok, Enum: name}
+	if ok, errExpr := p.expectPeek(token.DoubleColon); !ok {
+		return errExpr
+	}
+	if ok, errExpr := p.expectPeek(token.Ident); !ok {
+		return errExpr
+	}
+	enumExpr.Variant = p.curToken.Literal
+
+	if !p.peekTokenIs(token.OpenParen) {
+		return enumExpr
+	}
+	p.nextToken()
+
+	for !p.peekTokenIs(token.CloseParen) {
+		p.nextToken()
+		enumExpr.Arguments = append(enumExpr.Arguments, p.parseNestedExpression())
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if ok, errExpr := p.expectPeek(token.CloseParen); !ok {
+		return errExpr
+	}
+
+	return enumExpr
+}
+
+func (p *Parser) parseStructExpression(tok token.Token, name string) ast.Expression {
+	structExpr := &ast.StructExpression{Token: tok, Name: name}
+	if ok, errExpr := p.expectPeek(token.OpenBrack); !ok {
+		return errExpr
+	}
+
+	for p.peekTokenIs(token.Ident) {
+		p.nextToken()
+		field := ast.StructExpressionField{Token: p.curToken, Name: p.curToken.Literal}
+		if ok, errExpr := p.expectPeek(token.Colon); !ok {
+			return errExpr
+		}
+
+		p.nextToken()
+		field.Value = p.parseNestedExpression()
+		structExpr.Fields = append(structExpr.Fields, field)
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if ok, errExpr := p.expectPeek(token.CloseBrack); !ok {
+		return errExpr
+	}
+
+	return structExpr
+}
+
+func (p *Parser) parseFieldAccessExpression(lhs ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	if ok, errExpr := p.expectPeek(token.Ident); !ok {
+		return errExpr
+	}
+
+	return &ast.FieldAccessExpression{Token: tok, Expression: lhs, Field: p.curToken.Literal}
+}
+
+func (p *Parser) parseArrayExpression() ast.Expression {
+	if ok, errExpr := p.expect(token.OpenSquare); !ok {
+		return errExpr
+	}
+
+	array := &ast.ArrayExpression{Token: p.curToken}
+	for !p.peekTokenIs(token.CloseSquare) {
+		p.nextToken()
+		array.Elements = append(array.Elements, p.parseNestedExpression())
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if ok, _ := p.expectPeek(token.CloseSquare); !ok {
+		return &ast.ErrorExpression{InvalidToken: p.curToken}
+	}
+
+	if len(array.Elements) == 0 {
+		return p.exprError(array.Token, "an array expression needs at least one element")
+	}
+
+	return array
+}
+
+// Parses an index `a[i]` or a slice `a[start..end]`, where start and end are optional
+func (p *Parser) parseIndexExpression(lhs ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	var start ast.Expression
+	if !p.peekTokenIs(token.DotDot) {
+		p.nextToken()
+		start = p.parseNestedExpression()
+	}
+
+	if !p.peekTokenIs(token.DotDot) {
+		if ok, _ := p.expectPeek(token.CloseSquare); !ok {
+			return &ast.ErrorExpression{InvalidToken: p.curToken}
+		}
+		return &ast.IndexExpression{Token: tok, Expression: lhs, Index: start}
+	}
+
+	p.nextToken()
+	slice := &ast.SliceExpression{Token: tok, Expression: lhs, Start: start}
+	if !p.peekTokenIs(token.CloseSquare) {
+		p.nextToken()
+		slice.End = p.parseNestedExpression()
+	}
+
+	if ok, _ := p.expectPeek(token.CloseSquare); !ok {
+		return &ast.ErrorExpression{InvalidToken: p.curToken}
+	}
+	return slice
+}
+
+// Unary
+
+func (p *Parser) parseUnaryExpression() ast.Expression {
+	var op ast.UnaryOperator
+	switch p.curToken.Type {
+	case token.Minus:
+		op = ast.Negate
+	case token.Bang:
+		op = ast.Not
+	case token.Tilde:
+		op = ast.Complement
+	default:
+		return p.exprError(p.curToken, "invalid token for unary expression %s", p.curToken.Type)
+	}
+	tok := p.curToken
+
+	p.nextToken()
+	p.negated = op == ast.Negate && p.curTokenIs(token.Int)
+	operand := p.parseExpression(PrecPrefix)
+
+	return &ast.UnaryExpression{Operand: operand, Operator: op, Token: tok}
+}
+
+func (p *Parser) parseAddressOfExpression() ast.Expression {
+	tok := p.curToken
+
+	p.nextToken()
+	expr := p.parseExpression(PrecPrefix)
+
+	return &ast.AddressOfExpression{Token: tok, Expression: expr}
+}
+
+func (p *Parser) parseDereferenceExpression() ast.Expression {
+	tok := p.curToken
+
+	p.nextToken()
+	expr := p.parseExpression(PrecPrefix)
+
+	return &ast.DereferenceExpression{Token: tok, Expression: expr}
+}
+
+// Cast
+
+func (p *Parser) parseCastExpression(lhs ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	p.nextToken()
+	t, ok := p.parseType()
+	if !ok {
+		return &ast.ErrorExpression{InvalidToken: p.curToken}
+	}
+
+	return &ast.CastExpression{Token: tok, Expression: lhs, Type: t}
 }
 
 // Binary
@@ -481,6 +1625,20 @@ func (p *Parser) parseBinaryExpression(lhs ast.Expression) ast.Expression {
 		op = ast.Multiply
 	case token.Slash:
 		op = ast.Divide
+	case token.Percent:
+		op = ast.Modulo
+	case token.Ampersand:
+		op = ast.BitwiseAnd
+	case token.Pipe:
+		op = ast.BitwiseOr
+	case token.Caret:
+		op = ast.BitwiseXor
+	case token.ShiftLeft:
+		op = ast.ShiftLeft
+	case token.ShiftRight:
+		op = ast.ShiftRight
+	case token.TripleGreater:
+		op = ast.ShiftRightLogical
 	case token.DoubleEqual:
 		op = ast.Equal
 	case token.NotEqual:
@@ -493,6 +1651,10 @@ func (p *Parser) parseBinaryExpression(lhs ast.Expression) ast.Expression {
 		op = ast.GreaterThan
 	case token.GreaterThanEqual:
 		op = ast.GreaterThanEqual
+	case token.DoubleAmpersand:
+		op = ast.LogicalAnd
+	case token.DoublePipe:
+		op = ast.LogicalOr
 	default:
 		return p.exprError(p.curToken, "invalid token for binary expression %s", p.curToken.Type)
 	}
@@ -506,17 +1668,30 @@ func (p *Parser) parseBinaryExpression(lhs ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseAssignmentExpression(lhs ast.Expression) ast.Expression {
-	if ok, errExpr := p.expect(token.Equal); !ok {
-		return errExpr
-	}
-
 	varAss := &ast.AssignmentExpression{
 		Token: p.curToken,
 		Lhs:   lhs,
 	}
 
+	switch p.curToken.Type {
+	case token.Equal:
+	case token.PlusEqual:
+		varAss.Operator, varAss.Compound = ast.Add, true
+	case token.MinusEqual:
+		varAss.Operator, varAss.Compound = ast.Subtract, true
+	case token.AsteriskEqual:
+		varAss.Operator, varAss.Compound = ast.Multiply, true
+	case token.SlashEqual:
+		varAss.Operator, varAss.Compound = ast.Divide, true
+	case token.PercentEqual:
+		varAss.Operator, varAss.Compound = ast.Modulo, true
+	default:
+		return p.exprError(p.curToken, "invalid token for assignment expression %s", p.curToken.Type)
+	}
+
 	p.nextToken()
 
+	// Parsing the rhs with the lowest precedence makes "a = b = c" assign to b first
 	varAss.Rhs = p.parseExpression(PrecLowest)
 
 	return varAss
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"slices"
 	"testing"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
@@ -60,11 +61,123 @@ func expectDeclaration(t *testing.T, expected ast.Declaration, actual ast.Declar
 		if actual.Name != expected.Name {
 			t.Errorf("expected function name %s, got %s", expected.Name, actual.Name)
 		}
+		if actual.Public != expected.Public {
+			t.Errorf("expected function %s to be public %t, got %t", expected.Name, expected.Public, actual.Public)
+		}
+		if actual.ReturnType != expected.ReturnType && expected.ReturnType != "" {
+			t.Errorf("expected function return type %s, got %s", expected.ReturnType, actual.ReturnType)
+		}
+		if !slices.Equal(actual.TypeParameters, expected.TypeParameters) {
+			t.Errorf("expected function %s to have the type parameters %v, got %v", expected.Name, expected.TypeParameters, actual.TypeParameters)
+		}
 
 		expectExpression(t, expected.Body, actual.Body)
+	case *ast.StructDeclaration:
+		actual, ok := actual.(*ast.StructDeclaration)
+		if !ok {
+			t.Errorf("expected struct declaration, got %T", actual)
+			return
+		}
+		if actual.Name != expected.Name {
+			t.Errorf("expected struct name %s, got %s", expected.Name, actual.Name)
+		}
+
+		if len(expected.Fields) != len(actual.Fields) {
+			t.Errorf("expected struct with %d fields, got %d", len(expected.Fields), len(actual.Fields))
+			return
+		}
+		for i, field := range expected.Fields {
+			if field != actual.Fields[i] {
+				t.Errorf("expected field %v, got %v", field, actual.Fields[i])
+			}
+		}
+	case *ast.EnumDeclaration:
+		actual, ok := actual.(*ast.EnumDeclaration)
+		if !ok {
+			t.Errorf("expected enum declaration, got %T", actual)
+			return
+		}
+		if actual.Name != expected.Name {
+			t.Errorf("expected enum name %s, got %s", expected.Name, actual.Name)
+		}
+
+		if len(expected.Variants) != len(actual.Variants) {
+			t.Errorf("expected enum with %d variants, got %d", len(expected.Variants), len(actual.Variants))
+			return
+		}
+		for i, variant := range expected.Variants {
+			if variant.Name != actual.Variants[i].Name || fmt.Sprint(variant.Fields) != fmt.Sprint(actual.Variants[i].Fields) {
+				t.Errorf("expected variant %v, got %v", variant, actual.Variants[i])
+			}
+		}
+	case *ast.ConstDeclaration:
+		actual, ok := actual.(*ast.ConstDeclaration)
+		if !ok {
+			t.Errorf("expected const declaration, got %T", actual)
+			return
+		}
+		if actual.Name != expected.Name {
+			t.Errorf("expected const name %s, got %s", expected.Name, actual.Name)
+		}
+		if actual.Type != expected.Type {
+			t.Errorf("expected const type %s, got %s", expected.Type, actual.Type)
+		}
+
+		expectExpression(t, expected.Value, actual.Value)
+	case *ast.GlobalDeclaration:
+		actual, ok := actual.(*ast.GlobalDeclaration)
+		if !ok {
+			t.Errorf("expected global declaration, got %T", actual)
+			return
+		}
+		if actual.Name != expected.Name {
+			t.Errorf("expected global name %s, got %s", expected.Name, actual.Name)
+		}
+		if actual.Type != expected.Type {
+			t.Errorf("expected global type %s, got %s", expected.Type, actual.Type)
+		}
+
+		expectExpression(t, expected.Value, actual.Value)
+	case *ast.ExternFunctionDeclaration:
+		actual, ok := actual.(*ast.ExternFunctionDeclaration)
+		if !ok {
+			t.Errorf("expected extern function declaration, got %T", actual)
+			return
+		}
+		if actual.Name != expected.Name || actual.ReturnType != expected.ReturnType || actual.Public != expected.Public {
+			t.Errorf("expected extern function %s, got %s", expected, actual)
+		}
+		if !sameParameters(expected.Parameters, actual.Parameters) {
+			t.Errorf("expected parameters %v, got %v", expected.Parameters, actual.Parameters)
+		}
+	case *ast.ModuleDeclaration:
+		actual, ok := actual.(*ast.ModuleDeclaration)
+		if !ok {
+			t.Errorf("expected module declaration, got %T", actual)
+			return
+		}
+		if actual.Name != expected.Name {
+			t.Errorf("expected module name %s, got %s", expected.Name, actual.Name)
+		}
+	case *ast.ImportDeclaration:
+		actual, ok := actual.(*ast.ImportDeclaration)
+		if !ok {
+			t.Errorf("expected import declaration, got %T", actual)
+			return
+		}
+		if fmt.Sprint(actual.Path) != fmt.Sprint(expected.Path) {
+			t.Errorf("expected import of %v, got %v", expected.Path, actual.Path)
+		}
 	}
 }
 
+// Compares the names and types of the parameters, the tokens are not compared
+func sameParameters(expected []ast.Parameter, actual []ast.Parameter) bool {
+	return slices.EqualFunc(expected, actual, func(e ast.Parameter, a ast.Parameter) bool {
+		return e.Name == a.Name && e.Type == a.Type
+	})
+}
+
 func expectExpression(t *testing.T, expected ast.Expression, actual ast.Expression) {
 	t.Helper()
 
@@ -94,6 +207,40 @@ func expectExpression(t *testing.T, expected ast.Expression, actual ast.Expressi
 		if integerExpr.Value != expected.Value {
 			t.Errorf("expected integer value %d, got %d", expected.Value, integerExpr.Value)
 		}
+		if integerExpr.Type != expected.Type {
+			t.Errorf("expected integer type %q, got %q", expected.Type, integerExpr.Type)
+		}
+	case *ast.FloatExpression:
+		floatExpr, ok := actual.(*ast.FloatExpression)
+		if !ok {
+			t.Errorf("expected *ast.FloatExpression, got %T", actual)
+			return
+		}
+		if floatExpr.Value != expected.Value {
+			t.Errorf("expected float value %g, got %g", expected.Value, floatExpr.Value)
+		}
+	case *ast.UnaryExpression:
+		unaryExpr, ok := actual.(*ast.UnaryExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if unaryExpr.Operator != expected.Operator {
+			t.Errorf("expected %q operator for unary expression, got %q", expected.Operator.SymbolString(), unaryExpr.Operator.SymbolString())
+		}
+		expectExpression(t, expected.Operand, unaryExpr.Operand)
+	case *ast.CastExpression:
+		castExpr, ok := actual.(*ast.CastExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if castExpr.Type != expected.Type {
+			t.Errorf("expected cast to type %q, got %q", expected.Type, castExpr.Type)
+		}
+		expectExpression(t, expected.Expression, castExpr.Expression)
 	case *ast.BinaryExpression:
 		binaryExpr, ok := actual.(*ast.BinaryExpression)
 		if !ok {
@@ -106,6 +253,62 @@ func expectExpression(t *testing.T, expected ast.Expression, actual ast.Expressi
 		}
 		expectExpression(t, expected.Lhs, binaryExpr.Lhs)
 		expectExpression(t, expected.Rhs, binaryExpr.Rhs)
+	case *ast.FunctionCall:
+		callExpr, ok := actual.(*ast.FunctionCall)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if expected.Identifier != callExpr.Identifier {
+			t.Errorf("expected function %q to be called, got %q", expected.Identifier, callExpr.Identifier)
+		}
+		if len(expected.Arguments) != len(callExpr.Arguments) {
+			t.Errorf("expected %d arguments, got %d", len(expected.Arguments), len(callExpr.Arguments))
+			return
+		}
+		for i, arg := range expected.Arguments {
+			expectExpression(t, arg, callExpr.Arguments[i])
+		}
+	case *ast.CallExpression:
+		callExpr, ok := actual.(*ast.CallExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Function, callExpr.Function)
+		if len(expected.Arguments) != len(callExpr.Arguments) {
+			t.Errorf("expected %d arguments, got %d", len(expected.Arguments), len(callExpr.Arguments))
+			return
+		}
+		for i, arg := range expected.Arguments {
+			expectExpression(t, arg, callExpr.Arguments[i])
+		}
+	case *ast.ClosureExpression:
+		closure, ok := actual.(*ast.ClosureExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if !sameParameters(expected.Parameters, closure.Parameters) {
+			t.Errorf("expected closure parameters %v, got %v", expected.Parameters, closure.Parameters)
+		}
+		if expected.ReturnType != closure.ReturnType {
+			t.Errorf("expected closure return type %q, got %q", expected.ReturnType, closure.ReturnType)
+		}
+		expectExpression(t, expected.Body, closure.Body)
+	case *ast.StringExpression:
+		stringExpr, ok := actual.(*ast.StringExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if stringExpr.Value != expected.Value {
+			t.Errorf("expected string %q, got %q", expected.Value, stringExpr.Value)
+		}
 	case *ast.BooleanExpression:
 		booleanExpr, ok := actual.(*ast.BooleanExpression)
 		if !ok {
@@ -146,6 +349,10 @@ func expectExpression(t *testing.T, expected ast.Expression, actual ast.Expressi
 			t.Errorf("expected variable type to be %q, got %q", expected.Type, varDecl.Type)
 		}
 
+		if expected.Mutable != varDecl.Mutable {
+			t.Errorf("expected variable mutable to be %t, got %t", expected.Mutable, varDecl.Mutable)
+		}
+
 		expectExpression(t, expected.InitializingExpression, varDecl.InitializingExpression)
 	case *ast.VariableReference:
 		varRef, ok := actual.(*ast.VariableReference)
@@ -158,6 +365,191 @@ func expectExpression(t *testing.T, expected ast.Expression, actual ast.Expressi
 		if expected.Identifier != varRef.Identifier {
 			t.Errorf("expected variable reference identifier to be %q but got %q", expected.Identifier, varRef.Identifier)
 		}
+	case *ast.WhileExpression:
+		whileExpr, ok := actual.(*ast.WhileExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Condition, whileExpr.Condition)
+		expectExpression(t, expected.Body, whileExpr.Body)
+		expectExpression(t, expected.Else, whileExpr.Else)
+	case *ast.ForExpression:
+		forExpr, ok := actual.(*ast.ForExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if expected.Identifier != forExpr.Identifier {
+			t.Errorf("expected loop variable to be %q, got %q", expected.Identifier, forExpr.Identifier)
+		}
+		if expected.Inclusive != forExpr.Inclusive {
+			t.Errorf("expected inclusive to be %v, got %v", expected.Inclusive, forExpr.Inclusive)
+		}
+
+		expectExpression(t, expected.Start, forExpr.Start)
+		expectExpression(t, expected.End, forExpr.End)
+		expectExpression(t, expected.Body, forExpr.Body)
+	case *ast.IfExpression:
+		ifExpr, ok := actual.(*ast.IfExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Condition, ifExpr.Condition)
+		expectExpression(t, expected.Then, ifExpr.Then)
+		expectExpression(t, expected.Else, ifExpr.Else)
+	case *ast.BreakExpression:
+		breakExpr, ok := actual.(*ast.BreakExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Value, breakExpr.Value)
+	case *ast.ContinueExpression:
+		if _, ok := actual.(*ast.ContinueExpression); !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+		}
+	case *ast.ReturnExpression:
+		returnExpr, ok := actual.(*ast.ReturnExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Value, returnExpr.Value)
+	case *ast.AssignmentExpression:
+		assignExpr, ok := actual.(*ast.AssignmentExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if expected.Compound != assignExpr.Compound || expected.Operator != assignExpr.Operator {
+			t.Errorf("expected assignment %q, got %q", expected, assignExpr)
+		}
+		expectExpression(t, expected.Lhs, assignExpr.Lhs)
+		expectExpression(t, expected.Rhs, assignExpr.Rhs)
+	case *ast.StructExpression:
+		structExpr, ok := actual.(*ast.StructExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if expected.Name != structExpr.Name {
+			t.Errorf("expected struct name to be %q, got %q", expected.Name, structExpr.Name)
+		}
+		if len(expected.Fields) != len(structExpr.Fields) {
+			t.Errorf("expected struct expression with %d fields, got %d", len(expected.Fields), len(structExpr.Fields))
+			return
+		}
+		for i, field := range expected.Fields {
+			if field.Name != structExpr.Fields[i].Name {
+				t.Errorf("expected field name to be %q, got %q", field.Name, structExpr.Fields[i].Name)
+			}
+			expectExpression(t, field.Value, structExpr.Fields[i].Value)
+		}
+	case *ast.FieldAccessExpression:
+		fieldExpr, ok := actual.(*ast.FieldAccessExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if expected.Field != fieldExpr.Field {
+			t.Errorf("expected field to be %q, got %q", expected.Field, fieldExpr.Field)
+		}
+		expectExpression(t, expected.Expression, fieldExpr.Expression)
+	case *ast.ArrayExpression:
+		arrayExpr, ok := actual.(*ast.ArrayExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if len(expected.Elements) != len(arrayExpr.Elements) {
+			t.Errorf("expected array expression with %d elements, got %d", len(expected.Elements), len(arrayExpr.Elements))
+			return
+		}
+		for i, element := range expected.Elements {
+			expectExpression(t, element, arrayExpr.Elements[i])
+		}
+	case *ast.IndexExpression:
+		indexExpr, ok := actual.(*ast.IndexExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Expression, indexExpr.Expression)
+		expectExpression(t, expected.Index, indexExpr.Index)
+	case *ast.SliceExpression:
+		sliceExpr, ok := actual.(*ast.SliceExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Expression, sliceExpr.Expression)
+		expectExpression(t, expected.Start, sliceExpr.Start)
+		expectExpression(t, expected.End, sliceExpr.End)
+	case *ast.EnumExpression:
+		enumExpr, ok := actual.(*ast.EnumExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		if enumExpr.Enum != expected.Enum || enumExpr.Variant != expected.Variant {
+			t.Errorf("expected variant %s::%s, got %s::%s", expected.Enum, expected.Variant, enumExpr.Enum, enumExpr.Variant)
+		}
+		if len(enumExpr.Arguments) != len(expected.Arguments) {
+			t.Errorf("expected %d arguments, got %d", len(expected.Arguments), len(enumExpr.Arguments))
+			return
+		}
+		for i, arg := range expected.Arguments {
+			expectExpression(t, arg, enumExpr.Arguments[i])
+		}
+	case *ast.MatchExpression:
+		matchExpr, ok := actual.(*ast.MatchExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Expression, matchExpr.Expression)
+		if len(matchExpr.Arms) != len(expected.Arms) {
+			t.Errorf("expected %d arms, got %d", len(expected.Arms), len(matchExpr.Arms))
+			return
+		}
+		for i, arm := range expected.Arms {
+			actualArm := matchExpr.Arms[i]
+			if actualArm.Variant != arm.Variant || fmt.Sprint(actualArm.Bindings) != fmt.Sprint(arm.Bindings) {
+				t.Errorf("expected arm %s%v, got %s%v", arm.Variant, arm.Bindings, actualArm.Variant, actualArm.Bindings)
+			}
+			expectExpression(t, arm.Body, actualArm.Body)
+		}
+	case *ast.AddressOfExpression:
+		addressOfExpr, ok := actual.(*ast.AddressOfExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Expression, addressOfExpr.Expression)
+	case *ast.DereferenceExpression:
+		derefExpr, ok := actual.(*ast.DereferenceExpression)
+		if !ok {
+			t.Errorf("expected %T, got %T", expected, actual)
+			return
+		}
+
+		expectExpression(t, expected.Expression, derefExpr.Expression)
 	default:
 		t.Fatalf("unknown expression type %T", expected)
 	}
@@ -246,7 +638,7 @@ func TestGroupedExpression(t *testing.T) {
 
 func TestVariableExpression(t *testing.T) {
 	test := parserTest{
-		input: "fn main(): i64 = { x : i64 = 3; x };",
+		input: "fn main(): i64 = { x : i64 = 3; mut y := x; y };",
 		expectedProgram: ast.Program{
 			Declarations: []ast.Declaration{
 				&ast.FunctionDeclaration{
@@ -258,8 +650,106 @@ func TestVariableExpression(t *testing.T) {
 								Identifier:             "x",
 								Type:                   "i64",
 							},
+							&ast.VariableDeclaration{
+								InitializingExpression: &ast.VariableReference{Identifier: "x"},
+								Identifier:             "y",
+								Mutable:                true,
+							},
+						},
+						ReturnExpression: &ast.VariableReference{Identifier: "y"},
+					},
+				},
+			},
+		},
+	}
+	runParserTest(test, t)
+}
+
+func TestWhileExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = while true { continue; break 3 } else 4;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.WhileExpression{
+						Condition: &ast.BooleanExpression{Value: true},
+						Body: &ast.BlockExpression{
+							Expressions: []ast.Expression{
+								&ast.ContinueExpression{},
+							},
+							ReturnExpression: &ast.BreakExpression{Value: &ast.IntegerExpression{Value: 3}},
+						},
+						Else: &ast.IntegerExpression{Value: 4},
+					},
+				},
+			},
+		},
+	}
+	runParserTest(test, t)
+}
+
+func TestReturnExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = { if true { return; }; return 1 + 2 };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.IfExpression{
+								Condition: &ast.BooleanExpression{Value: true},
+								Then: &ast.BlockExpression{
+									Expressions: []ast.Expression{&ast.ReturnExpression{}},
+								},
+							},
+						},
+						ReturnExpression: &ast.ReturnExpression{
+							Value: &ast.BinaryExpression{
+								Lhs:      &ast.IntegerExpression{Value: 1},
+								Rhs:      &ast.IntegerExpression{Value: 2},
+								Operator: ast.Add,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	runParserTest(test, t)
+}
+
+func TestForExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = { for i in 0..10 { i }; for j in 1 + 1..=n {} };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.ForExpression{
+								Identifier: "i",
+								Start:      &ast.IntegerExpression{Value: 0},
+								End:        &ast.IntegerExpression{Value: 10},
+								Body: &ast.BlockExpression{
+									Expressions:      []ast.Expression{},
+									ReturnExpression: &ast.VariableReference{Identifier: "i"},
+								},
+							},
+						},
+						ReturnExpression: &ast.ForExpression{
+							Identifier: "j",
+							Start: &ast.BinaryExpression{
+								Lhs:      &ast.IntegerExpression{Value: 1},
+								Rhs:      &ast.IntegerExpression{Value: 1},
+								Operator: ast.Add,
+							},
+							End:       &ast.VariableReference{Identifier: "n"},
+							Inclusive: true,
+							Body:      &ast.BlockExpression{Expressions: []ast.Expression{}},
 						},
-						ReturnExpression: &ast.VariableReference{Identifier: "x"},
 					},
 				},
 			},
@@ -267,3 +757,716 @@ func TestVariableExpression(t *testing.T) {
 	}
 	runParserTest(test, t)
 }
+
+func TestUnaryExpressions(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = -3 * ~2 == 3 - -2;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BinaryExpression{
+						Lhs: &ast.BinaryExpression{
+							Lhs:      &ast.UnaryExpression{Operand: &ast.IntegerExpression{Value: 3}, Operator: ast.Negate},
+							Rhs:      &ast.UnaryExpression{Operand: &ast.IntegerExpression{Value: 2}, Operator: ast.Complement},
+							Operator: ast.Multiply,
+						},
+						Rhs: &ast.BinaryExpression{
+							Lhs:      &ast.IntegerExpression{Value: 3},
+							Rhs:      &ast.UnaryExpression{Operand: &ast.IntegerExpression{Value: 2}, Operator: ast.Negate},
+							Operator: ast.Subtract,
+						},
+						Operator: ast.Equal,
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): bool = a || b && 1 < 2;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BinaryExpression{
+						Lhs: &ast.VariableReference{Identifier: "a"},
+						Rhs: &ast.BinaryExpression{
+							Lhs: &ast.VariableReference{Identifier: "b"},
+							Rhs: &ast.BinaryExpression{
+								Lhs:      &ast.IntegerExpression{Value: 1},
+								Rhs:      &ast.IntegerExpression{Value: 2},
+								Operator: ast.LessThan,
+							},
+							Operator: ast.LogicalAnd,
+						},
+						Operator: ast.LogicalOr,
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestBitwiseExpressions(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = 1 | 2 ^ 3 & 4 << 5 + 6 % 7;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BinaryExpression{
+						Lhs: &ast.IntegerExpression{Value: 1},
+						Rhs: &ast.BinaryExpression{
+							Lhs: &ast.IntegerExpression{Value: 2},
+							Rhs: &ast.BinaryExpression{
+								Lhs: &ast.IntegerExpression{Value: 3},
+								Rhs: &ast.BinaryExpression{
+									Lhs: &ast.IntegerExpression{Value: 4},
+									Rhs: &ast.BinaryExpression{
+										Lhs: &ast.IntegerExpression{Value: 5},
+										Rhs: &ast.BinaryExpression{
+											Lhs:      &ast.IntegerExpression{Value: 6},
+											Rhs:      &ast.IntegerExpression{Value: 7},
+											Operator: ast.Modulo,
+										},
+										Operator: ast.Add,
+									},
+									Operator: ast.ShiftLeft,
+								},
+								Operator: ast.BitwiseAnd,
+							},
+							Operator: ast.BitwiseXor,
+						},
+						Operator: ast.BitwiseOr,
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestCastExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): u8 = -x as u8 * 2 as u8;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BinaryExpression{
+						Lhs: &ast.CastExpression{
+							Expression: &ast.UnaryExpression{Operand: &ast.VariableReference{Identifier: "x"}, Operator: ast.Negate},
+							Type:       "u8",
+						},
+						Rhs:      &ast.CastExpression{Expression: &ast.IntegerExpression{Value: 2}, Type: "u8"},
+						Operator: ast.Multiply,
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestStructDeclaration(t *testing.T) {
+	test := parserTest{
+		input: "struct Point { x: i64, y: i64, visible: bool, }; fn main(): i64 = 0;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.StructDeclaration{
+					Name: "Point",
+					Fields: []ast.StructField{
+						{Name: "x", Type: "i64"},
+						{Name: "y", Type: "i64"},
+						{Name: "visible", Type: "bool"},
+					},
+				},
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.IntegerExpression{Value: 0},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestStructExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = { p := Point { x: 1, y: 2 }; p.x = p.y; while visible { p.inner.x } };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.VariableDeclaration{
+								Identifier: "p",
+								InitializingExpression: &ast.StructExpression{
+									Name: "Point",
+									Fields: []ast.StructExpressionField{
+										{Name: "x", Value: &ast.IntegerExpression{Value: 1}},
+										{Name: "y", Value: &ast.IntegerExpression{Value: 2}},
+									},
+								},
+							},
+							&ast.AssignmentExpression{
+								Lhs: &ast.FieldAccessExpression{Expression: &ast.VariableReference{Identifier: "p"}, Field: "x"},
+								Rhs: &ast.FieldAccessExpression{Expression: &ast.VariableReference{Identifier: "p"}, Field: "y"},
+							},
+						},
+						ReturnExpression: &ast.WhileExpression{
+							Condition: &ast.VariableReference{Identifier: "visible"},
+							Body: &ast.BlockExpression{
+								ReturnExpression: &ast.FieldAccessExpression{
+									Expression: &ast.FieldAccessExpression{Expression: &ast.VariableReference{Identifier: "p"}, Field: "inner"},
+									Field:      "x",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestArrayExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = { a : [3]i64 = [1, 2, 3]; s : []i64 = a[1..]; a[0] = s[..2][1]; a[..] };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.VariableDeclaration{
+								Identifier: "a",
+								Type:       "[3]i64",
+								InitializingExpression: &ast.ArrayExpression{
+									Elements: []ast.Expression{
+										&ast.IntegerExpression{Value: 1},
+										&ast.IntegerExpression{Value: 2},
+										&ast.IntegerExpression{Value: 3},
+									},
+								},
+							},
+							&ast.VariableDeclaration{
+								Identifier: "s",
+								Type:       "[]i64",
+								InitializingExpression: &ast.SliceExpression{
+									Expression: &ast.VariableReference{Identifier: "a"},
+									Start:      &ast.IntegerExpression{Value: 1},
+								},
+							},
+							&ast.AssignmentExpression{
+								Lhs: &ast.IndexExpression{Expression: &ast.VariableReference{Identifier: "a"}, Index: &ast.IntegerExpression{Value: 0}},
+								Rhs: &ast.IndexExpression{
+									Expression: &ast.SliceExpression{
+										Expression: &ast.VariableReference{Identifier: "s"},
+										End:        &ast.IntegerExpression{Value: 2},
+									},
+									Index: &ast.IntegerExpression{Value: 1},
+								},
+							},
+						},
+						ReturnExpression: &ast.SliceExpression{Expression: &ast.VariableReference{Identifier: "a"}},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestStringExpression(t *testing.T) {
+	test := parserTest{
+		input: `fn main(): str = { print("hello\n"); s : str = "a\"b"; s };`,
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.FunctionCall{
+								Identifier: "print",
+								Arguments:  []ast.Expression{&ast.StringExpression{Value: "hello\n"}},
+							},
+							&ast.VariableDeclaration{
+								Identifier:             "s",
+								Type:                   "str",
+								InitializingExpression: &ast.StringExpression{Value: "a\"b"},
+							},
+						},
+						ReturnExpression: &ast.VariableReference{Identifier: "s"},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestFloatExpression(t *testing.T) {
+	test := parserTest{
+		input: `fn main(): f64 = 1.5 * 2e3 + 0.25e-2 as f64;`,
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name:       "main",
+					ReturnType: "f64",
+					Body: &ast.BinaryExpression{
+						Lhs:      &ast.BinaryExpression{Lhs: &ast.FloatExpression{Value: 1.5}, Rhs: &ast.FloatExpression{Value: 2000}, Operator: ast.Multiply},
+						Rhs:      &ast.CastExpression{Expression: &ast.FloatExpression{Value: 0.0025}, Type: "f64"},
+						Operator: ast.Add,
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestIntegerExpression(t *testing.T) {
+	test := parserTest{
+		input: `fn main(): i64 = 0xff_ffu16 + 0o17 + 0b1010 + 1_000 + -128i8 + 0xffff_ffff_ffff_ffffu64 + 010;`,
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name:       "main",
+					ReturnType: "i64",
+					Body: &ast.BinaryExpression{
+						Lhs: &ast.BinaryExpression{
+							Lhs: &ast.BinaryExpression{
+								Lhs: &ast.BinaryExpression{
+									Lhs: &ast.BinaryExpression{
+										Lhs:      &ast.BinaryExpression{Lhs: &ast.IntegerExpression{Value: 0xffff, Type: "u16"}, Rhs: &ast.IntegerExpression{Value: 15}, Operator: ast.Add},
+										Rhs:      &ast.IntegerExpression{Value: 10},
+										Operator: ast.Add,
+									},
+									Rhs:      &ast.IntegerExpression{Value: 1000},
+									Operator: ast.Add,
+								},
+								Rhs:      &ast.UnaryExpression{Operand: &ast.IntegerExpression{Value: 128, Type: "i8"}, Operator: ast.Negate},
+								Operator: ast.Add,
+							},
+							Rhs:      &ast.IntegerExpression{Value: -1, Type: "u64"},
+							Operator: ast.Add,
+						},
+						Rhs:      &ast.IntegerExpression{Value: 10},
+						Operator: ast.Add,
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestIntegerLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn main(): u8 = 256u8;", "the integer literal 256u8 does not fit into u8\nfn main(): u8 = 256u8;\n                ^^^^^"},
+		{"fn main(): i8 = 128i8;", "the integer literal 128i8 does not fit into i8\nfn main(): i8 = 128i8;\n                ^^^^^"},
+		{"fn main(): u64 = 18446744073709551616;", "the integer literal 18446744073709551616 does not fit into 64 bits\nfn main(): u64 = 18446744073709551616;\n                 ^^^^^^^^^^^^^^^^^^^^"},
+		{"fn main(): i64 = 0b102;", "the integer literal 0b102 has invalid digits for base 2\nfn main(): i64 = 0b102;\n                 ^^^^^"},
+		{"fn main(): i64 = 1__0;", "a \"_\" in the integer literal 1__0 has to be between two digits\nfn main(): i64 = 1__0;\n                 ^^^^"},
+		{"fn main(): i64 = 1f32;", "unknown integer literal suffix \"f32\", only integer types like u8 or i32 can be a suffix\nfn main(): i64 = 1f32;\n                 ^^^^"},
+	}
+
+	for _, test := range tests {
+		l, err := lexer.New(test.input, "test.tt")
+		if err != nil {
+			t.Fatalf("creating lexer failed: %v", err)
+		}
+
+		p := New(l)
+		errors := []string{}
+		p.WithErrorCallback(func(tok token.Token, s string, a ...any) {
+			errors = append(errors, fmt.Sprintf(s, a...))
+		})
+		p.ParseProgram()
+
+		if len(errors) == 0 || errors[0] != test.expected {
+			t.Errorf("expected the error %q for %q, got %q", test.expected, test.input, errors)
+		}
+	}
+}
+
+func TestPointerExpression(t *testing.T) {
+	test := parserTest{
+		input: `fn main(): i64 = { p : *i64 = &x; *p = *p.y + 1; *p };`,
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.VariableDeclaration{
+								Identifier:             "p",
+								Type:                   "*i64",
+								InitializingExpression: &ast.AddressOfExpression{Expression: &ast.VariableReference{Identifier: "x"}},
+							},
+							&ast.AssignmentExpression{
+								Lhs: &ast.DereferenceExpression{Expression: &ast.VariableReference{Identifier: "p"}},
+								Rhs: &ast.BinaryExpression{
+									Lhs: &ast.DereferenceExpression{
+										Expression: &ast.FieldAccessExpression{
+											Expression: &ast.VariableReference{Identifier: "p"},
+											Field:      "y",
+										},
+									},
+									Operator: ast.Add,
+									Rhs:      &ast.IntegerExpression{Value: 1},
+								},
+							},
+						},
+						ReturnExpression: &ast.DereferenceExpression{Expression: &ast.VariableReference{Identifier: "p"}},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestEnumDeclaration(t *testing.T) {
+	test := parserTest{
+		input: "enum Shape { Circle(i64), Rect(i64, [2]u8), Empty, }; fn main(): i64 = 0;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.EnumDeclaration{
+					Name: "Shape",
+					Variants: []ast.EnumVariant{
+						{Name: "Circle", Fields: []ast.Type{"i64"}},
+						{Name: "Rect", Fields: []ast.Type{"i64", "[2]u8"}},
+						{Name: "Empty"},
+					},
+				},
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.IntegerExpression{Value: 0},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestMatchExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = match Shape::Rect(1, 2) { Circle(r) => r, Rect(w, _) => { w } Empty => 0, _ => s };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.MatchExpression{
+						Expression: &ast.EnumExpression{
+							Enum:      "Shape",
+							Variant:   "Rect",
+							Arguments: []ast.Expression{&ast.IntegerExpression{Value: 1}, &ast.IntegerExpression{Value: 2}},
+						},
+						Arms: []ast.MatchArm{
+							{Variant: "Circle", Bindings: []string{"r"}, Body: &ast.VariableReference{Identifier: "r"}},
+							{Variant: "Rect", Bindings: []string{"w", "_"}, Body: &ast.BlockExpression{ReturnExpression: &ast.VariableReference{Identifier: "w"}}},
+							{Variant: "Empty", Body: &ast.IntegerExpression{Value: 0}},
+							{Variant: "_", Body: &ast.VariableReference{Identifier: "s"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestConstDeclaration(t *testing.T) {
+	test := parserTest{
+		input: "const SIZE: i64 = 4 * 8; fn main(): i64 = SIZE;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.ConstDeclaration{
+					Name: "SIZE",
+					Type: "i64",
+					Value: &ast.BinaryExpression{
+						Lhs:      &ast.IntegerExpression{Value: 4},
+						Rhs:      &ast.IntegerExpression{Value: 8},
+						Operator: ast.Multiply,
+					},
+				},
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.VariableReference{Identifier: "SIZE"},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestGlobalDeclaration(t *testing.T) {
+	test := parserTest{
+		input: "var counter: i64 = 0; var table: [2]i64 = [1, 2]; fn main(): i64 = counter;",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.GlobalDeclaration{
+					Name:  "counter",
+					Type:  "i64",
+					Value: &ast.IntegerExpression{Value: 0},
+				},
+				&ast.GlobalDeclaration{
+					Name: "table",
+					Type: "[2]i64",
+					Value: &ast.ArrayExpression{
+						Elements: []ast.Expression{&ast.IntegerExpression{Value: 1}, &ast.IntegerExpression{Value: 2}},
+					},
+				},
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.VariableReference{Identifier: "counter"},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestModuleDeclarations(t *testing.T) {
+	test := parserTest{
+		input: "mod app; import util; import geo.shapes; pub fn area(s: shapes.Shape): shapes.Point = shapes.Point { x: util.double(util.SIZE), y: shapes.Shape::Empty };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.ModuleDeclaration{Name: "app"},
+				&ast.ImportDeclaration{Path: []string{"util"}},
+				&ast.ImportDeclaration{Path: []string{"geo", "shapes"}},
+				&ast.FunctionDeclaration{
+					Name:       "area",
+					Public:     true,
+					ReturnType: "shapes.Point",
+					Body: &ast.StructExpression{
+						Name: "shapes.Point",
+						Fields: []ast.StructExpressionField{
+							{Name: "x", Value: &ast.FunctionCall{
+								Identifier: "util.double",
+								Arguments:  []ast.Expression{&ast.VariableReference{Identifier: "util.SIZE"}},
+							}},
+							{Name: "y", Value: &ast.EnumExpression{Enum: "shapes.Shape", Variant: "Empty"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestGenericFunctionDeclaration(t *testing.T) {
+	test := parserTest{
+		input: "fn max[T](a: T, b: T): T = a; pub fn pair[A, B](a: *A, b: []B): i64 = 0; fn main(): i64 = max(1, 2);",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name:           "max",
+					TypeParameters: []string{"T"},
+					ReturnType:     "T",
+					Body:           &ast.VariableReference{Identifier: "a"},
+				},
+				&ast.FunctionDeclaration{
+					Name:           "pair",
+					TypeParameters: []string{"A", "B"},
+					ReturnType:     "i64",
+					Public:         true,
+					Body:           &ast.IntegerExpression{Value: 0},
+				},
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.FunctionCall{Identifier: "max", Arguments: []ast.Expression{&ast.IntegerExpression{Value: 1}, &ast.IntegerExpression{Value: 2}}},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestFunctionValues(t *testing.T) {
+	test := parserTest{
+		input: "fn compose(f: fn(i64): i64, g: fn(i64, fn(): bool): *i64): fn(i64): i64 = f; fn main(): i64 = { g: fn(i64): i64 = fn(x: i64): i64 = x + 1; g(1)(2) + (fn(): i64 = 3)() };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name:       "compose",
+					ReturnType: "fn(i64): i64",
+					Body:       &ast.VariableReference{Identifier: "f"},
+				},
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.VariableDeclaration{
+								Identifier: "g",
+								Type:       "fn(i64): i64",
+								InitializingExpression: &ast.ClosureExpression{
+									Parameters: []ast.Parameter{{Name: "x", Type: "i64"}},
+									ReturnType: "i64",
+									Body:       &ast.BinaryExpression{Lhs: &ast.VariableReference{Identifier: "x"}, Rhs: &ast.IntegerExpression{Value: 1}, Operator: ast.Add},
+								},
+							},
+						},
+						ReturnExpression: &ast.BinaryExpression{
+							Lhs: &ast.CallExpression{
+								Function:  &ast.FunctionCall{Identifier: "g", Arguments: []ast.Expression{&ast.IntegerExpression{Value: 1}}},
+								Arguments: []ast.Expression{&ast.IntegerExpression{Value: 2}},
+							},
+							Rhs: &ast.CallExpression{
+								Function: &ast.ClosureExpression{
+									Parameters: []ast.Parameter{},
+									ReturnType: "i64",
+									Body:       &ast.IntegerExpression{Value: 3},
+								},
+							},
+							Operator: ast.Add,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestPipeExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = a || b |> f(b) |> g(c, |) |> h(|, 1);",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.FunctionCall{
+						Identifier: "h",
+						Arguments: []ast.Expression{
+							&ast.BlockExpression{
+								Expressions: []ast.Expression{
+									&ast.VariableDeclaration{
+										Identifier: "pipe.1",
+										InitializingExpression: &ast.FunctionCall{
+											Identifier: "f",
+											Arguments: []ast.Expression{
+												&ast.BinaryExpression{Lhs: &ast.VariableReference{Identifier: "a"}, Rhs: &ast.VariableReference{Identifier: "b"}, Operator: ast.LogicalOr},
+												&ast.VariableReference{Identifier: "b"},
+											},
+										},
+									},
+								},
+								ReturnExpression: &ast.FunctionCall{
+									Identifier: "g",
+									Arguments: []ast.Expression{
+										&ast.VariableReference{Identifier: "c"},
+										&ast.VariableReference{Identifier: "pipe.1"},
+									},
+								},
+							},
+							&ast.IntegerExpression{Value: 1},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestAssignmentExpression(t *testing.T) {
+	test := parserTest{
+		input: "fn main(): i64 = { a = b = c + 1; *p -= 2; a[i] %= a[j] * 3; };",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.BlockExpression{
+						Expressions: []ast.Expression{
+							&ast.AssignmentExpression{
+								Lhs: &ast.VariableReference{Identifier: "a"},
+								Rhs: &ast.AssignmentExpression{
+									Lhs: &ast.VariableReference{Identifier: "b"},
+									Rhs: &ast.BinaryExpression{Lhs: &ast.VariableReference{Identifier: "c"}, Rhs: &ast.IntegerExpression{Value: 1}, Operator: ast.Add},
+								},
+							},
+							&ast.AssignmentExpression{
+								Lhs:      &ast.DereferenceExpression{Expression: &ast.VariableReference{Identifier: "p"}},
+								Rhs:      &ast.IntegerExpression{Value: 2},
+								Compound: true,
+								Operator: ast.Subtract,
+							},
+							&ast.AssignmentExpression{
+								Lhs: &ast.IndexExpression{Expression: &ast.VariableReference{Identifier: "a"}, Index: &ast.VariableReference{Identifier: "i"}},
+								Rhs: &ast.BinaryExpression{
+									Lhs:      &ast.IndexExpression{Expression: &ast.VariableReference{Identifier: "a"}, Index: &ast.VariableReference{Identifier: "j"}},
+									Rhs:      &ast.IntegerExpression{Value: 3},
+									Operator: ast.Multiply,
+								},
+								Compound: true,
+								Operator: ast.Modulo,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
+
+func TestExternFunctionDeclaration(t *testing.T) {
+	test := parserTest{
+		input: "extern fn write(fd: i64, buf: *u8, len: i64): i64; pub extern fn exit(code: i64): i64; fn main(): i64 = exit(0);",
+		expectedProgram: ast.Program{
+			Declarations: []ast.Declaration{
+				&ast.ExternFunctionDeclaration{
+					Name:       "write",
+					Parameters: []ast.Parameter{{Name: "fd", Type: "i64"}, {Name: "buf", Type: "*u8"}, {Name: "len", Type: "i64"}},
+					ReturnType: "i64",
+				},
+				&ast.ExternFunctionDeclaration{
+					Name:       "exit",
+					Parameters: []ast.Parameter{{Name: "code", Type: "i64"}},
+					ReturnType: "i64",
+					Public:     true,
+				},
+				&ast.FunctionDeclaration{
+					Name: "main",
+					Body: &ast.FunctionCall{Identifier: "exit", Arguments: []ast.Expression{&ast.IntegerExpression{Value: 0}}},
+				},
+			},
+		},
+	}
+
+	runParserTest(test, t)
+}
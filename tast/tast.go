@@ -6,6 +6,7 @@ package tast
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
@@ -73,6 +74,8 @@ type FunctionDeclaration struct {
 	Name       string
 	Parameters []Parameter
 	ReturnType types.Type
+	// The variables and parameters, whose address is taken, they have to be stored in memory
+	AddressTaken map[string]bool
 }
 
 var _ Declaration = &FunctionDeclaration{}
@@ -84,21 +87,154 @@ func (fd *FunctionDeclaration) String() string {
 	return fmt.Sprintf("fn %v(%v): %v = %v;", fd.Name, ArgsToString(fd.Parameters), fd.ReturnType.Name(), fd.Body.String())
 }
 
+// A function, which is defined outside of the program, it is only called
+type ExternFunctionDeclaration struct {
+	Token      token.Token // The token.EXTERN
+	Name       string
+	Parameters []Parameter
+	ReturnType types.Type
+}
+
+var _ Declaration = &ExternFunctionDeclaration{}
+
+func (ed *ExternFunctionDeclaration) declarationNode()     {}
+func (ed *ExternFunctionDeclaration) TokenLiteral() string { return ed.Token.Literal }
+func (ed *ExternFunctionDeclaration) Tok() token.Token     { return ed.Token }
+func (ed *ExternFunctionDeclaration) String() string {
+	return fmt.Sprintf("extern fn %v(%v): %v;", ed.Name, ArgsToString(ed.Parameters), ed.ReturnType.Name())
+}
+
+type ConstDeclaration struct {
+	Token     token.Token // The token.CONST
+	Name      string
+	ConstType types.Type
+	Value     Expression
+	// The value of the constant, which is evaluated by the type checker.
+	// Booleans are 0 or 1
+	Result int64
+}
+
+var _ Declaration = &ConstDeclaration{}
+
+func (cd *ConstDeclaration) declarationNode()     {}
+func (cd *ConstDeclaration) TokenLiteral() string { return cd.Token.Literal }
+func (cd *ConstDeclaration) Tok() token.Token     { return cd.Token }
+func (cd *ConstDeclaration) String() string {
+	return fmt.Sprintf("const %v: %v = %v;", cd.Name, cd.ConstType.Name(), cd.Value.String())
+}
+
+type GlobalDeclaration struct {
+	Token      token.Token // The token.VAR
+	Name       string
+	GlobalType types.Type
+	Value      Expression
+	// The initial value in memory, which is evaluated by the type checker
+	Result []byte
+}
+
+var _ Declaration = &GlobalDeclaration{}
+
+func (gd *GlobalDeclaration) declarationNode()     {}
+func (gd *GlobalDeclaration) TokenLiteral() string { return gd.Token.Literal }
+func (gd *GlobalDeclaration) Tok() token.Token     { return gd.Token }
+func (gd *GlobalDeclaration) String() string {
+	return fmt.Sprintf("var %v: %v = %v;", gd.Name, gd.GlobalType.Name(), gd.Value.String())
+}
+
+type StructDeclaration struct {
+	Token      token.Token // The token.STRUCT
+	StructType *types.StructType
+}
+
+var _ Declaration = &StructDeclaration{}
+
+func (sd *StructDeclaration) declarationNode()     {}
+func (sd *StructDeclaration) TokenLiteral() string { return sd.Token.Literal }
+func (sd *StructDeclaration) Tok() token.Token     { return sd.Token }
+func (sd *StructDeclaration) String() string {
+	var b strings.Builder
+
+	for _, field := range sd.StructType.Fields {
+		b.WriteString(fmt.Sprintf(" %s: %s,", field.Name, field.Type.Name()))
+	}
+
+	return fmt.Sprintf("struct %s {%s }", sd.StructType.Name(), b.String())
+}
+
+type EnumDeclaration struct {
+	Token    token.Token // The token.ENUM
+	EnumType *types.EnumType
+}
+
+var _ Declaration = &EnumDeclaration{}
+
+func (ed *EnumDeclaration) declarationNode()     {}
+func (ed *EnumDeclaration) TokenLiteral() string { return ed.Token.Literal }
+func (ed *EnumDeclaration) Tok() token.Token     { return ed.Token }
+func (ed *EnumDeclaration) String() string {
+	var b strings.Builder
+
+	for _, variant := range ed.EnumType.Variants {
+		b.WriteString(" " + variant.Name)
+		if len(variant.Fields) > 0 {
+			fields := []string{}
+			for _, field := range variant.Fields {
+				fields = append(fields, field.Name())
+			}
+			b.WriteString("(" + strings.Join(fields, ", ") + ")")
+		}
+		b.WriteRune(',')
+	}
+
+	return fmt.Sprintf("enum %s {%s }", ed.EnumType.Name(), b.String())
+}
+
 type IntegerExpression struct {
-	Token token.Token // The token.INT
-	Value int64
+	Token     token.Token // The token.INT
+	Value     int64
+	ValueType types.Type
 }
 
 var _ Expression = &IntegerExpression{}
 
 func (ie *IntegerExpression) expressionNode() {}
 func (ie *IntegerExpression) Type() types.Type {
-	return types.I64
+	return ie.ValueType
 }
 func (ie *IntegerExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IntegerExpression) Tok() token.Token     { return ie.Token }
 func (ie *IntegerExpression) String() string       { return ie.Token.Literal }
 
+type FloatExpression struct {
+	Token token.Token // The token.FLOAT
+	Value float64
+}
+
+var _ Expression = &FloatExpression{}
+
+func (fe *FloatExpression) expressionNode() {}
+func (fe *FloatExpression) Type() types.Type {
+	return types.F64
+}
+func (fe *FloatExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *FloatExpression) Tok() token.Token     { return fe.Token }
+func (fe *FloatExpression) String() string       { return fe.Token.Literal }
+
+type StringExpression struct {
+	Token token.Token // The token.STRING
+	Value string
+}
+
+var _ Expression = &StringExpression{}
+
+func (se *StringExpression) expressionNode() {}
+func (se *StringExpression) Type() types.Type {
+	return types.Str
+}
+func (se *StringExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *StringExpression) Tok() token.Token     { return se.Token }
+func (se *StringExpression) String() string       { return strconv.Quote(se.Value) }
+
 type BooleanExpression struct {
 	Token token.Token // The token.TRUE or token.FALSE
 	Value bool
@@ -114,6 +250,79 @@ func (be *BooleanExpression) TokenLiteral() string { return be.Token.Literal }
 func (be *BooleanExpression) Tok() token.Token     { return be.Token }
 func (be *BooleanExpression) String() string       { return be.Token.Literal }
 
+type UnaryExpression struct {
+	Token      token.Token // The operator
+	Operand    Expression
+	Operator   ast.UnaryOperator
+	ResultType types.Type
+}
+
+var _ Expression = &UnaryExpression{}
+
+func (ue *UnaryExpression) expressionNode() {}
+func (ue *UnaryExpression) Type() types.Type {
+	return ue.ResultType
+}
+func (ue *UnaryExpression) TokenLiteral() string { return ue.Token.Literal }
+func (ue *UnaryExpression) Tok() token.Token     { return ue.Token }
+func (ue *UnaryExpression) String() string {
+	return fmt.Sprintf("(%s%s :> %s)", ue.Operator.SymbolString(), ue.Operand, ue.ResultType.Name())
+}
+
+type AddressOfExpression struct {
+	Token       token.Token // The '&' token
+	Expression  Expression
+	PointerType *types.PointerType
+}
+
+var _ Expression = &AddressOfExpression{}
+
+func (ae *AddressOfExpression) expressionNode() {}
+func (ae *AddressOfExpression) Type() types.Type {
+	return ae.PointerType
+}
+func (ae *AddressOfExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AddressOfExpression) Tok() token.Token     { return ae.Token }
+func (ae *AddressOfExpression) String() string {
+	return fmt.Sprintf("(&%s :> %s)", ae.Expression, ae.PointerType.Name())
+}
+
+type DereferenceExpression struct {
+	Token      token.Token // The '*' token, or the '.' token of a field access through a pointer
+	Expression Expression
+	ResultType types.Type
+}
+
+var _ Expression = &DereferenceExpression{}
+
+func (de *DereferenceExpression) expressionNode() {}
+func (de *DereferenceExpression) Type() types.Type {
+	return de.ResultType
+}
+func (de *DereferenceExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DereferenceExpression) Tok() token.Token     { return de.Token }
+func (de *DereferenceExpression) String() string {
+	return fmt.Sprintf("(*%s :> %s)", de.Expression, de.ResultType.Name())
+}
+
+type CastExpression struct {
+	Token      token.Token // The 'as' token
+	Expression Expression
+	TargetType types.Type
+}
+
+var _ Expression = &CastExpression{}
+
+func (ce *CastExpression) expressionNode() {}
+func (ce *CastExpression) Type() types.Type {
+	return ce.TargetType
+}
+func (ce *CastExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CastExpression) Tok() token.Token     { return ce.Token }
+func (ce *CastExpression) String() string {
+	return fmt.Sprintf("(%s as %s)", ce.Expression, ce.TargetType.Name())
+}
+
 type BinaryExpression struct {
 	Token      token.Token // The operator
 	Lhs, Rhs   Expression
@@ -235,10 +444,51 @@ func (vr *VariableReference) String() string {
 	return fmt.Sprintf("(%s :> %s)", vr.Identifier, vr.Type().Name())
 }
 
+type ConstReference struct {
+	Token      token.Token // The identifier token
+	Identifier string
+	ConstType  types.Type
+}
+
+var _ Expression = &ConstReference{}
+
+func (cr *ConstReference) expressionNode() {}
+func (cr *ConstReference) Type() types.Type {
+	return cr.ConstType
+}
+
+func (cr *ConstReference) TokenLiteral() string { return cr.Token.Literal }
+func (cr *ConstReference) Tok() token.Token     { return cr.Token }
+func (cr *ConstReference) String() string {
+	return fmt.Sprintf("(const %s :> %s)", cr.Identifier, cr.Type().Name())
+}
+
+type GlobalReference struct {
+	Token      token.Token // The identifier token
+	Identifier string
+	GlobalType types.Type
+}
+
+var _ Expression = &GlobalReference{}
+
+func (gr *GlobalReference) expressionNode() {}
+func (gr *GlobalReference) Type() types.Type {
+	return gr.GlobalType
+}
+
+func (gr *GlobalReference) TokenLiteral() string { return gr.Token.Literal }
+func (gr *GlobalReference) Tok() token.Token     { return gr.Token }
+func (gr *GlobalReference) String() string {
+	return fmt.Sprintf("(var %s :> %s)", gr.Identifier, gr.Type().Name())
+}
+
 type AssignmentExpression struct {
-	Token token.Token // The Equal
+	Token token.Token // The Equal or the compound operator like +=
 	Lhs   Expression
 	Rhs   Expression
+	// Set for compound assignments, "a += b" assigns "a + b" but evaluates a only once
+	Compound bool
+	Operator ast.BinaryOperator
 }
 
 var _ Expression = &AssignmentExpression{}
@@ -250,9 +500,20 @@ func (ae *AssignmentExpression) Type() types.Type {
 func (ae *AssignmentExpression) TokenLiteral() string { return ae.Token.Literal }
 func (ae *AssignmentExpression) Tok() token.Token     { return ae.Token }
 func (ae *AssignmentExpression) String() string {
+	if ae.Compound {
+		return fmt.Sprintf("%s %s= %s", ae.Lhs.String(), ae.Operator.SymbolString(), ae.Rhs.String())
+	}
 	return fmt.Sprintf("%s = %s", ae.Lhs.String(), ae.Rhs.String())
 }
 
+// The type of the value stored into the lhs, in "a = b = c" the value of "b = c" is the new b
+func (ae *AssignmentExpression) AssignedType() types.Type {
+	if rhs, ok := ae.Rhs.(*AssignmentExpression); ok {
+		return rhs.Lhs.Type()
+	}
+	return ae.Rhs.Type()
+}
+
 // identifier ( expressions... )
 type FunctionCall struct {
 	Token      token.Token // The identifier
@@ -284,3 +545,444 @@ func (fc *FunctionCall) String() string {
 
 	return b.String()
 }
+
+// A function used as a value, it has no environment
+type FunctionReference struct {
+	Token        token.Token // The identifier token
+	Identifier   string
+	FunctionType *types.FunctionType
+}
+
+var _ Expression = &FunctionReference{}
+
+func (fr *FunctionReference) expressionNode() {}
+func (fr *FunctionReference) Type() types.Type {
+	return fr.FunctionType
+}
+
+func (fr *FunctionReference) TokenLiteral() string { return fr.Token.Literal }
+func (fr *FunctionReference) Tok() token.Token     { return fr.Token }
+func (fr *FunctionReference) String() string {
+	return fmt.Sprintf("(fn %s :> %s)", fr.Identifier, fr.Type().Name())
+}
+
+// Calls a function value, which is a function reference or a closure
+type IndirectCall struct {
+	Token      token.Token // The '(' token
+	Function   Expression
+	Arguments  []Expression
+	ReturnType types.Type
+}
+
+var _ Expression = &IndirectCall{}
+
+func (ic *IndirectCall) expressionNode()      {}
+func (ic *IndirectCall) Type() types.Type     { return ic.ReturnType }
+func (ic *IndirectCall) TokenLiteral() string { return ic.Token.Literal }
+func (ic *IndirectCall) Tok() token.Token     { return ic.Token }
+func (ic *IndirectCall) String() string {
+	arguments := []string{}
+	for _, arg := range ic.Arguments {
+		arguments = append(arguments, arg.String())
+	}
+	return fmt.Sprintf("%s(%s)", ic.Function, strings.Join(arguments, ","))
+}
+
+// An anonymous function, which can use the variables around it.
+// The values of the captured variables are copied into its environment when it is created.
+type ClosureExpression struct {
+	Token token.Token // The token.FN
+	// The name of the function the closure is compiled to
+	Name       string
+	Parameters []Parameter
+	// The captured variables of the enclosing functions, in the order of the environment
+	Captures    []Parameter
+	Body        Expression
+	ClosureType *types.FunctionType
+	// The variables and parameters, whose address is taken, they have to be stored in memory
+	AddressTaken map[string]bool
+}
+
+var _ Expression = &ClosureExpression{}
+
+func (ce *ClosureExpression) expressionNode() {}
+func (ce *ClosureExpression) Type() types.Type {
+	return ce.ClosureType
+}
+func (ce *ClosureExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ClosureExpression) Tok() token.Token     { return ce.Token }
+func (ce *ClosureExpression) String() string {
+	return fmt.Sprintf("(fn %v(%v) [%v]: %v = %v)", ce.Name, ArgsToString(ce.Parameters), ArgsToString(ce.Captures), ce.ClosureType.ReturnType.Name(), ce.Body)
+}
+
+type WhileExpression struct {
+	Token     token.Token // The 'while' token
+	Condition Expression
+	Body      Expression
+	// Can be nil
+	Else       Expression
+	ReturnType types.Type
+}
+
+var _ Expression = &WhileExpression{}
+
+func (we *WhileExpression) expressionNode() {}
+func (we *WhileExpression) Type() types.Type {
+	return we.ReturnType
+}
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WhileExpression) Tok() token.Token     { return we.Token }
+func (we *WhileExpression) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("(while %s\n\t", we.Condition.String()))
+	builder.WriteString(we.Body.String())
+
+	if we.Else != nil {
+		builder.WriteString(" else in ")
+		builder.WriteString(we.Else.String())
+	}
+	builder.WriteString(fmt.Sprintf(") :> %s", we.Type().Name()))
+
+	return builder.String()
+}
+
+type BreakExpression struct {
+	Token token.Token // The 'break' token
+	// Can be nil
+	Value Expression
+	// The type of the loop this break belongs to
+	LoopType types.Type
+}
+
+var _ Expression = &BreakExpression{}
+
+func (be *BreakExpression) expressionNode() {}
+func (be *BreakExpression) Type() types.Type {
+	return types.Unit
+}
+func (be *BreakExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BreakExpression) Tok() token.Token     { return be.Token }
+func (be *BreakExpression) String() string {
+	if be.Value != nil {
+		return fmt.Sprintf("break %s", be.Value.String())
+	}
+	return "break"
+}
+
+type ContinueExpression struct {
+	Token token.Token // The 'continue' token
+}
+
+var _ Expression = &ContinueExpression{}
+
+func (ce *ContinueExpression) expressionNode() {}
+func (ce *ContinueExpression) Type() types.Type {
+	return types.Unit
+}
+func (ce *ContinueExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ContinueExpression) Tok() token.Token     { return ce.Token }
+func (ce *ContinueExpression) String() string       { return "continue" }
+
+type ReturnExpression struct {
+	Token token.Token // The 'return' token
+	// Can be nil
+	Value Expression
+	// The return type of the function this return belongs to
+	FunctionReturnType types.Type
+}
+
+var _ Expression = &ReturnExpression{}
+
+func (re *ReturnExpression) expressionNode() {}
+func (re *ReturnExpression) Type() types.Type {
+	return types.Never
+}
+func (re *ReturnExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *ReturnExpression) Tok() token.Token     { return re.Token }
+func (re *ReturnExpression) String() string {
+	if re.Value != nil {
+		return fmt.Sprintf("return %s", re.Value.String())
+	}
+	return "return"
+}
+
+type ForExpression struct {
+	Token        token.Token // The 'for' token
+	Identifier   string
+	VariableType types.Type
+	Start        Expression
+	End          Expression
+	Inclusive    bool
+	Body         Expression
+}
+
+var _ Expression = &ForExpression{}
+
+func (fe *ForExpression) expressionNode() {}
+func (fe *ForExpression) Type() types.Type {
+	return types.Unit
+}
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForExpression) Tok() token.Token     { return fe.Token }
+func (fe *ForExpression) String() string {
+	rangeOp := ".."
+	if fe.Inclusive {
+		rangeOp = "..="
+	}
+	return fmt.Sprintf("(for %s : %s in %s%s%s\n\t%s) :> %s", fe.Identifier, fe.VariableType.Name(), fe.Start, rangeOp, fe.End, fe.Body, fe.Type().Name())
+}
+
+type StructExpressionField struct {
+	Token token.Token // The name of the field
+	Name  string
+	Value Expression
+}
+
+type StructExpression struct {
+	Token      token.Token // The name of the struct
+	StructType *types.StructType
+	Fields     []StructExpressionField
+}
+
+var _ Expression = &StructExpression{}
+
+func (se *StructExpression) expressionNode() {}
+func (se *StructExpression) Type() types.Type {
+	return se.StructType
+}
+func (se *StructExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *StructExpression) Tok() token.Token     { return se.Token }
+func (se *StructExpression) String() string {
+	var b strings.Builder
+
+	for _, field := range se.Fields {
+		b.WriteString(fmt.Sprintf(" %s: %s,", field.Name, field.Value))
+	}
+
+	return fmt.Sprintf("%s {%s }", se.StructType.Name(), b.String())
+}
+
+type EnumExpression struct {
+	Token     token.Token // The name of the enum
+	EnumType  *types.EnumType
+	Variant   string
+	Arguments []Expression
+}
+
+var _ Expression = &EnumExpression{}
+
+func (ee *EnumExpression) expressionNode() {}
+func (ee *EnumExpression) Type() types.Type {
+	return ee.EnumType
+}
+func (ee *EnumExpression) TokenLiteral() string { return ee.Token.Literal }
+func (ee *EnumExpression) Tok() token.Token     { return ee.Token }
+func (ee *EnumExpression) String() string {
+	args := []string{}
+	for _, arg := range ee.Arguments {
+		args = append(args, arg.String())
+	}
+	return fmt.Sprintf("%s::%s(%s)", ee.EnumType.Name(), ee.Variant, strings.Join(args, ", "))
+}
+
+type MatchArm struct {
+	Token token.Token // The name of the variant
+	// The wildcard "_" matches every variant
+	Variant string
+	// The variables for the payload of the variant, "_" ignores a value
+	Bindings []string
+	Body     Expression
+}
+
+type MatchExpression struct {
+	Token      token.Token // The 'match' token
+	Expression Expression
+	Arms       []MatchArm
+	ReturnType types.Type
+}
+
+var _ Expression = &MatchExpression{}
+
+func (me *MatchExpression) expressionNode() {}
+func (me *MatchExpression) Type() types.Type {
+	return me.ReturnType
+}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Tok() token.Token     { return me.Token }
+func (me *MatchExpression) String() string {
+	var b strings.Builder
+
+	for _, arm := range me.Arms {
+		b.WriteString("\n\t" + arm.Variant)
+		if len(arm.Bindings) > 0 {
+			b.WriteString("(" + strings.Join(arm.Bindings, ", ") + ")")
+		}
+		b.WriteString(" => " + arm.Body.String() + ",")
+	}
+
+	return fmt.Sprintf("(match %s {%s\n}) :> %s", me.Expression, b.String(), me.ReturnType.Name())
+}
+
+type FieldAccessExpression struct {
+	Token      token.Token // The '.' token
+	Expression Expression
+	Field      string
+	FieldType  types.Type
+}
+
+var _ Expression = &FieldAccessExpression{}
+
+func (fae *FieldAccessExpression) expressionNode() {}
+func (fae *FieldAccessExpression) Type() types.Type {
+	return fae.FieldType
+}
+func (fae *FieldAccessExpression) TokenLiteral() string { return fae.Token.Literal }
+func (fae *FieldAccessExpression) Tok() token.Token     { return fae.Token }
+func (fae *FieldAccessExpression) String() string {
+	return fmt.Sprintf("(%s.%s :> %s)", fae.Expression, fae.Field, fae.FieldType.Name())
+}
+
+type ArrayExpression struct {
+	Token     token.Token // The '[' token
+	Elements  []Expression
+	ArrayType *types.ArrayType
+}
+
+var _ Expression = &ArrayExpression{}
+
+func (ae *ArrayExpression) expressionNode() {}
+func (ae *ArrayExpression) Type() types.Type {
+	return ae.ArrayType
+}
+func (ae *ArrayExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *ArrayExpression) Tok() token.Token     { return ae.Token }
+func (ae *ArrayExpression) String() string {
+	elements := []string{}
+	for _, e := range ae.Elements {
+		elements = append(elements, e.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+}
+
+type IndexExpression struct {
+	Token       token.Token // The '[' token
+	Expression  Expression
+	Index       Expression
+	ElementType types.Type
+}
+
+var _ Expression = &IndexExpression{}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) Type() types.Type {
+	return ie.ElementType
+}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Tok() token.Token     { return ie.Token }
+func (ie *IndexExpression) String() string {
+	return fmt.Sprintf("(%s[%s] :> %s)", ie.Expression, ie.Index, ie.ElementType.Name())
+}
+
+type SliceExpression struct {
+	Token      token.Token // The '[' token
+	Expression Expression
+	// Nullable, the slice starts at 0 if it is not set
+	Start Expression
+	// Nullable, the slice ends at the length of the expression if it is not set
+	End Expression
+	// A slice of ElementType, or str if a str is sliced
+	SliceType   types.Type
+	ElementType types.Type
+}
+
+var _ Expression = &SliceExpression{}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) Type() types.Type {
+	return se.SliceType
+}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Tok() token.Token     { return se.Token }
+func (se *SliceExpression) String() string {
+	var start, end string
+	if se.Start != nil {
+		start = se.Start.String()
+	}
+	if se.End != nil {
+		end = se.End.String()
+	}
+	return fmt.Sprintf("(%s[%s..%s] :> %s)", se.Expression, start, end, se.SliceType.Name())
+}
+
+// The builtin print function, writes the string to the standard output
+type PrintExpression struct {
+	Token      token.Token // The identifier print
+	Expression Expression
+}
+
+var _ Expression = &PrintExpression{}
+
+func (pe *PrintExpression) expressionNode() {}
+func (pe *PrintExpression) Type() types.Type {
+	return types.Unit
+}
+func (pe *PrintExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrintExpression) Tok() token.Token     { return pe.Token }
+func (pe *PrintExpression) String() string {
+	return fmt.Sprintf("print(%s)", pe.Expression)
+}
+
+// The builtin panic function, writes the location and the message to the standard error and exits
+type PanicExpression struct {
+	Token   token.Token // The identifier panic
+	Message Expression
+}
+
+var _ Expression = &PanicExpression{}
+
+func (pe *PanicExpression) expressionNode() {}
+func (pe *PanicExpression) Type() types.Type {
+	return types.Never
+}
+func (pe *PanicExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PanicExpression) Tok() token.Token     { return pe.Token }
+func (pe *PanicExpression) String() string {
+	return fmt.Sprintf("panic(%s)", pe.Message)
+}
+
+// The builtin assert function, panics if the condition is false
+type AssertExpression struct {
+	Token     token.Token // The identifier assert
+	Condition Expression
+}
+
+var _ Expression = &AssertExpression{}
+
+func (ae *AssertExpression) expressionNode() {}
+func (ae *AssertExpression) Type() types.Type {
+	return types.Unit
+}
+func (ae *AssertExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssertExpression) Tok() token.Token     { return ae.Token }
+func (ae *AssertExpression) String() string {
+	return fmt.Sprintf("assert(%s)", ae.Condition)
+}
+
+// The builtin len function, returns the length of an array, a slice or a string
+type LenExpression struct {
+	Token      token.Token // The identifier len
+	Expression Expression
+}
+
+var _ Expression = &LenExpression{}
+
+func (le *LenExpression) expressionNode() {}
+func (le *LenExpression) Type() types.Type {
+	return types.I64
+}
+func (le *LenExpression) TokenLiteral() string { return le.Token.Literal }
+func (le *LenExpression) Tok() token.Token     { return le.Token }
+func (le *LenExpression) String() string {
+	return fmt.Sprintf("len(%s)", le.Expression)
+}
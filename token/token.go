@@ -16,49 +16,115 @@ type Token struct {
 }
 
 var keywords = map[string]TokenType{
-	"else":  Else,
-	"false": False,
-	"fn":    Fn,
-	"if":    If,
-	"in":    In,
-	"true":  True,
+	"as":       As,
+	"break":    Break,
+	"const":    Const,
+	"continue": Continue,
+	"else":     Else,
+	"enum":     Enum,
+	"extern":   Extern,
+	"false":    False,
+	"fn":       Fn,
+	"for":      For,
+	"if":       If,
+	"import":   Import,
+	"in":       In,
+	"match":    Match,
+	"mod":      Mod,
+	"mut":      Mut,
+	"pub":      Pub,
+	"return":   Return,
+	"struct":   Struct,
+	"true":     True,
+	"var":      Var,
+	"while":    While,
 }
 
 const (
 	Illegal TokenType = "ILLEGAL"
 	Eof     TokenType = "EOF"
 
-	Ident TokenType = "IDENT"
-	Int   TokenType = "INT"
+	Ident  TokenType = "IDENT"
+	Int    TokenType = "INT"
+	Float  TokenType = "FLOAT"
+	String TokenType = "STRING"
 
-	Semicolon  TokenType = ";"
-	Colon      TokenType = ":"
-	Comma      TokenType = ","
-	Equal      TokenType = "="
-	OpenParen  TokenType = "("
-	CloseParen TokenType = ")"
-	OpenBrack  TokenType = "{"
-	CloseBrack TokenType = "}"
+	Semicolon   TokenType = ";"
+	Colon       TokenType = ":"
+	DoubleColon TokenType = "::"
+	Comma       TokenType = ","
+	Equal       TokenType = "="
+	FatArrow    TokenType = "=>"
+	OpenParen   TokenType = "("
+	CloseParen  TokenType = ")"
+	OpenBrack   TokenType = "{"
+	CloseBrack  TokenType = "}"
+
+	OpenSquare  TokenType = "["
+	CloseSquare TokenType = "]"
+
+	Dot TokenType = "."
+
+	// Ranges
+	DotDot      TokenType = ".."
+	DotDotEqual TokenType = "..="
+
+	// Unary Operators
+	Bang  TokenType = "!"
+	Tilde TokenType = "~"
 
 	// Binary Operators
 	Plus             TokenType = "+"
 	Minus            TokenType = "-"
 	Asterisk         TokenType = "*"
 	Slash            TokenType = "/"
+	Percent          TokenType = "%"
+	Ampersand        TokenType = "&"
+	Pipe             TokenType = "|"
+	Caret            TokenType = "^"
+	ShiftLeft        TokenType = "<<"
+	ShiftRight       TokenType = ">>"
+	TripleGreater    TokenType = ">>>"
 	DoubleEqual      TokenType = "=="
 	NotEqual         TokenType = "!="
 	LessThan         TokenType = "<"
 	LessThanEqual    TokenType = "<="
 	GreaterThan      TokenType = ">"
 	GreaterThanEqual TokenType = ">="
+	DoubleAmpersand  TokenType = "&&"
+	DoublePipe       TokenType = "||"
+	PipeGreater      TokenType = "|>"
+
+	// Compound Assignments
+	PlusEqual     TokenType = "+="
+	MinusEqual    TokenType = "-="
+	AsteriskEqual TokenType = "*="
+	SlashEqual    TokenType = "/="
+	PercentEqual  TokenType = "%="
 
 	// Keywords
-	Else  TokenType = "ELSE"
-	False TokenType = "FALSE"
-	Fn    TokenType = "FN"
-	If    TokenType = "IF"
-	In    TokenType = "IN"
-	True  TokenType = "TRUE"
+	As       TokenType = "AS"
+	Break    TokenType = "BREAK"
+	Const    TokenType = "CONST"
+	Continue TokenType = "CONTINUE"
+	Else     TokenType = "ELSE"
+	Enum     TokenType = "ENUM"
+	Extern   TokenType = "EXTERN"
+	False    TokenType = "FALSE"
+	Fn       TokenType = "FN"
+	For      TokenType = "FOR"
+	If       TokenType = "IF"
+	Import   TokenType = "IMPORT"
+	In       TokenType = "IN"
+	Match    TokenType = "MATCH"
+	Mod      TokenType = "MOD"
+	Mut      TokenType = "MUT"
+	Pub      TokenType = "PUB"
+	Return   TokenType = "RETURN"
+	Struct   TokenType = "STRUCT"
+	True     TokenType = "TRUE"
+	Var      TokenType = "VAR"
+	While    TokenType = "WHILE"
 )
 
 func LookupKeyword(literal string) TokenType {
@@ -2,8 +2,11 @@ package ttir
 
 import (
 	"fmt"
+	"slices"
 
+	"robaertschi.xyz/robaertschi/tt/ast"
 	"robaertschi.xyz/robaertschi/tt/tast"
+	"robaertschi.xyz/robaertschi/tt/token"
 	"robaertschi.xyz/robaertschi/tt/types"
 )
 
@@ -21,8 +24,106 @@ func tempLabel() string {
 	return fmt.Sprintf("lbl.%d", uniqueLabelId)
 }
 
+type loop struct {
+	continueLabel string
+	breakLabel    string
+	// Nullable, if the loop does not have a value
+	dst Operand
+}
+
+// The loops that are currently being emitted, the innermost loop is last
+var loops []loop
+
+// The variables of the current function, whose address is taken
+var addressTaken map[string]bool
+
+// The values of the constants, they are inlined into every use
+var constants map[string]*Constant
+
+var globals []*GlobalVariable
+
+// The functions of the closures, they are emitted after the other functions
+var closures []*Function
+
+// The environments of the closures are allocated in a global variable, which is never freed.
+// The used bytes are stored in another global variable.
+const (
+	closureMemory     = "tt.closures"
+	closureMemoryUsed = "tt.closures.used"
+	closureMemorySize = 1 << 20
+	// The message of the panic, if the memory is used up
+	closureMemoryMessage = "out of memory for closure environments, they are limited to 1 MiB"
+)
+
+// Adds the global variables for the environments of the closures, if they do not exist yet
+func addClosureMemory() {
+	for _, g := range globals {
+		if g.Name == closureMemory {
+			return
+		}
+	}
+
+	globals = append(globals,
+		&GlobalVariable{Name: closureMemory, Type: types.NewArray(types.U8, closureMemorySize), Value: make([]byte, closureMemorySize)},
+		&GlobalVariable{Name: closureMemoryUsed, Type: types.I64, Value: make([]byte, types.I64.Size())},
+	)
+}
+
+// Checks if expressions of the type produce a value, which has to be stored
+func hasValue(t types.Type) bool {
+	return !t.IsSameType(types.Unit) && !t.IsSameType(types.Never)
+}
+
+// Returns the operand of the variable, variables whose address is taken are stored in memory
+func variable(name string, t types.Type) Operand {
+	v := &Var{Value: name, Type: t}
+	if addressTaken[name] && !types.IsAggregate(t) {
+		return &Memory{Base: v, Offset: 0, Type: t}
+	}
+	return v
+}
+
+// The data of the program, the same value is only stored once
+var data []*Data
+var dataNames map[string]string
+
+// Returns the name of the data with the value, it is added if it does not exist yet
+func addData(value []byte) string {
+	if name, ok := dataNames[string(value)]; ok {
+		return name
+	}
+
+	name := fmt.Sprintf("string.%d", len(data)+1)
+	data = append(data, &Data{Name: name, Value: value})
+	dataNames[string(value)] = name
+	return name
+}
+
 func EmitProgram(program *tast.Program) *Program {
+	uniqueTempId = 0
+	uniqueLabelId = 0
+	data = []*Data{}
+	dataNames = make(map[string]string)
+	constants = make(map[string]*Constant)
+	globals = []*GlobalVariable{}
+	closures = []*Function{}
+	externs := []string{}
+	for _, decl := range program.Declarations {
+		switch decl := decl.(type) {
+		case *tast.ExternFunctionDeclaration:
+			// Multiple modules can declare the same extern function
+			if !slices.Contains(externs, decl.Name) {
+				externs = append(externs, decl.Name)
+			}
+		case *tast.ConstDeclaration:
+			constants[decl.Name] = &Constant{Value: decl.Result, Type: decl.ConstType}
+		case *tast.GlobalDeclaration:
+			globals = append(globals, &GlobalVariable{Name: decl.Name, Type: decl.GlobalType, Value: decl.Result})
+		}
+	}
+
 	functions := make([]*Function, 0)
+	structs := []*types.StructType{}
 	var mainFunction *Function
 	for _, decl := range program.Declarations {
 		switch decl := decl.(type) {
@@ -32,23 +133,45 @@ func EmitProgram(program *tast.Program) *Program {
 			if f.Name == "main" {
 				mainFunction = f
 			}
+		case *tast.StructDeclaration:
+			structs = append(structs, decl.StructType)
 		}
 	}
 
+	functions = append(functions, closures...)
+
 	return &Program{
 		Functions:    functions,
 		MainFunction: mainFunction,
+		Structs:      structs,
+		Data:         data,
+		Globals:      globals,
+		Externs:      externs,
 	}
 }
 
 func emitFunction(function *tast.FunctionDeclaration) *Function {
-	value, instructions := emitExpression(function.Body)
-	instructions = append(instructions, &Ret{Op: value})
+	addressTaken = function.AddressTaken
 
-	arguments := []string{}
+	arguments := []*Var{}
+	instructions := []Instruction{}
 
 	for _, arg := range function.Parameters {
-		arguments = append(arguments, arg.Name)
+		// The argument is passed in a temporary and then stored in memory
+		if dst, ok := variable(arg.Name, arg.Type).(*Memory); ok {
+			src := &Var{Value: temp(), Type: arg.Type}
+			arguments = append(arguments, src)
+			instructions = append(instructions, &Copy{Src: src, Dst: dst})
+			continue
+		}
+		arguments = append(arguments, &Var{Value: arg.Name, Type: arg.Type})
+	}
+
+	value, bodyInstructions := emitExpression(function.Body)
+	instructions = append(instructions, bodyInstructions...)
+	// Every path through a body, which never produces a value, already ends with a return
+	if !function.Body.Type().IsSameType(types.Never) {
+		instructions = append(instructions, &Ret{Op: value})
 	}
 
 	f := &Function{
@@ -56,6 +179,7 @@ func emitFunction(function *tast.FunctionDeclaration) *Function {
 		Instructions:   instructions,
 		Arguments:      arguments,
 		HasReturnValue: !function.ReturnType.IsSameType(types.Unit),
+		ReturnType:     function.ReturnType,
 	}
 
 	return f
@@ -64,20 +188,64 @@ func emitFunction(function *tast.FunctionDeclaration) *Function {
 func emitExpression(expr tast.Expression) (Operand, []Instruction) {
 	switch expr := expr.(type) {
 	case *tast.IntegerExpression:
-		return &Constant{Value: expr.Value}, []Instruction{}
+		return &Constant{Value: expr.Value, Type: expr.Type()}, []Instruction{}
+	case *tast.FloatExpression:
+		return FloatConstant(expr.Value), []Instruction{}
 	case *tast.BooleanExpression:
 		value := int64(0)
 		if expr.Value {
 			value = 1
 		}
-		return &Constant{Value: value}, []Instruction{}
+		return &Constant{Value: value, Type: types.Bool}, []Instruction{}
+	case *tast.StringExpression:
+		// The bytes are followed by a zero byte, which is not part of the string, so that they can also be used as a C string
+		name := addData(append([]byte(expr.Value), 0))
+		dst := &Var{Value: temp(), Type: types.Str}
+		return dst, []Instruction{
+			&Copy{Src: &DataAddress{Name: name}, Dst: &Memory{Base: dst, Offset: types.SlicePointerOffset, Type: types.I64}},
+			&Copy{Src: &Constant{Value: int64(len(expr.Value))}, Dst: &Memory{Base: dst, Offset: types.SliceLengthOffset, Type: types.I64}},
+		}
+	case *tast.UnaryExpression:
+		src, instructions := emitExpression(expr.Operand)
+		dst := &Var{Value: temp(), Type: expr.ResultType}
+		instructions = append(instructions, &Unary{Operator: expr.Operator, Src: src, Dst: dst})
+		return dst, instructions
+	case *tast.CastExpression:
+		src, instructions := emitExpression(expr.Expression)
+		if src.ValueType().IsSameType(expr.TargetType) {
+			return src, instructions
+		}
+		dst := &Var{Value: temp(), Type: expr.TargetType}
+		instructions = append(instructions, &Convert{Src: src, Dst: dst})
+		return dst, instructions
 	case *tast.BinaryExpression:
 		switch expr.Operator {
+		case ast.LogicalAnd, ast.LogicalOr:
+			// dst = lhs
+			// && -> if dst is false jump to end
+			// || -> if dst is true jump to end
+			// dst = rhs
+			// end:
+			endLabel := tempLabel()
+			dst := &Var{Value: temp(), Type: types.Bool}
+
+			lhsDst, instructions := emitExpression(expr.Lhs)
+			instructions = append(instructions, &Copy{Src: lhsDst, Dst: dst})
+			if expr.Operator == ast.LogicalAnd {
+				instructions = append(instructions, &JumpIfZero{Value: dst, Label: endLabel})
+			} else {
+				instructions = append(instructions, &JumpIfNotZero{Value: dst, Label: endLabel})
+			}
+
+			rhsDst, rhsInstructions := emitExpression(expr.Rhs)
+			instructions = append(instructions, rhsInstructions...)
+			instructions = append(instructions, &Copy{Src: rhsDst, Dst: dst}, Label(endLabel))
+			return dst, instructions
 		default:
 			lhsDst, instructions := emitExpression(expr.Lhs)
 			rhsDst, rhsInstructions := emitExpression(expr.Rhs)
 			instructions = append(instructions, rhsInstructions...)
-			dst := &Var{Value: temp()}
+			dst := &Var{Value: temp(), Type: expr.ResultType}
 			instructions = append(instructions, &Binary{Operator: expr.Operator, Lhs: lhsDst, Rhs: rhsDst, Dst: dst})
 			return dst, instructions
 		}
@@ -106,7 +274,10 @@ func emitExpression(expr tast.Expression) (Operand, []Instruction) {
 		// } endOfIf:
 		elseLabel := tempLabel()
 		endOfIfLabel := tempLabel()
-		var dst Operand = &Var{Value: temp()}
+		var dst Operand
+		if expr.Else != nil && hasValue(expr.ReturnType) {
+			dst = &Var{Value: temp(), Type: expr.ReturnType}
+		}
 
 		condDst, instructions := emitExpression(expr.Condition)
 
@@ -114,59 +285,696 @@ func emitExpression(expr tast.Expression) (Operand, []Instruction) {
 		thenDst, thenInstructions := emitExpression(expr.Then)
 		instructions = append(instructions, thenInstructions...)
 		if expr.Else != nil {
-			if !expr.ReturnType.IsSameType(types.Unit) {
+			if dst != nil && hasValue(expr.Then.Type()) {
 				instructions = append(instructions, &Copy{Src: thenDst, Dst: dst})
 			}
 			instructions = append(instructions, Jump(endOfIfLabel))
-		} else {
-			dst = nil
 		}
 
 		instructions = append(instructions, Label(elseLabel))
 		if expr.Else != nil {
 			elseDst, elseInstructions := emitExpression(expr.Else)
 			instructions = append(instructions, elseInstructions...)
-			if !expr.ReturnType.IsSameType(types.Unit) {
+			if dst != nil && hasValue(expr.Else.Type()) {
 				instructions = append(instructions, &Copy{Src: elseDst, Dst: dst})
 			}
 		}
 		instructions = append(instructions, Label(endOfIfLabel))
 		return dst, instructions
-	case *tast.AssignmentExpression:
-		ident := expr.Lhs.(*tast.VariableReference)
+	case *tast.WhileExpression:
+		// continue: if (cond -> false jump to "else") {
+		//     ...
+		// } jump to continue
+		// else: else {
+		//     ...
+		// } break:
+		continueLabel := tempLabel()
+		elseLabel := tempLabel()
+		breakLabel := tempLabel()
+		var dst Operand
+		if !expr.ReturnType.IsSameType(types.Unit) {
+			dst = &Var{Value: temp(), Type: expr.ReturnType}
+		}
+
+		instructions := []Instruction{Label(continueLabel)}
+		condDst, condInstructions := emitExpression(expr.Condition)
+		instructions = append(instructions, condInstructions...)
+		instructions = append(instructions, &JumpIfZero{Value: condDst, Label: elseLabel})
+
+		loops = append(loops, loop{continueLabel: continueLabel, breakLabel: breakLabel, dst: dst})
+		_, bodyInstructions := emitExpression(expr.Body)
+		loops = loops[:len(loops)-1]
+
+		instructions = append(instructions, bodyInstructions...)
+		instructions = append(instructions, Jump(continueLabel), Label(elseLabel))
+
+		if expr.Else != nil {
+			elseDst, elseInstructions := emitExpression(expr.Else)
+			instructions = append(instructions, elseInstructions...)
+			if dst != nil {
+				instructions = append(instructions, &Copy{Src: elseDst, Dst: dst})
+			}
+		}
+		instructions = append(instructions, Label(breakLabel))
 
-		rhsDst, instructions := emitExpression(expr.Rhs)
+		return dst, instructions
+	case *tast.ForExpression:
+		// counter = start; end = end
+		// if counter >= end jump to break
+		// loop: {
+		//     identifier = counter
+		//     ...
+		// } continue: counter += 1; jump to loop if counter < end
+		// break:
+		//
+		// The inclusive range checks if the counter reached the end before incrementing,
+		// so that the counter can not overflow if the end is the largest value of the type.
+		loopLabel := tempLabel()
+		continueLabel := tempLabel()
+		breakLabel := tempLabel()
+		counter := &Var{Value: temp(), Type: expr.VariableType}
+		end := &Var{Value: temp(), Type: expr.VariableType}
+		cond := &Var{Value: temp(), Type: types.Bool}
+		one := &Constant{Value: 1, Type: expr.VariableType}
+
+		startDst, instructions := emitExpression(expr.Start)
+		instructions = append(instructions, &Copy{Src: startDst, Dst: counter})
+		endDst, endInstructions := emitExpression(expr.End)
+		instructions = append(instructions, endInstructions...)
+		instructions = append(instructions, &Copy{Src: endDst, Dst: end})
+
+		compareOp := ast.LessThan
+		if expr.Inclusive {
+			compareOp = ast.LessThanEqual
+		}
+		instructions = append(instructions,
+			&Binary{Operator: compareOp, Lhs: counter, Rhs: end, Dst: cond},
+			&JumpIfZero{Value: cond, Label: breakLabel},
+			Label(loopLabel),
+			&Copy{Src: counter, Dst: variable(expr.Identifier, expr.VariableType)},
+		)
+
+		loops = append(loops, loop{continueLabel: continueLabel, breakLabel: breakLabel})
+		_, bodyInstructions := emitExpression(expr.Body)
+		loops = loops[:len(loops)-1]
+		instructions = append(instructions, bodyInstructions...)
+
+		instructions = append(instructions, Label(continueLabel))
+		if expr.Inclusive {
+			instructions = append(instructions,
+				&Binary{Operator: ast.Equal, Lhs: counter, Rhs: end, Dst: cond},
+				&JumpIfNotZero{Value: cond, Label: breakLabel},
+				&Binary{Operator: ast.Add, Lhs: counter, Rhs: one, Dst: counter},
+				Jump(loopLabel),
+			)
+		} else {
+			instructions = append(instructions,
+				&Binary{Operator: ast.Add, Lhs: counter, Rhs: one, Dst: counter},
+				&Binary{Operator: ast.LessThan, Lhs: counter, Rhs: end, Dst: cond},
+				&JumpIfNotZero{Value: cond, Label: loopLabel},
+			)
+		}
+		instructions = append(instructions, Label(breakLabel))
+
+		return nil, instructions
+	case *tast.BreakExpression:
+		l := loops[len(loops)-1]
+		instructions := []Instruction{}
+
+		if expr.Value != nil {
+			valueDst, valueInstructions := emitExpression(expr.Value)
+			instructions = append(instructions, valueInstructions...)
+			if l.dst != nil {
+				instructions = append(instructions, &Copy{Src: valueDst, Dst: l.dst})
+			}
+		}
 
-		instructions = append(instructions, &Copy{Src: rhsDst, Dst: &Var{Value: ident.Identifier}})
+		return nil, append(instructions, Jump(l.breakLabel))
+	case *tast.ContinueExpression:
+		return nil, []Instruction{Jump(loops[len(loops)-1].continueLabel)}
+	case *tast.ReturnExpression:
+		if expr.Value == nil {
+			return nil, []Instruction{&Ret{}}
+		}
 
+		valueDst, instructions := emitExpression(expr.Value)
+		return nil, append(instructions, &Ret{Op: valueDst})
+	case *tast.AssignmentExpression:
+		_, instructions := emitAssignment(expr)
 		return nil, instructions
+	case *tast.StructExpression:
+		dst := &Var{Value: temp(), Type: expr.StructType}
+		instructions := []Instruction{}
+
+		for _, field := range expr.Fields {
+			valueDst, valueInstructions := emitExpression(field.Value)
+			instructions = append(instructions, valueInstructions...)
+
+			structField, _ := expr.StructType.Field(field.Name)
+			instructions = append(instructions, &Copy{Src: valueDst, Dst: &Memory{Base: dst, Offset: structField.Offset, Type: structField.Type}})
+		}
+
+		return dst, instructions
+	case *tast.EnumExpression:
+		dst := &Var{Value: temp(), Type: expr.EnumType}
+		variant, _ := expr.EnumType.Variant(expr.Variant)
+		instructions := []Instruction{&Copy{Src: &Constant{Value: variant.Tag}, Dst: &Memory{Base: dst, Offset: types.EnumTagOffset, Type: types.I64}}}
+
+		for i, arg := range expr.Arguments {
+			valueDst, valueInstructions := emitExpression(arg)
+			instructions = append(instructions, valueInstructions...)
+			instructions = append(instructions, &Copy{Src: valueDst, Dst: &Memory{Base: dst, Offset: variant.Offsets[i], Type: variant.Fields[i]}})
+		}
+
+		return dst, instructions
+	case *tast.MatchExpression:
+		// tag = expr.tag
+		// if tag != variant jump to "next" {
+		//     bindings = payload
+		//     dst = ...
+		// } jump to end
+		// next: ...
+		// end:
+		//
+		// The checker made sure, that the match is exhaustive, so the last arm does not need to compare the tag
+		endLabel := tempLabel()
+		var dst Operand
+		if hasValue(expr.ReturnType) {
+			dst = &Var{Value: temp(), Type: expr.ReturnType}
+		}
+
+		et := expr.Expression.Type().(*types.EnumType)
+		p, instructions := emitPlace(expr.Expression)
+		tagPlace, tagInstructions := p.at(types.EnumTagOffset, types.I64)
+		tag, readInstructions := tagPlace.read()
+		instructions = append(instructions, tagInstructions...)
+		instructions = append(instructions, readInstructions...)
+
+		for i, arm := range expr.Arms {
+			last := i == len(expr.Arms)-1
+			nextLabel := ""
+
+			if arm.Variant != "_" {
+				variant, _ := et.Variant(arm.Variant)
+				if !last {
+					nextLabel = tempLabel()
+					cond := &Var{Value: temp(), Type: types.Bool}
+					instructions = append(instructions,
+						&Binary{Operator: ast.Equal, Lhs: tag, Rhs: &Constant{Value: variant.Tag}, Dst: cond},
+						&JumpIfZero{Value: cond, Label: nextLabel},
+					)
+				}
+
+				for j, binding := range arm.Bindings {
+					if binding == "_" {
+						continue
+					}
+					fieldPlace, fieldInstructions := p.at(variant.Offsets[j], variant.Fields[j])
+					value, readInstructions := fieldPlace.read()
+					instructions = append(instructions, fieldInstructions...)
+					instructions = append(instructions, readInstructions...)
+					instructions = append(instructions, &Copy{Src: value, Dst: variable(binding, variant.Fields[j])})
+				}
+			}
+
+			bodyDst, bodyInstructions := emitExpression(arm.Body)
+			instructions = append(instructions, bodyInstructions...)
+			if dst != nil && hasValue(arm.Body.Type()) {
+				instructions = append(instructions, &Copy{Src: bodyDst, Dst: dst})
+			}
+
+			if !last {
+				instructions = append(instructions, Jump(endLabel))
+			}
+			if nextLabel != "" {
+				instructions = append(instructions, Label(nextLabel))
+			}
+		}
+		instructions = append(instructions, Label(endLabel))
+
+		return dst, instructions
+	case *tast.AddressOfExpression:
+		p, instructions := emitPlace(expr.Expression)
+		address, addressInstructions := p.addressOf(expr.PointerType)
+		return address, append(instructions, addressInstructions...)
+	case *tast.FieldAccessExpression, *tast.IndexExpression, *tast.DereferenceExpression:
+		p, instructions := emitPlace(expr)
+		dst, readInstructions := p.read()
+		return dst, append(instructions, readInstructions...)
+	case *tast.ArrayExpression:
+		dst := &Var{Value: temp(), Type: expr.ArrayType}
+		instructions := []Instruction{}
+		size := expr.ArrayType.Element.Size()
+
+		for i, element := range expr.Elements {
+			elementDst, elementInstructions := emitExpression(element)
+			instructions = append(instructions, elementInstructions...)
+			instructions = append(instructions, &Copy{Src: elementDst, Dst: &Memory{Base: dst, Offset: int64(i) * size, Type: expr.ArrayType.Element}})
+		}
+
+		return dst, instructions
+	case *tast.SliceExpression:
+		// check 0 <= end <= length
+		// check 0 <= start <= end
+		// dst.pointer = base + start * size
+		// dst.length = end - start
+		var base, length Operand
+		var instructions []Instruction
+
+		switch t := expr.Expression.Type().(type) {
+		case *types.ArrayType:
+			p, placeInstructions := emitPlace(expr.Expression)
+			var addressInstructions []Instruction
+			base, addressInstructions = p.addressOf(types.I64)
+			instructions = append(placeInstructions, addressInstructions...)
+			length = &Constant{Value: t.Length}
+		case *types.SliceType, *types.StringType:
+			base, length, instructions = emitSliceParts(expr.Expression)
+		}
+
+		var start, end Operand = &Constant{Value: 0}, length
+		if expr.Start != nil {
+			var startInstructions []Instruction
+			start, startInstructions = emitIndex(expr.Start)
+			instructions = append(instructions, startInstructions...)
+		}
+		if expr.End != nil {
+			var endInstructions []Instruction
+			end, endInstructions = emitIndex(expr.End)
+			instructions = append(instructions, endInstructions...)
+		}
+
+		_, constantStart := start.(*Constant)
+		_, constantEnd := end.(*Constant)
+		_, constantLength := length.(*Constant)
+		// The checker already checked constant bounds
+		if !constantStart || !constantEnd || !constantLength {
+			instructions = append(instructions,
+				&BoundsCheck{Index: end, Length: length, Inclusive: true},
+				&BoundsCheck{Index: start, Length: end, Inclusive: true},
+			)
+		}
+
+		dst := &Var{Value: temp(), Type: expr.SliceType}
+		offset := &Var{Value: temp(), Type: types.I64}
+		pointer := &Var{Value: temp(), Type: types.I64}
+		sliceLength := &Var{Value: temp(), Type: types.I64}
+		instructions = append(instructions,
+			&Binary{Operator: ast.Multiply, Lhs: start, Rhs: &Constant{Value: expr.ElementType.Size()}, Dst: offset},
+			&Binary{Operator: ast.Add, Lhs: base, Rhs: offset, Dst: pointer},
+			&Binary{Operator: ast.Subtract, Lhs: end, Rhs: start, Dst: sliceLength},
+			&Copy{Src: pointer, Dst: &Memory{Base: dst, Offset: types.SlicePointerOffset, Type: types.I64}},
+			&Copy{Src: sliceLength, Dst: &Memory{Base: dst, Offset: types.SliceLengthOffset, Type: types.I64}},
+		)
+		return dst, instructions
+	case *tast.PrintExpression:
+		pointer, length, instructions := emitSliceParts(expr.Expression)
+		return nil, append(instructions, &Syscall{Number: syscallWrite, Arguments: []Operand{&Constant{Value: stdout}, pointer, length}})
+	case *tast.PanicExpression:
+		message, messageLength, instructions := emitSliceParts(expr.Message)
+		return nil, append(instructions, emitPanic(expr.Token, message, messageLength)...)
+	case *tast.AssertExpression:
+		condition, instructions := emitExpression(expr.Condition)
+		message, messageInstructions := emitData(assertMessage)
+		okLabel := tempLabel()
+
+		instructions = append(instructions, &JumpIfNotZero{Value: condition, Label: okLabel})
+		instructions = append(instructions, messageInstructions...)
+		instructions = append(instructions, emitPanic(expr.Token, message, &Constant{Value: int64(len(assertMessage))})...)
+		return nil, append(instructions, Label(okLabel))
+	case *tast.LenExpression:
+		if t, ok := expr.Expression.Type().(*types.ArrayType); ok {
+			_, instructions := emitExpression(expr.Expression)
+			return &Constant{Value: t.Length, Type: types.I64}, instructions
+		}
+
+		_, length, instructions := emitSliceParts(expr.Expression)
+		return length, instructions
 	case *tast.VariableDeclaration:
 		rhsDst, instructions := emitExpression(expr.InitializingExpression)
 
-		instructions = append(instructions, &Copy{Src: rhsDst, Dst: &Var{Value: expr.Identifier}})
+		instructions = append(instructions, &Copy{Src: rhsDst, Dst: variable(expr.Identifier, expr.VariableType)})
 
 		return nil, instructions
+	case *tast.ConstReference:
+		return constants[expr.Identifier], []Instruction{}
+	case *tast.GlobalReference:
+		dst := &Var{Value: temp(), Type: expr.GlobalType}
+		return dst, []Instruction{&Copy{Src: &Global{Name: expr.Identifier, Type: expr.GlobalType}, Dst: dst}}
 	case *tast.VariableReference:
-		return &Var{Value: expr.Identifier}, []Instruction{}
+		src := variable(expr.Identifier, expr.VariableType)
+		if _, ok := src.(*Memory); ok {
+			dst := &Var{Value: temp(), Type: expr.VariableType}
+			return dst, []Instruction{&Copy{Src: src, Dst: dst}}
+		}
+		return src, []Instruction{}
 	case *tast.FunctionCall:
 		var dst Operand
 		if !expr.ReturnType.IsSameType(types.Unit) {
-			dst = &Var{Value: temp()}
+			dst = &Var{Value: temp(), Type: expr.ReturnType}
 		}
-		args := []Operand{}
-
-		instructions := []Instruction{}
+		args, instructions := emitArguments(expr.Arguments)
 
-		for _, arg := range expr.Arguments {
-			dst, argInstructions := emitExpression(arg)
+		instructions = append(instructions, &Call{FunctionName: expr.Identifier, Arguments: args, ReturnValue: dst})
+		return dst, instructions
+	case *tast.FunctionReference:
+		// Functions, which are not closures, do not need an environment
+		dst := &Var{Value: temp(), Type: expr.FunctionType}
+		return dst, []Instruction{
+			&Copy{Src: &FunctionAddress{Name: expr.Identifier}, Dst: &Memory{Base: dst, Offset: types.FunctionCodeOffset, Type: types.I64}},
+			&Copy{Src: &Constant{Value: 0}, Dst: &Memory{Base: dst, Offset: types.FunctionEnvironmentOffset, Type: types.I64}},
+		}
+	case *tast.IndirectCall:
+		function, instructions := emitExpression(expr.Function)
+		code := &Var{Value: temp(), Type: types.I64}
+		environment := &Var{Value: temp(), Type: types.I64}
+		instructions = append(instructions,
+			&Copy{Src: &Memory{Base: function.(*Var), Offset: types.FunctionCodeOffset, Type: types.I64}, Dst: code},
+			&Copy{Src: &Memory{Base: function.(*Var), Offset: types.FunctionEnvironmentOffset, Type: types.I64}, Dst: environment},
+		)
 
-			instructions = append(instructions, argInstructions...)
-			args = append(args, dst)
+		var dst Operand
+		if !expr.ReturnType.IsSameType(types.Unit) {
+			dst = &Var{Value: temp(), Type: expr.ReturnType}
 		}
+		args, argInstructions := emitArguments(expr.Arguments)
+		instructions = append(instructions, argInstructions...)
 
-		instructions = append(instructions, &Call{FunctionName: expr.Identifier, Arguments: args, ReturnValue: dst})
+		instructions = append(instructions, &CallIndirect{Code: code, Environment: environment, Arguments: args, ReturnValue: dst})
+		return dst, instructions
+	case *tast.ClosureExpression:
+		closures = append(closures, emitClosure(expr))
+
+		dst := &Var{Value: temp(), Type: expr.ClosureType}
+		environment, instructions := emitEnvironment(expr.Token, expr.Captures)
+		instructions = append(instructions,
+			&Copy{Src: &FunctionAddress{Name: expr.Name}, Dst: &Memory{Base: dst, Offset: types.FunctionCodeOffset, Type: types.I64}},
+			&Copy{Src: environment, Dst: &Memory{Base: dst, Offset: types.FunctionEnvironmentOffset, Type: types.I64}},
+		)
 		return dst, instructions
 	default:
 		panic(fmt.Sprintf("unexpected tast.Expression: %#v", expr))
 	}
 }
+
+// Emits the arguments of a call
+func emitArguments(arguments []tast.Expression) ([]Operand, []Instruction) {
+	args := []Operand{}
+	instructions := []Instruction{}
+
+	for _, arg := range arguments {
+		dst, argInstructions := emitExpression(arg)
+
+		instructions = append(instructions, argInstructions...)
+		args = append(args, dst)
+	}
+
+	return args, instructions
+}
+
+// The layout of the environment of a closure, the captured variables are stored like the fields of a struct
+func environmentLayout(captures []tast.Parameter) *types.StructType {
+	fields := []types.StructField{}
+	for _, capture := range captures {
+		fields = append(fields, types.StructField{Name: capture.Name, Type: capture.Type})
+	}
+	return types.NewStruct("", fields)
+}
+
+// Emits the function of a closure. It starts by copying the captured variables out of its environment.
+func emitClosure(closure *tast.ClosureExpression) *Function {
+	// The closure is emitted in the middle of another function
+	outerAddressTaken, outerLoops := addressTaken, loops
+	defer func() {
+		addressTaken, loops = outerAddressTaken, outerLoops
+	}()
+	loops = nil
+
+	f := emitFunction(&tast.FunctionDeclaration{
+		Token:        closure.Token,
+		Body:         closure.Body,
+		Name:         closure.Name,
+		Parameters:   closure.Parameters,
+		ReturnType:   closure.ClosureType.ReturnType,
+		AddressTaken: closure.AddressTaken,
+	})
+	if len(closure.Captures) == 0 {
+		return f
+	}
+
+	f.Environment = &Var{Value: temp(), Type: types.I64}
+	environment := place{address: f.Environment, t: environmentLayout(closure.Captures)}
+	instructions := []Instruction{}
+	for i, field := range environment.t.(*types.StructType).Fields {
+		capturePlace, captureInstructions := environment.at(field.Offset, field.Type)
+		value, readInstructions := capturePlace.read()
+		instructions = append(instructions, captureInstructions...)
+		instructions = append(instructions, readInstructions...)
+		instructions = append(instructions, &Copy{Src: value, Dst: variable(closure.Captures[i].Name, field.Type)})
+	}
+	f.Instructions = append(instructions, f.Instructions...)
+	return f
+}
+
+// Allocates the environment of a closure and copies the values of the captured variables into it.
+// Returns the address of the environment, closures without captured variables do not need one.
+// If the memory is used up, the program panics at the closure tok.
+//
+// used = tt.closures.used
+// end = used + size
+// if end > closureMemorySize { panic(closureMemoryMessage) }
+// tt.closures.used = end
+// environment = &tt.closures + used
+func emitEnvironment(tok token.Token, captures []tast.Parameter) (Operand, []Instruction) {
+	if len(captures) == 0 {
+		return &Constant{Value: 0}, []Instruction{}
+	}
+	addClosureMemory()
+
+	layout := environmentLayout(captures)
+	// Every environment starts at a multiple of 8, so that all captured values are aligned
+	size := (layout.Size() + 7) / 8 * 8
+	used := &Var{Value: temp(), Type: types.I64}
+	end := &Var{Value: temp(), Type: types.I64}
+	base := &Var{Value: temp(), Type: types.I64}
+	environment := &Var{Value: temp(), Type: types.I64}
+	fits := &Var{Value: temp(), Type: types.Bool}
+	fitsLabel := tempLabel()
+	instructions := []Instruction{
+		&Copy{Src: &Global{Name: closureMemoryUsed, Type: types.I64}, Dst: used},
+		&Binary{Operator: ast.Add, Lhs: used, Rhs: &Constant{Value: size}, Dst: end},
+		&Binary{Operator: ast.LessThanEqual, Lhs: end, Rhs: &Constant{Value: closureMemorySize}, Dst: fits},
+		&JumpIfNotZero{Value: fits, Label: fitsLabel},
+	}
+	message, messageInstructions := emitData(closureMemoryMessage)
+	instructions = append(instructions, messageInstructions...)
+	instructions = append(instructions, emitPanic(tok, message, &Constant{Value: int64(len(closureMemoryMessage))})...)
+	instructions = append(instructions,
+		Label(fitsLabel),
+		&Copy{Src: end, Dst: &Global{Name: closureMemoryUsed, Type: types.I64}},
+		&AddressOf{Src: &Global{Name: closureMemory, Type: types.NewArray(types.U8, closureMemorySize)}, Dst: base},
+		&Binary{Operator: ast.Add, Lhs: base, Rhs: used, Dst: environment},
+	)
+
+	for i, field := range layout.Fields {
+		value, valueInstructions := emitExpression(&tast.VariableReference{Identifier: captures[i].Name, VariableType: captures[i].Type})
+		instructions = append(instructions, valueInstructions...)
+
+		p, fieldInstructions := place{address: environment, t: layout}.at(field.Offset, field.Type)
+		instructions = append(instructions, fieldInstructions...)
+		instructions = append(instructions, p.write(value)...)
+	}
+
+	return environment, instructions
+}
+
+// Emits an assignment and returns the stored value, so that "a = b = c" can store it again
+func emitAssignment(expr *tast.AssignmentExpression) (Operand, []Instruction) {
+	var value Operand
+	var instructions []Instruction
+	if rhs, ok := expr.Rhs.(*tast.AssignmentExpression); ok {
+		value, instructions = emitAssignment(rhs)
+	} else {
+		value, instructions = emitExpression(expr.Rhs)
+	}
+
+	var p place
+	switch lhs := expr.Lhs.(type) {
+	case *tast.VariableReference:
+		p = place{memory: variable(lhs.Identifier, lhs.VariableType), t: lhs.VariableType}
+	default:
+		var placeInstructions []Instruction
+		p, placeInstructions = emitPlace(lhs)
+		instructions = append(instructions, placeInstructions...)
+	}
+
+	// The place is computed once, so "a[f()] += 1" only calls f once
+	if expr.Compound {
+		current, readInstructions := p.read()
+		instructions = append(instructions, readInstructions...)
+		dst := &Var{Value: temp(), Type: p.t}
+		instructions = append(instructions, &Binary{Operator: expr.Operator, Lhs: current, Rhs: value, Dst: dst})
+		value = dst
+	}
+
+	return value, append(instructions, p.write(value)...)
+}
+
+// A location in memory, which is either known at compile time as an offset into a variable
+// or a global variable, or only at runtime as an address
+type place struct {
+	// A *Memory, a *Global or a *Var, nil if the location is only known at runtime
+	memory  Operand
+	address Operand
+	t       types.Type
+}
+
+func (p place) read() (Operand, []Instruction) {
+	dst := &Var{Value: temp(), Type: p.t}
+	if p.memory != nil {
+		return dst, []Instruction{&Copy{Src: p.memory, Dst: dst}}
+	}
+	return dst, []Instruction{&Load{Address: p.address, Dst: dst}}
+}
+
+func (p place) write(src Operand) []Instruction {
+	if p.memory != nil {
+		return []Instruction{&Copy{Src: src, Dst: p.memory}}
+	}
+	return []Instruction{&Store{Src: src, Address: p.address}}
+}
+
+// Returns the place which is offset bytes after this one and has the type t
+func (p place) at(offset int64, t types.Type) (place, []Instruction) {
+	switch m := p.memory.(type) {
+	case *Memory:
+		return place{memory: &Memory{Base: m.Base, Offset: m.Offset + offset, Type: t}, t: t}, []Instruction{}
+	case *Global:
+		return place{memory: &Global{Name: m.Name, Offset: m.Offset + offset, Type: t}, t: t}, []Instruction{}
+	}
+
+	if offset == 0 {
+		return place{address: p.address, t: t}, []Instruction{}
+	}
+	address := &Var{Value: temp(), Type: types.I64}
+	return place{address: address, t: t}, []Instruction{&Binary{Operator: ast.Add, Lhs: p.address, Rhs: &Constant{Value: offset}, Dst: address}}
+}
+
+// Returns the address of the place, a new variable has the type t
+func (p place) addressOf(t types.Type) (Operand, []Instruction) {
+	if p.memory == nil {
+		return p.address, []Instruction{}
+	}
+
+	address := &Var{Value: temp(), Type: t}
+	return address, []Instruction{&AddressOf{Src: p.memory, Dst: address}}
+}
+
+// Emits the location of expr. Expressions, that are not a variable, field or element,
+// are stored in a temporary variable first.
+func emitPlace(expr tast.Expression) (place, []Instruction) {
+	switch expr := expr.(type) {
+	case *tast.FieldAccessExpression:
+		p, instructions := emitPlace(expr.Expression)
+		field, _ := expr.Expression.Type().(*types.StructType).Field(expr.Field)
+		fieldPlace, fieldInstructions := p.at(field.Offset, field.Type)
+		return fieldPlace, append(instructions, fieldInstructions...)
+	case *tast.IndexExpression:
+		var base, length Operand
+		var instructions []Instruction
+		size := expr.ElementType.Size()
+
+		switch t := expr.Expression.Type().(type) {
+		case *types.ArrayType:
+			p, placeInstructions := emitPlace(expr.Expression)
+			// The checker already made sure, that constant indices are in bounds
+			if index, ok := expr.Index.(*tast.IntegerExpression); ok {
+				elementPlace, elementInstructions := p.at(index.Value*size, expr.ElementType)
+				return elementPlace, append(placeInstructions, elementInstructions...)
+			}
+
+			var addressInstructions []Instruction
+			base, addressInstructions = p.addressOf(types.I64)
+			instructions = append(placeInstructions, addressInstructions...)
+			length = &Constant{Value: t.Length}
+		case *types.SliceType, *types.StringType:
+			base, length, instructions = emitSliceParts(expr.Expression)
+		}
+
+		index, indexInstructions := emitIndex(expr.Index)
+		instructions = append(instructions, indexInstructions...)
+
+		offset := &Var{Value: temp(), Type: types.I64}
+		address := &Var{Value: temp(), Type: types.I64}
+		instructions = append(instructions,
+			&BoundsCheck{Index: index, Length: length},
+			&Binary{Operator: ast.Multiply, Lhs: index, Rhs: &Constant{Value: size}, Dst: offset},
+			&Binary{Operator: ast.Add, Lhs: base, Rhs: offset, Dst: address},
+		)
+		return place{address: address, t: expr.ElementType}, instructions
+	case *tast.DereferenceExpression:
+		address, instructions := emitExpression(expr.Expression)
+		return place{address: address, t: expr.ResultType}, instructions
+	case *tast.VariableReference:
+		v := &Var{Value: expr.Identifier, Type: expr.VariableType}
+		return place{memory: &Memory{Base: v, Offset: 0, Type: v.Type}, t: v.Type}, []Instruction{}
+	case *tast.GlobalReference:
+		return place{memory: &Global{Name: expr.Identifier, Type: expr.GlobalType}, t: expr.GlobalType}, []Instruction{}
+	default:
+		dst, instructions := emitExpression(expr)
+		// Values, which have fields or elements, are always stored in variables
+		v := dst.(*Var)
+		return place{memory: &Memory{Base: v, Offset: 0, Type: v.Type}, t: v.Type}, instructions
+	}
+}
+
+// Emits the index and converts it to i64, which is used for all address calculations
+func emitIndex(expr tast.Expression) (Operand, []Instruction) {
+	index, instructions := emitExpression(expr)
+	if index.ValueType().IsSameType(types.I64) {
+		return index, instructions
+	}
+
+	dst := &Var{Value: temp(), Type: types.I64}
+	return dst, append(instructions, &Convert{Src: index, Dst: dst})
+}
+
+const (
+	syscallWrite int64 = 1
+	stdout       int64 = 1
+)
+
+// The message of a failed assert
+const assertMessage = "assertion failed"
+
+// Panics with the message, the location "file:line:col: " of the token is written before it
+func emitPanic(tok token.Token, message Operand, messageLength Operand) []Instruction {
+	location := fmt.Sprintf("%s:%d:%d: ", tok.Loc.File, tok.Loc.Line, tok.Loc.Col)
+	pointer, instructions := emitData(location)
+
+	return append(instructions, &Panic{
+		Location:       pointer,
+		LocationLength: &Constant{Value: int64(len(location))},
+		Message:        message,
+		MessageLength:  messageLength,
+	})
+}
+
+// Returns a pointer to the bytes of the value, which are stored as data
+func emitData(value string) (Operand, []Instruction) {
+	name := addData(append([]byte(value), 0))
+	dst := &Var{Value: temp(), Type: types.I64}
+	return dst, []Instruction{&Copy{Src: &DataAddress{Name: name}, Dst: dst}}
+}
+
+// Emits the slice or string and returns the pointer to the first element and the length of it
+func emitSliceParts(expr tast.Expression) (Operand, Operand, []Instruction) {
+	slice, instructions := emitExpression(expr)
+	pointer := &Var{Value: temp(), Type: types.I64}
+	length := &Var{Value: temp(), Type: types.I64}
+
+	return pointer, length, append(instructions,
+		&Copy{Src: &Memory{Base: slice.(*Var), Offset: types.SlicePointerOffset, Type: types.I64}, Dst: pointer},
+		&Copy{Src: &Memory{Base: slice.(*Var), Offset: types.SliceLengthOffset, Type: types.I64}, Dst: length},
+	)
+}
@@ -2,36 +2,94 @@ package ttir
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
+	"robaertschi.xyz/robaertschi/tt/types"
 )
 
 type Program struct {
 	Functions    []*Function
 	MainFunction *Function
+	Structs      []*types.StructType
+	Data         []*Data
+	Globals      []*GlobalVariable
+	// The names of the extern functions, they are defined outside of the program
+	Externs []string
 }
 
 func (p *Program) String() string {
 	var builder strings.Builder
+	for _, e := range p.Externs {
+		builder.WriteString(fmt.Sprintf("extern %s\n", e))
+	}
 	for _, f := range p.Functions {
 		builder.WriteString(f.String())
 	}
+	for _, d := range p.Data {
+		builder.WriteString(d.String())
+	}
+	for _, g := range p.Globals {
+		builder.WriteString(g.String())
+	}
 	return builder.String()
 }
 
+// Read-only data, which is referenced with a DataAddress
+type Data struct {
+	Name  string
+	Value []byte
+}
+
+func (d *Data) String() string {
+	return fmt.Sprintf("data %s = %q\n", d.Name, d.Value)
+}
+
+// A mutable global variable, which is referenced with a Global
+type GlobalVariable struct {
+	Name string
+	Type types.Type
+	// The initial value in memory
+	Value []byte
+}
+
+func (g *GlobalVariable) String() string {
+	if g.IsZero() {
+		return fmt.Sprintf("var %s %s = zero\n", g.Name, g.Type.Name())
+	}
+	return fmt.Sprintf("var %s %s = %v\n", g.Name, g.Type.Name(), g.Value)
+}
+
+// Checks if the initial value is only zeros, it does not need to be stored in the executable
+func (g *GlobalVariable) IsZero() bool {
+	for _, b := range g.Value {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 type Function struct {
-	Name           string
-	Arguments      []string
+	Name      string
+	Arguments []*Var
+	// Nullable, the address of the environment of a closure, it is passed in addition to the arguments
+	Environment    *Var
 	Instructions   []Instruction
 	HasReturnValue bool
+	ReturnType     types.Type
 }
 
 func (f *Function) String() string {
 	var builder strings.Builder
 	builder.WriteString(fmt.Sprintf("fn %s", f.Name))
+	if f.Environment != nil {
+		builder.WriteString(fmt.Sprintf(" [%s]", f.Environment))
+	}
 	for _, arg := range f.Arguments {
-		builder.WriteString(" " + arg)
+		builder.WriteString(" " + arg.String())
 	}
 	builder.WriteRune('\n')
 	for _, i := range f.Instructions {
@@ -60,6 +118,17 @@ func (r *Ret) String() string {
 }
 func (r *Ret) instruction() {}
 
+type Unary struct {
+	Operator ast.UnaryOperator
+	Src      Operand
+	Dst      Operand
+}
+
+func (u *Unary) String() string {
+	return fmt.Sprintf("%s = %s %s\n", u.Dst, u.Operator, u.Src)
+}
+func (u *Unary) instruction() {}
+
 type Binary struct {
 	Operator ast.BinaryOperator
 	Lhs      Operand
@@ -82,6 +151,96 @@ func (c *Copy) String() string {
 }
 func (c *Copy) instruction() {}
 
+// Converts Src to the type of Dst, the value is truncated or extended if the sizes differ
+type Convert struct {
+	Src Operand
+	Dst Operand
+}
+
+func (c *Convert) String() string {
+	return fmt.Sprintf("%s = convert %s to %s\n", c.Dst, c.Src, c.Dst.ValueType().Name())
+}
+func (c *Convert) instruction() {}
+
+// Stores the address of Src, which has to be in memory, in Dst
+type AddressOf struct {
+	Src Operand
+	Dst Operand
+}
+
+func (ao *AddressOf) String() string {
+	return fmt.Sprintf("%s = address of %s\n", ao.Dst, ao.Src)
+}
+func (ao *AddressOf) instruction() {}
+
+// Loads the value at Address into Dst, the type of Dst determines how much is loaded
+type Load struct {
+	Address Operand
+	Dst     Operand
+}
+
+func (l *Load) String() string {
+	return fmt.Sprintf("%s = load %s\n", l.Dst, l.Address)
+}
+func (l *Load) instruction() {}
+
+// Stores Src at Address
+type Store struct {
+	Src     Operand
+	Address Operand
+}
+
+func (s *Store) String() string {
+	return fmt.Sprintf("store %s, %s\n", s.Src, s.Address)
+}
+func (s *Store) instruction() {}
+
+// Exits the program with the bounds error code, if Index is not between 0 and Length.
+// Length is only allowed as Index if Inclusive is set.
+type BoundsCheck struct {
+	Index     Operand
+	Length    Operand
+	Inclusive bool
+}
+
+func (bc *BoundsCheck) String() string {
+	op := "<"
+	if bc.Inclusive {
+		op = "<="
+	}
+	return fmt.Sprintf("check 0 <= %s %s %s\n", bc.Index, op, bc.Length)
+}
+func (bc *BoundsCheck) instruction() {}
+
+// Writes the location, the message and a newline to the standard error and exits the program with the panic error code.
+// The pointers point to the bytes of the location and the message.
+type Panic struct {
+	Location       Operand
+	LocationLength Operand
+	Message        Operand
+	MessageLength  Operand
+}
+
+func (p *Panic) String() string {
+	return fmt.Sprintf("panic %s, %s, %s, %s\n", p.Location, p.LocationLength, p.Message, p.MessageLength)
+}
+func (p *Panic) instruction() {}
+
+// Calls the syscall Number with the Arguments, the result is ignored
+type Syscall struct {
+	Number    int64
+	Arguments []Operand
+}
+
+func (s *Syscall) String() string {
+	args := []string{}
+	for _, arg := range s.Arguments {
+		args = append(args, arg.String())
+	}
+	return fmt.Sprintf("syscall %d %s\n", s.Number, strings.Join(args, ", "))
+}
+func (s *Syscall) instruction() {}
+
 type JumpIfZero struct {
 	Value Operand
 	Label string
@@ -146,25 +305,155 @@ func (c *Call) String() string {
 }
 func (c *Call) instruction() {}
 
+// Calls the function at the address Code, Environment is passed to closures as the address of their environment
+type CallIndirect struct {
+	Code        Operand
+	Environment Operand
+	Arguments   []Operand
+	// NOTE: Nullable
+	ReturnValue Operand
+}
+
+func (c *CallIndirect) String() string {
+	args := []string{}
+	for _, arg := range c.Arguments {
+		args = append(args, arg.String())
+	}
+
+	result := ""
+	if c.ReturnValue != nil {
+		result = c.ReturnValue.String() + " = "
+	}
+	return fmt.Sprintf("%scall indirect %s [%s] %s\n", result, c.Code, c.Environment, strings.Join(args, ", "))
+}
+func (c *CallIndirect) instruction() {}
+
 type Operand interface {
 	String() string
+	// The type of the value, operands without a type are i64
+	ValueType() types.Type
 	operand()
 }
 
+// The kind of register, which holds the value of an operand
+type RegisterClass int
+
+const (
+	// Integers, booleans and addresses
+	IntegerClass RegisterClass = iota
+	// Floating point numbers
+	FloatClass
+)
+
+func ClassOf(op Operand) RegisterClass {
+	if types.IsFloat(op.ValueType()) {
+		return FloatClass
+	}
+	return IntegerClass
+}
+
+// A constant value, floats are stored as their IEEE 754 bits
 type Constant struct {
 	Value int64
+	Type  types.Type
+}
+
+func FloatConstant(value float64) *Constant {
+	return &Constant{Value: int64(math.Float64bits(value)), Type: types.F64}
+}
+
+// The value of a float constant
+func (c *Constant) Float() float64 {
+	return math.Float64frombits(uint64(c.Value))
 }
 
 func (c *Constant) String() string {
+	if ClassOf(c) == FloatClass {
+		// Whole numbers get a fraction, so that they can not be mistaken for integers
+		s := strconv.FormatFloat(c.Float(), 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	}
 	return fmt.Sprintf("%d", c.Value)
 }
+func (c *Constant) ValueType() types.Type {
+	if c.Type == nil {
+		return types.I64
+	}
+	return c.Type
+}
 func (c *Constant) operand() {}
 
 type Var struct {
 	Value string
+	Type  types.Type
 }
 
 func (v *Var) String() string {
 	return v.Value
 }
+func (v *Var) ValueType() types.Type {
+	if v.Type == nil {
+		return types.I64
+	}
+	return v.Type
+}
 func (v *Var) operand() {}
+
+// The value of type Type, which is Offset bytes after the start of the struct in Base
+type Memory struct {
+	Base   *Var
+	Offset int64
+	Type   types.Type
+}
+
+func (m *Memory) String() string {
+	return fmt.Sprintf("%s+%d", m.Base, m.Offset)
+}
+func (m *Memory) ValueType() types.Type {
+	return m.Type
+}
+func (m *Memory) operand() {}
+
+// The value of type Type, which is Offset bytes after the start of the global variable Name
+type Global struct {
+	Name   string
+	Offset int64
+	Type   types.Type
+}
+
+func (g *Global) String() string {
+	return fmt.Sprintf("$%s+%d", g.Name, g.Offset)
+}
+func (g *Global) ValueType() types.Type {
+	return g.Type
+}
+func (g *Global) operand() {}
+
+// The address of the Data with the name Name, it can only be the source of a Copy
+type DataAddress struct {
+	Name string
+}
+
+func (da *DataAddress) String() string {
+	return "&" + da.Name
+}
+func (da *DataAddress) ValueType() types.Type {
+	return types.I64
+}
+func (da *DataAddress) operand() {}
+
+// The address of the function with the name Name, it can only be the source of a Copy
+type FunctionAddress struct {
+	Name string
+}
+
+func (fa *FunctionAddress) String() string {
+	return "&fn " + fa.Name
+}
+func (fa *FunctionAddress) ValueType() types.Type {
+	return types.I64
+}
+func (fa *FunctionAddress) operand() {}
@@ -9,6 +9,7 @@ import (
 	"robaertschi.xyz/robaertschi/tt/parser"
 	"robaertschi.xyz/robaertschi/tt/token"
 	"robaertschi.xyz/robaertschi/tt/typechecker"
+	"robaertschi.xyz/robaertschi/tt/types"
 )
 
 type ttirEmitterTest struct {
@@ -19,7 +20,22 @@ type ttirEmitterTest struct {
 func runTTIREmitterTest(t *testing.T, test ttirEmitterTest) {
 	t.Helper()
 
-	l, err := lexer.New(test.input, "test.tt")
+	program := parse(t, test.input, "test.tt")
+	tprogram, err := typechecker.New().CheckProgram(program)
+
+	if err != nil {
+		t.Fatalf("typechecker error: %q", err)
+	}
+
+	ttir := EmitProgram(tprogram)
+
+	expectProgram(t, &test.expected, ttir)
+}
+
+func parse(t *testing.T, input string, file string) *ast.Program {
+	t.Helper()
+
+	l, err := lexer.New(input, file)
 	l.WithErrorCallback(func(l token.Loc, s string, a ...any) {
 		format := fmt.Sprintf(s, a...)
 		t.Errorf("Lexer error callback called: %s:%d:%d %s", l.File, l.Line, l.Col, format)
@@ -33,16 +49,7 @@ func runTTIREmitterTest(t *testing.T, test ttirEmitterTest) {
 		format := fmt.Sprintf(s, a...)
 		t.Errorf("Parser error callback called: %s:%d:%d %s", tok.Loc.File, tok.Loc.Line, tok.Loc.Col, format)
 	})
-	program := p.ParseProgram()
-	tprogram, err := typechecker.New().CheckProgram(program)
-
-	if err != nil {
-		t.Fatalf("typechecker error: %q", err)
-	}
-
-	ttir := EmitProgram(tprogram)
-
-	expectProgram(t, &test.expected, ttir)
+	return p.ParseProgram()
 }
 
 func expectProgram(t *testing.T, expected *Program, actual *Program) {
@@ -56,6 +63,32 @@ func expectProgram(t *testing.T, expected *Program, actual *Program) {
 	for i, decl := range expected.Functions {
 		expectFunction(t, decl, actual.Functions[i])
 	}
+
+	if len(expected.Data) != len(actual.Data) {
+		t.Errorf("expected %d data, got %d", len(expected.Data), len(actual.Data))
+		return
+	}
+
+	for i, data := range expected.Data {
+		if data.Name != actual.Data[i].Name || string(data.Value) != string(actual.Data[i].Value) {
+			t.Errorf("expected data %s, got %s", data, actual.Data[i])
+		}
+	}
+
+	if fmt.Sprint(expected.Externs) != fmt.Sprint(actual.Externs) {
+		t.Errorf("expected externs %v, got %v", expected.Externs, actual.Externs)
+	}
+
+	if len(expected.Globals) != len(actual.Globals) {
+		t.Errorf("expected %d globals, got %d", len(expected.Globals), len(actual.Globals))
+		return
+	}
+
+	for i, global := range expected.Globals {
+		if global.Name != actual.Globals[i].Name || string(global.Value) != string(actual.Globals[i].Value) {
+			t.Errorf("expected global %s, got %s", global, actual.Globals[i])
+		}
+	}
 }
 
 func expectFunction(t *testing.T, expected *Function, actual *Function) {
@@ -70,12 +103,34 @@ func expectFunction(t *testing.T, expected *Function, actual *Function) {
 		return
 	}
 
+	// The labels are generated, so the expected names are mapped to the actual names on first use
+	labels := map[string]string{}
 	for i, inst := range expected.Instructions {
-		expectInstruction(t, inst, actual.Instructions[i])
+		expectInstruction(t, labels, inst, actual.Instructions[i])
+	}
+}
+
+// Checks that the expected label always maps to the same actual label and no two expected labels map to the same one
+func expectLabel(t *testing.T, labels map[string]string, expected string, actual string) {
+	t.Helper()
+
+	if mapped, ok := labels[expected]; ok {
+		if mapped != actual {
+			t.Errorf("expected label %q to be %q, but got %q", expected, mapped, actual)
+		}
+		return
 	}
+
+	for other, mapped := range labels {
+		if mapped == actual {
+			t.Errorf("expected label %q, but got %q, which is already used for %q", expected, actual, other)
+			return
+		}
+	}
+	labels[expected] = actual
 }
 
-func expectInstruction(t *testing.T, inst Instruction, actual Instruction) {
+func expectInstruction(t *testing.T, labels map[string]string, inst Instruction, actual Instruction) {
 	t.Helper()
 	switch inst := inst.(type) {
 	case *Ret:
@@ -98,6 +153,189 @@ func expectInstruction(t *testing.T, inst Instruction, actual Instruction) {
 		expectOperand(t, inst.Lhs, binary.Lhs)
 		expectOperand(t, inst.Rhs, binary.Rhs)
 		expectOperand(t, inst.Dst, binary.Dst)
+	case *Unary:
+		unary, ok := actual.(*Unary)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		if inst.Operator != unary.Operator {
+			t.Errorf("expected unary operator %v, but got %v", inst.Operator, unary.Operator)
+		}
+		expectOperand(t, inst.Src, unary.Src)
+		expectOperand(t, inst.Dst, unary.Dst)
+	case *Copy:
+		copy, ok := actual.(*Copy)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Src, copy.Src)
+		expectOperand(t, inst.Dst, copy.Dst)
+	case *Convert:
+		convert, ok := actual.(*Convert)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Src, convert.Src)
+		expectOperand(t, inst.Dst, convert.Dst)
+	case *JumpIfZero:
+		jz, ok := actual.(*JumpIfZero)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Value, jz.Value)
+		expectLabel(t, labels, inst.Label, jz.Label)
+	case *JumpIfNotZero:
+		jnz, ok := actual.(*JumpIfNotZero)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Value, jnz.Value)
+		expectLabel(t, labels, inst.Label, jnz.Label)
+	case *AddressOf:
+		addressOf, ok := actual.(*AddressOf)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Src, addressOf.Src)
+		expectOperand(t, inst.Dst, addressOf.Dst)
+	case *Load:
+		load, ok := actual.(*Load)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Address, load.Address)
+		expectOperand(t, inst.Dst, load.Dst)
+	case *Store:
+		store, ok := actual.(*Store)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Src, store.Src)
+		expectOperand(t, inst.Address, store.Address)
+	case *BoundsCheck:
+		check, ok := actual.(*BoundsCheck)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		if inst.Inclusive != check.Inclusive {
+			t.Errorf("expected inclusive to be %v, but got %v", inst.Inclusive, check.Inclusive)
+		}
+		expectOperand(t, inst.Index, check.Index)
+		expectOperand(t, inst.Length, check.Length)
+	case *Panic:
+		p, ok := actual.(*Panic)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Location, p.Location)
+		expectOperand(t, inst.LocationLength, p.LocationLength)
+		expectOperand(t, inst.Message, p.Message)
+		expectOperand(t, inst.MessageLength, p.MessageLength)
+	case *Syscall:
+		syscall, ok := actual.(*Syscall)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		if inst.Number != syscall.Number {
+			t.Errorf("expected syscall number %d, but got %d", inst.Number, syscall.Number)
+		}
+		if len(inst.Arguments) != len(syscall.Arguments) {
+			t.Errorf("expected %d syscall arguments, but got %d", len(inst.Arguments), len(syscall.Arguments))
+			return
+		}
+		for i, arg := range inst.Arguments {
+			expectOperand(t, arg, syscall.Arguments[i])
+		}
+	case *Call:
+		call, ok := actual.(*Call)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		if inst.FunctionName != call.FunctionName {
+			t.Errorf("expected call to %q, but got %q", inst.FunctionName, call.FunctionName)
+		}
+		if len(inst.Arguments) != len(call.Arguments) {
+			t.Errorf("expected %d arguments, but got %d", len(inst.Arguments), len(call.Arguments))
+			return
+		}
+		for i, arg := range inst.Arguments {
+			expectOperand(t, arg, call.Arguments[i])
+		}
+		expectOperand(t, inst.ReturnValue, call.ReturnValue)
+	case *CallIndirect:
+		call, ok := actual.(*CallIndirect)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectOperand(t, inst.Code, call.Code)
+		expectOperand(t, inst.Environment, call.Environment)
+		if len(inst.Arguments) != len(call.Arguments) {
+			t.Errorf("expected %d arguments, but got %d", len(inst.Arguments), len(call.Arguments))
+			return
+		}
+		for i, arg := range inst.Arguments {
+			expectOperand(t, arg, call.Arguments[i])
+		}
+		expectOperand(t, inst.ReturnValue, call.ReturnValue)
+	case Jump:
+		jump, ok := actual.(Jump)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectLabel(t, labels, string(inst), string(jump))
+	case Label:
+		label, ok := actual.(Label)
+
+		if !ok {
+			t.Errorf("expected inst to be %T, but got %T", inst, actual)
+			return
+		}
+
+		expectLabel(t, labels, string(inst), string(label))
+	default:
+		t.Errorf("unexpected instruction %T in the expected instructions", inst)
 	}
 }
 
@@ -116,6 +354,9 @@ func expectOperand(t *testing.T, expected Operand, actual Operand) {
 		if expected.Value != constant.Value {
 			t.Errorf("expected *Constant.Value to be %d, but got %d", expected.Value, constant.Value)
 		}
+		if expected.Type != nil && !expected.Type.IsSameType(constant.Type) {
+			t.Errorf("expected constant %d to have type %q, but got %q", expected.Value, expected.Type.Name(), constant.Type.Name())
+		}
 	case *Var:
 		v, ok := actual.(*Var)
 
@@ -126,6 +367,50 @@ func expectOperand(t *testing.T, expected Operand, actual Operand) {
 		if expected.Value != v.Value {
 			t.Errorf("expected var to be %q, but got %q", expected.Value, v.Value)
 		}
+		if expected.Type != nil && !expected.Type.IsSameType(v.ValueType()) {
+			t.Errorf("expected var %q to have type %q, but got %q", expected.Value, expected.Type.Name(), v.ValueType().Name())
+		}
+	case *Memory:
+		m, ok := actual.(*Memory)
+
+		if !ok {
+			t.Errorf("expected operand to be %T, but got %T", expected, actual)
+			return
+		}
+		expectOperand(t, expected.Base, m.Base)
+		if expected.Offset != m.Offset {
+			t.Errorf("expected memory offset to be %d, but got %d", expected.Offset, m.Offset)
+		}
+	case *DataAddress:
+		d, ok := actual.(*DataAddress)
+
+		if !ok {
+			t.Errorf("expected operand to be %T, but got %T", expected, actual)
+			return
+		}
+		if expected.Name != d.Name {
+			t.Errorf("expected data address of %q, but got %q", expected.Name, d.Name)
+		}
+	case *FunctionAddress:
+		f, ok := actual.(*FunctionAddress)
+
+		if !ok {
+			t.Errorf("expected operand to be %T, but got %T", expected, actual)
+			return
+		}
+		if expected.Name != f.Name {
+			t.Errorf("expected function address of %q, but got %q", expected.Name, f.Name)
+		}
+	case *Global:
+		g, ok := actual.(*Global)
+
+		if !ok {
+			t.Errorf("expected operand to be %T, but got %T", expected, actual)
+			return
+		}
+		if expected.Name != g.Name || expected.Offset != g.Offset {
+			t.Errorf("expected global %s, but got %s", expected, g)
+		}
 	}
 }
 
@@ -161,3 +446,509 @@ func TestBinaryExpression(t *testing.T) {
 		},
 	})
 }
+
+func TestWhileExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): i64 = while true { break 3 } else 4;",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					Label("continue"),
+					&JumpIfZero{Value: &Constant{Value: 1}, Label: "else"},
+					&Copy{Src: &Constant{Value: 3}, Dst: &Var{Value: "temp.1"}},
+					Jump("break"),
+					Jump("continue"),
+					Label("else"),
+					&Copy{Src: &Constant{Value: 4}, Dst: &Var{Value: "temp.1"}},
+					Label("break"),
+					&Ret{},
+				}},
+			},
+		},
+	})
+}
+
+func TestUnaryExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): bool = !(-3 == ~3);",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Unary{Operator: ast.Negate, Src: &Constant{Value: 3}, Dst: &Var{Value: "temp.1"}},
+					&Unary{Operator: ast.Complement, Src: &Constant{Value: 3}, Dst: &Var{Value: "temp.2"}},
+					&Binary{Operator: ast.Equal, Lhs: &Var{Value: "temp.1"}, Rhs: &Var{Value: "temp.2"}, Dst: &Var{Value: "temp.3"}},
+					&Unary{Operator: ast.Not, Src: &Var{Value: "temp.3"}, Dst: &Var{Value: "temp.4"}},
+					&Ret{Op: &Var{Value: "temp.4"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestLogicalExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): bool = true && false || true;",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 1}, Dst: &Var{Value: "temp.2"}},
+					&JumpIfZero{Value: &Var{Value: "temp.2"}, Label: "and.end"},
+					&Copy{Src: &Constant{Value: 0}, Dst: &Var{Value: "temp.2"}},
+					Label("and.end"),
+					&Copy{Src: &Var{Value: "temp.2"}, Dst: &Var{Value: "temp.1"}},
+					&JumpIfNotZero{Value: &Var{Value: "temp.1"}, Label: "or.end"},
+					&Copy{Src: &Constant{Value: 1}, Dst: &Var{Value: "temp.1"}},
+					Label("or.end"),
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestCastExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): u8 = (300 as u8) + (1 as i64) as u8;",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Convert{Src: &Constant{Value: 300}, Dst: &Var{Value: "temp.1", Type: types.U8}},
+					&Convert{Src: &Constant{Value: 1}, Dst: &Var{Value: "temp.2", Type: types.U8}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.1"}, Rhs: &Var{Value: "temp.2"}, Dst: &Var{Value: "temp.3", Type: types.U8}},
+					&Ret{Op: &Var{Value: "temp.3"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestStructExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "struct Point { x: i64, y: i64 }; fn main(): i64 = { mut p := Point { x: 1, y: 2 }; p.y = 3; p.x };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 1}, Dst: &Memory{Base: &Var{Value: "temp.1"}, Offset: 0}},
+					&Copy{Src: &Constant{Value: 2}, Dst: &Memory{Base: &Var{Value: "temp.1"}, Offset: 8}},
+					&Copy{Src: &Var{Value: "temp.1"}, Dst: &Var{Value: "p.0"}},
+					&Copy{Src: &Constant{Value: 3}, Dst: &Memory{Base: &Var{Value: "p.0"}, Offset: 8}},
+					&Copy{Src: &Memory{Base: &Var{Value: "p.0"}, Offset: 0}, Dst: &Var{Value: "temp.2", Type: types.I64}},
+					&Ret{Op: &Var{Value: "temp.2"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestIndexExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): i64 = { mut a := [1, 2]; i := 1; a[i] = a[0]; len(a[i..]) };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 1}, Dst: &Memory{Base: &Var{Value: "temp.1"}, Offset: 0}},
+					&Copy{Src: &Constant{Value: 2}, Dst: &Memory{Base: &Var{Value: "temp.1"}, Offset: 8}},
+					&Copy{Src: &Var{Value: "temp.1"}, Dst: &Var{Value: "a.0"}},
+					&Copy{Src: &Constant{Value: 1}, Dst: &Var{Value: "i.1"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "a.0"}, Offset: 0}, Dst: &Var{Value: "temp.2", Type: types.I64}},
+					&AddressOf{Src: &Memory{Base: &Var{Value: "a.0"}, Offset: 0}, Dst: &Var{Value: "temp.3"}},
+					&BoundsCheck{Index: &Var{Value: "i.1"}, Length: &Constant{Value: 2}},
+					&Binary{Operator: ast.Multiply, Lhs: &Var{Value: "i.1"}, Rhs: &Constant{Value: 8}, Dst: &Var{Value: "temp.4"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.3"}, Rhs: &Var{Value: "temp.4"}, Dst: &Var{Value: "temp.5"}},
+					&Store{Src: &Var{Value: "temp.2"}, Address: &Var{Value: "temp.5"}},
+					&AddressOf{Src: &Memory{Base: &Var{Value: "a.0"}, Offset: 0}, Dst: &Var{Value: "temp.6"}},
+					&BoundsCheck{Index: &Constant{Value: 2}, Length: &Constant{Value: 2}, Inclusive: true},
+					&BoundsCheck{Index: &Var{Value: "i.1"}, Length: &Constant{Value: 2}, Inclusive: true},
+					&Binary{Operator: ast.Multiply, Lhs: &Var{Value: "i.1"}, Rhs: &Constant{Value: 8}, Dst: &Var{Value: "temp.8"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.6"}, Rhs: &Var{Value: "temp.8"}, Dst: &Var{Value: "temp.9"}},
+					&Binary{Operator: ast.Subtract, Lhs: &Constant{Value: 2}, Rhs: &Var{Value: "i.1"}, Dst: &Var{Value: "temp.10"}},
+					&Copy{Src: &Var{Value: "temp.9"}, Dst: &Memory{Base: &Var{Value: "temp.7"}, Offset: 0}},
+					&Copy{Src: &Var{Value: "temp.10"}, Dst: &Memory{Base: &Var{Value: "temp.7"}, Offset: 8}},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.7"}, Offset: 0}, Dst: &Var{Value: "temp.11"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.7"}, Offset: 8}, Dst: &Var{Value: "temp.12"}},
+					&Ret{Op: &Var{Value: "temp.12"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestStringExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: `fn main(): i64 = { print("hi\n"); s := "hi\n"; len(s) };`,
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &DataAddress{Name: "string.1"}, Dst: &Memory{Base: &Var{Value: "temp.1"}, Offset: 0}},
+					&Copy{Src: &Constant{Value: 3}, Dst: &Memory{Base: &Var{Value: "temp.1"}, Offset: 8}},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.1"}, Offset: 0}, Dst: &Var{Value: "temp.2"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.1"}, Offset: 8}, Dst: &Var{Value: "temp.3"}},
+					&Syscall{Number: 1, Arguments: []Operand{&Constant{Value: 1}, &Var{Value: "temp.2"}, &Var{Value: "temp.3"}}},
+					&Copy{Src: &DataAddress{Name: "string.1"}, Dst: &Memory{Base: &Var{Value: "temp.4"}, Offset: 0}},
+					&Copy{Src: &Constant{Value: 3}, Dst: &Memory{Base: &Var{Value: "temp.4"}, Offset: 8}},
+					&Copy{Src: &Var{Value: "temp.4"}, Dst: &Var{Value: "s.0"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "s.0"}, Offset: 0}, Dst: &Var{Value: "temp.5"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "s.0"}, Offset: 8}, Dst: &Var{Value: "temp.6"}},
+					&Ret{Op: &Var{Value: "temp.6"}},
+				}},
+			},
+			Data: []*Data{{Name: "string.1", Value: []byte("hi\n\x00")}},
+		},
+	})
+}
+
+func TestPanicAndAssert(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: `fn main(): i64 = { assert(1 < 2); panic("no") };`,
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Binary{Operator: ast.LessThan, Lhs: &Constant{Value: 1}, Rhs: &Constant{Value: 2}, Dst: &Var{Value: "temp.1"}},
+					&JumpIfNotZero{Value: &Var{Value: "temp.1"}, Label: "ok"},
+					&Copy{Src: &DataAddress{Name: "string.1"}, Dst: &Var{Value: "temp.2"}},
+					&Copy{Src: &DataAddress{Name: "string.2"}, Dst: &Var{Value: "temp.3"}},
+					&Panic{Location: &Var{Value: "temp.3"}, LocationLength: &Constant{Value: 14}, Message: &Var{Value: "temp.2"}, MessageLength: &Constant{Value: 16}},
+					Label("ok"),
+					&Copy{Src: &DataAddress{Name: "string.3"}, Dst: &Memory{Base: &Var{Value: "temp.4"}, Offset: 0}},
+					&Copy{Src: &Constant{Value: 2}, Dst: &Memory{Base: &Var{Value: "temp.4"}, Offset: 8}},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.4"}, Offset: 0}, Dst: &Var{Value: "temp.5"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.4"}, Offset: 8}, Dst: &Var{Value: "temp.6"}},
+					&Copy{Src: &DataAddress{Name: "string.4"}, Dst: &Var{Value: "temp.7"}},
+					&Panic{Location: &Var{Value: "temp.7"}, LocationLength: &Constant{Value: 14}, Message: &Var{Value: "temp.5"}, MessageLength: &Var{Value: "temp.6"}},
+				}},
+			},
+			Data: []*Data{
+				{Name: "string.1", Value: []byte("assertion failed\x00")},
+				{Name: "string.2", Value: []byte("test.tt:1:19: \x00")},
+				{Name: "string.3", Value: []byte("no\x00")},
+				{Name: "string.4", Value: []byte("test.tt:1:34: \x00")},
+			},
+		},
+	})
+}
+
+func TestFloats(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn half(x: i64): f64 = x as f64 / 2.0; fn main(): i64 = (half(5) * -1.5) as i64;",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "half", Arguments: []*Var{{Value: "x.0"}}, Instructions: []Instruction{
+					&Convert{Src: &Var{Value: "x.0"}, Dst: &Var{Value: "temp.1"}},
+					&Binary{Operator: ast.Divide, Lhs: &Var{Value: "temp.1"}, Rhs: FloatConstant(2), Dst: &Var{Value: "temp.2"}},
+					&Ret{Op: &Var{Value: "temp.2"}},
+				}},
+				{Name: "main", Instructions: []Instruction{
+					&Call{FunctionName: "half", Arguments: []Operand{&Constant{Value: 5}}, ReturnValue: &Var{Value: "temp.3"}},
+					&Unary{Operator: ast.Negate, Src: FloatConstant(1.5), Dst: &Var{Value: "temp.4"}},
+					&Binary{Operator: ast.Multiply, Lhs: &Var{Value: "temp.3"}, Rhs: &Var{Value: "temp.4"}, Dst: &Var{Value: "temp.5"}},
+					&Convert{Src: &Var{Value: "temp.5"}, Dst: &Var{Value: "temp.6"}},
+					&Ret{Op: &Var{Value: "temp.6"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestPointerExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): i64 = { mut x := 1; p := &x; *p = *p + 1; x };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 1}, Dst: &Memory{Base: &Var{Value: "x.0"}, Offset: 0}},
+					&AddressOf{Src: &Memory{Base: &Var{Value: "x.0"}, Offset: 0}, Dst: &Var{Value: "temp.1"}},
+					&Copy{Src: &Var{Value: "temp.1"}, Dst: &Var{Value: "p.1"}},
+					&Load{Address: &Var{Value: "p.1"}, Dst: &Var{Value: "temp.2"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.2"}, Rhs: &Constant{Value: 1}, Dst: &Var{Value: "temp.3"}},
+					&Store{Src: &Var{Value: "temp.3"}, Address: &Var{Value: "p.1"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "x.0"}, Offset: 0}, Dst: &Var{Value: "temp.4"}},
+					&Ret{Op: &Var{Value: "temp.4"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestAssignmentExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): i64 = { mut x := 1; p := &x; mut y := 0; y = *p *= 2; x + y };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 1}, Dst: &Memory{Base: &Var{Value: "x.0"}, Offset: 0}},
+					&AddressOf{Src: &Memory{Base: &Var{Value: "x.0"}, Offset: 0}, Dst: &Var{Value: "temp.1"}},
+					&Copy{Src: &Var{Value: "temp.1"}, Dst: &Var{Value: "p.1"}},
+					&Copy{Src: &Constant{Value: 0}, Dst: &Var{Value: "y.2"}},
+					&Load{Address: &Var{Value: "p.1"}, Dst: &Var{Value: "temp.2"}},
+					&Binary{Operator: ast.Multiply, Lhs: &Var{Value: "temp.2"}, Rhs: &Constant{Value: 2}, Dst: &Var{Value: "temp.3"}},
+					&Store{Src: &Var{Value: "temp.3"}, Address: &Var{Value: "p.1"}},
+					&Copy{Src: &Var{Value: "temp.3"}, Dst: &Var{Value: "y.2"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "x.0"}, Offset: 0}, Dst: &Var{Value: "temp.4"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.4"}, Rhs: &Var{Value: "y.2"}, Dst: &Var{Value: "temp.5"}},
+					&Ret{Op: &Var{Value: "temp.5"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestUntypedIntegers(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn add(x: u8, y: u8): u8 = x + y; fn main(): i64 = { a: u16 = 1; b := 2 + a; (add(3, 4) as i64) + (b as i64) };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "add", Instructions: []Instruction{
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "x.0"}, Rhs: &Var{Value: "y.1"}, Dst: &Var{Value: "temp.1", Type: types.U8}},
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 1, Type: types.U16}, Dst: &Var{Value: "a.0"}},
+					&Binary{Operator: ast.Add, Lhs: &Constant{Value: 2, Type: types.U16}, Rhs: &Var{Value: "a.0"}, Dst: &Var{Value: "temp.2", Type: types.U16}},
+					&Copy{Src: &Var{Value: "temp.2"}, Dst: &Var{Value: "b.1", Type: types.U16}},
+					&Call{FunctionName: "add", Arguments: []Operand{&Constant{Value: 3, Type: types.U8}, &Constant{Value: 4, Type: types.U8}}, ReturnValue: &Var{Value: "temp.3"}},
+					&Convert{Src: &Var{Value: "temp.3"}, Dst: &Var{Value: "temp.4", Type: types.I64}},
+					&Convert{Src: &Var{Value: "b.1"}, Dst: &Var{Value: "temp.5", Type: types.I64}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.4"}, Rhs: &Var{Value: "temp.5"}, Dst: &Var{Value: "temp.6"}},
+					&Ret{Op: &Var{Value: "temp.6"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestPipeEvaluationOrder(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn f(): i64 = 1; fn h(): i64 = 2; fn g(a: i64, b: i64): i64 = a - b; fn main(): i64 = f() |> g(h(), |);",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "f", Instructions: []Instruction{
+					&Ret{Op: &Constant{Value: 1}},
+				}},
+				{Name: "h", Instructions: []Instruction{
+					&Ret{Op: &Constant{Value: 2}},
+				}},
+				{Name: "g", Instructions: []Instruction{
+					&Binary{Operator: ast.Subtract, Lhs: &Var{Value: "a.0"}, Rhs: &Var{Value: "b.1"}, Dst: &Var{Value: "temp.1"}},
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+				{Name: "main", Instructions: []Instruction{
+					&Call{FunctionName: "f", Arguments: []Operand{}, ReturnValue: &Var{Value: "temp.2"}},
+					&Copy{Src: &Var{Value: "temp.2"}, Dst: &Var{Value: "pipe.1.0"}},
+					&Call{FunctionName: "h", Arguments: []Operand{}, ReturnValue: &Var{Value: "temp.4"}},
+					&Call{FunctionName: "g", Arguments: []Operand{&Var{Value: "temp.4"}, &Var{Value: "pipe.1.0"}}, ReturnValue: &Var{Value: "temp.3"}},
+					&Ret{Op: &Var{Value: "temp.3"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestMatchExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "enum E { A(i64), B }; fn main(): i64 = match E::A(3) { A(x) => x, B => 0 };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 0}, Dst: &Memory{Base: &Var{Value: "temp.2"}, Offset: 0}},
+					&Copy{Src: &Constant{Value: 3}, Dst: &Memory{Base: &Var{Value: "temp.2"}, Offset: 8}},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.2"}, Offset: 0}, Dst: &Var{Value: "temp.3", Type: types.I64}},
+					&Binary{Operator: ast.Equal, Lhs: &Var{Value: "temp.3"}, Rhs: &Constant{Value: 0}, Dst: &Var{Value: "temp.4"}},
+					&JumpIfZero{Value: &Var{Value: "temp.4"}, Label: "next"},
+					&Copy{Src: &Memory{Base: &Var{Value: "temp.2"}, Offset: 8}, Dst: &Var{Value: "temp.5"}},
+					&Copy{Src: &Var{Value: "temp.5"}, Dst: &Var{Value: "x.0"}},
+					&Copy{Src: &Var{Value: "x.0"}, Dst: &Var{Value: "temp.1"}},
+					Jump("end"),
+					Label("next"),
+					&Copy{Src: &Constant{Value: 0}, Dst: &Var{Value: "temp.1"}},
+					Label("end"),
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestReturnExpression(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn main(): i64 = if true { return 1 } else { 2 };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&JumpIfZero{Value: &Constant{Value: 1}, Label: "else"},
+					&Ret{Op: &Constant{Value: 1}},
+					Jump("end"),
+					Label("else"),
+					&Copy{Src: &Constant{Value: 2}, Dst: &Var{Value: "temp.1"}},
+					Label("end"),
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestConstDeclaration(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "const N: i64 = double(3) - 1; fn double(x: i64): i64 = x * 2; fn main(): i64 = N + 1;",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "double", Instructions: []Instruction{
+					&Binary{Operator: ast.Multiply, Lhs: &Var{Value: "x.0"}, Rhs: &Constant{Value: 2}, Dst: &Var{Value: "temp.1"}},
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+				{Name: "main", Instructions: []Instruction{
+					&Binary{Operator: ast.Add, Lhs: &Constant{Value: 5}, Rhs: &Constant{Value: 1}, Dst: &Var{Value: "temp.2"}},
+					&Ret{Op: &Var{Value: "temp.2"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestGlobalDeclaration(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "var count: i64 = 2; var table: [2]i8 = [1 as i8, -1 as i8]; fn main(): i64 = { count = count + 1; table[1] as i64 };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Global{Name: "count", Offset: 0}, Dst: &Var{Value: "temp.1"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.1"}, Rhs: &Constant{Value: 1}, Dst: &Var{Value: "temp.2"}},
+					&Copy{Src: &Var{Value: "temp.2"}, Dst: &Global{Name: "count", Offset: 0}},
+					&Copy{Src: &Global{Name: "table", Offset: 1}, Dst: &Var{Value: "temp.3"}},
+					&Convert{Src: &Var{Value: "temp.3"}, Dst: &Var{Value: "temp.4", Type: types.I64}},
+					&Ret{Op: &Var{Value: "temp.4"}},
+				}},
+			},
+			Globals: []*GlobalVariable{
+				{Name: "count", Value: []byte{2, 0, 0, 0, 0, 0, 0, 0}},
+				{Name: "table", Value: []byte{1, 255}},
+			},
+		},
+	})
+}
+
+func TestModules(t *testing.T) {
+	modules := []*ast.Module{
+		{Program: parse(t, "import util; fn double(): i64 = 0; fn main(): i64 = util.double(util.count);", "main.tt")},
+		{Path: []string{"util"}, Program: parse(t, "mod util; pub var count: i64 = 1; pub fn double(x: i64): i64 = x * 2;", "util.tt")},
+	}
+
+	tprogram, err := typechecker.New().CheckModules(modules)
+	if err != nil {
+		t.Fatalf("typechecker error: %q", err)
+	}
+
+	expectProgram(t, &Program{
+		Functions: []*Function{
+			{Name: "double", Instructions: []Instruction{
+				&Ret{Op: &Constant{Value: 0}},
+			}},
+			{Name: "main", Instructions: []Instruction{
+				&Copy{Src: &Global{Name: "util_count", Offset: 0}, Dst: &Var{Value: "temp.2"}},
+				&Call{FunctionName: "util_double", Arguments: []Operand{&Var{Value: "temp.2"}}, ReturnValue: &Var{Value: "temp.1"}},
+				&Ret{Op: &Var{Value: "temp.1"}},
+			}},
+			{Name: "util_double", Instructions: []Instruction{
+				&Binary{Operator: ast.Multiply, Lhs: &Var{Value: "x.0"}, Rhs: &Constant{Value: 2}, Dst: &Var{Value: "temp.3"}},
+				&Ret{Op: &Var{Value: "temp.3"}},
+			}},
+		},
+		Globals: []*GlobalVariable{
+			{Name: "util_count", Value: []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		},
+	}, EmitProgram(tprogram))
+}
+
+func TestExternFunctionDeclaration(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "extern fn twice(x: i64): i64; fn main(): i64 = twice(2);",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Call{FunctionName: "twice", Arguments: []Operand{&Constant{Value: 2}}, ReturnValue: &Var{Value: "temp.1"}},
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+			},
+			Externs: []string{"twice"},
+		},
+	})
+}
+
+func TestGenericFunctions(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn id[T](a: T): T = a; fn main(): i64 = id(1) + id(2 as u8) as i64;",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "main", Instructions: []Instruction{
+					&Call{FunctionName: "id.i64", Arguments: []Operand{&Constant{Value: 1}}, ReturnValue: &Var{Value: "temp.1"}},
+					&Convert{Src: &Constant{Value: 2}, Dst: &Var{Value: "temp.3", Type: types.U8}},
+					&Call{FunctionName: "id.u8", Arguments: []Operand{&Var{Value: "temp.3"}}, ReturnValue: &Var{Value: "temp.2"}},
+					&Convert{Src: &Var{Value: "temp.2"}, Dst: &Var{Value: "temp.4", Type: types.I64}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.1"}, Rhs: &Var{Value: "temp.4"}, Dst: &Var{Value: "temp.5"}},
+					&Ret{Op: &Var{Value: "temp.5"}},
+				}},
+				{Name: "id.i64", Instructions: []Instruction{
+					&Ret{Op: &Var{Value: "a.0"}},
+				}},
+				{Name: "id.u8", Instructions: []Instruction{
+					&Ret{Op: &Var{Value: "a.0"}},
+				}},
+			},
+		},
+	})
+}
+
+func TestClosures(t *testing.T) {
+	runTTIREmitterTest(t, ttirEmitterTest{
+		input: "fn one(): i64 = 1; fn main(): i64 = { n := 2; f := fn(x: i64): i64 = x + n; g := one; f(g()) };",
+		expected: Program{
+			Functions: []*Function{
+				{Name: "one", Instructions: []Instruction{
+					&Ret{Op: &Constant{Value: 1}},
+				}},
+				{Name: "main", Instructions: []Instruction{
+					&Copy{Src: &Constant{Value: 2}, Dst: &Var{Value: "n.0"}},
+					&Copy{Src: &Global{Name: "tt.closures.used"}, Dst: &Var{Value: "temp.5"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.5"}, Rhs: &Constant{Value: 8}, Dst: &Var{Value: "temp.6"}},
+					&Binary{Operator: ast.LessThanEqual, Lhs: &Var{Value: "temp.6"}, Rhs: &Constant{Value: 1 << 20}, Dst: &Var{Value: "temp.9"}},
+					&JumpIfNotZero{Value: &Var{Value: "temp.9"}, Label: "fits"},
+					&Copy{Src: &DataAddress{Name: "string.1"}, Dst: &Var{Value: "temp.10"}},
+					&Copy{Src: &DataAddress{Name: "string.2"}, Dst: &Var{Value: "temp.11"}},
+					&Panic{Location: &Var{Value: "temp.11"}, LocationLength: &Constant{Value: 14}, Message: &Var{Value: "temp.10"}, MessageLength: &Constant{Value: 65}},
+					Label("fits"),
+					&Copy{Src: &Var{Value: "temp.6"}, Dst: &Global{Name: "tt.closures.used"}},
+					&AddressOf{Src: &Global{Name: "tt.closures"}, Dst: &Var{Value: "temp.7"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "temp.7"}, Rhs: &Var{Value: "temp.5"}, Dst: &Var{Value: "temp.8"}},
+					&Store{Src: &Var{Value: "n.0"}, Address: &Var{Value: "temp.8"}},
+					&Copy{Src: &FunctionAddress{Name: "main.closure.0"}, Dst: &Memory{Base: &Var{Value: "temp.4"}, Offset: 0}},
+					&Copy{Src: &Var{Value: "temp.8"}, Dst: &Memory{Base: &Var{Value: "temp.4"}, Offset: 8}},
+					&Copy{Src: &Var{Value: "temp.4"}, Dst: &Var{Value: "f.2"}},
+					&Copy{Src: &FunctionAddress{Name: "one"}, Dst: &Memory{Base: &Var{Value: "temp.12"}, Offset: 0}},
+					&Copy{Src: &Constant{Value: 0}, Dst: &Memory{Base: &Var{Value: "temp.12"}, Offset: 8}},
+					&Copy{Src: &Var{Value: "temp.12"}, Dst: &Var{Value: "g.3"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "f.2"}, Offset: 0}, Dst: &Var{Value: "temp.13"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "f.2"}, Offset: 8}, Dst: &Var{Value: "temp.14"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "g.3"}, Offset: 0}, Dst: &Var{Value: "temp.16"}},
+					&Copy{Src: &Memory{Base: &Var{Value: "g.3"}, Offset: 8}, Dst: &Var{Value: "temp.17"}},
+					&CallIndirect{Code: &Var{Value: "temp.16"}, Environment: &Var{Value: "temp.17"}, Arguments: []Operand{}, ReturnValue: &Var{Value: "temp.18"}},
+					&CallIndirect{Code: &Var{Value: "temp.13"}, Environment: &Var{Value: "temp.14"}, Arguments: []Operand{&Var{Value: "temp.18"}}, ReturnValue: &Var{Value: "temp.15"}},
+					&Ret{Op: &Var{Value: "temp.15"}},
+				}},
+				{Name: "main.closure.0", Instructions: []Instruction{
+					&Load{Address: &Var{Value: "temp.2"}, Dst: &Var{Value: "temp.3"}},
+					&Copy{Src: &Var{Value: "temp.3"}, Dst: &Var{Value: "n.0"}},
+					&Binary{Operator: ast.Add, Lhs: &Var{Value: "x.1"}, Rhs: &Var{Value: "n.0"}, Dst: &Var{Value: "temp.1"}},
+					&Ret{Op: &Var{Value: "temp.1"}},
+				}},
+			},
+			Data: []*Data{
+				{Name: "string.1", Value: []byte("out of memory for closure environments, they are limited to 1 MiB\x00")},
+				{Name: "string.2", Value: []byte("test.tt:1:51: \x00")},
+			},
+			Globals: []*GlobalVariable{
+				{Name: "tt.closures", Value: make([]byte, 1<<20)},
+				{Name: "tt.closures.used", Value: make([]byte, 8)},
+			},
+		},
+	})
+}
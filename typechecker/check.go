@@ -3,8 +3,11 @@ package typechecker
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
+	"robaertschi.xyz/robaertschi/tt/lexer"
 	"robaertschi.xyz/robaertschi/tt/tast"
 	"robaertschi.xyz/robaertschi/tt/token"
 	"robaertschi.xyz/robaertschi/tt/types"
@@ -25,6 +28,38 @@ func copyVars(vars Variables) Variables {
 type Checker struct {
 	foundMain         bool
 	functionVariables map[string]Variables
+	// The types of the loops the inferer is currently in, the innermost loop is last
+	loopTypes []types.Type
+	// The return type of the function the inferer is currently in
+	returnType types.Type
+	structs    map[string]*types.StructType
+	enums      map[string]*types.EnumType
+	// The types of the constants
+	constants map[string]types.Type
+	// The types of the global variables
+	globals map[string]types.Type
+	// The variables of the current function, whose address is taken
+	addressTaken map[string]bool
+	// The types of the functions, constants and global variables, which every function can use
+	declarations Variables
+	// The generic functions, they are inferred again for every instantiation
+	generics map[string]*ast.FunctionDeclaration
+	// The types of the type parameters of the instance, which the inferer is currently in
+	typeParameters map[string]types.Type
+	// The types of the already inferred instances by their symbol
+	instanceTypes map[string]*types.FunctionType
+	// The inferred instances in the order they were instantiated
+	instances []tast.Declaration
+	// The number of instances, which are inferred at the moment, to stop endless instantiations
+	instantiationDepth int
+	// The symbol of the function the inferer is currently in, closures are named after it
+	function string
+	// The number of closures in every function, to give each closure its own name
+	closureCounts map[string]int
+	// The source code of every file, to show the line of an error
+	sources map[string]string
+	// The variables, which the closure the inferer is currently in captures, they can not be assigned to
+	captures map[string]bool
 }
 
 func New() *Checker {
@@ -35,8 +70,27 @@ func (c *Checker) error(t token.Token, format string, args ...any) error {
 	return fmt.Errorf("%s:%d:%d %s", t.Loc.File, t.Loc.Line, t.Loc.Col, fmt.Sprintf(format, args...))
 }
 
+// Like error, but the line of the token follows with carets under the token, like the errors of the parser
+func (c *Checker) caretError(t token.Token, format string, args ...any) error {
+	source, ok := c.sources[t.Loc.File]
+	if !ok {
+		return c.error(t, format, args...)
+	}
+	return c.error(t, "%s\n%s", fmt.Sprintf(format, args...), lexer.Caret(source, t.Loc, len(t.Literal)))
+}
+
 func (c *Checker) CheckProgram(program *ast.Program) (*tast.Program, error) {
-	_, err := VarResolve(program)
+	return c.CheckModules([]*ast.Module{{Program: program}})
+}
+
+// Checks a program, which consists of multiple modules, the first module is the main module
+func (c *Checker) CheckModules(modules []*ast.Module) (*tast.Program, error) {
+	c.sources = make(map[string]string)
+	for _, module := range modules {
+		c.sources[module.Program.File] = module.Program.Source
+	}
+
+	program, err := VarResolve(modules)
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +113,10 @@ func (c *Checker) CheckProgram(program *ast.Program) (*tast.Program, error) {
 		return nil, errors.Join(errs...)
 	}
 
+	if err := c.evaluate(newProgram); err != nil {
+		return nil, err
+	}
+
 	if !c.foundMain {
 		// TODO(Robin): Add support for libraries
 		errs = append(errs, errors.New("no function called 'main' found"))
@@ -76,10 +134,39 @@ func (c *Checker) checkDeclaration(decl tast.Declaration) error {
 			return err
 		}
 
+		if _, ok := types.Unify(decl.Body.Type(), decl.ReturnType); !ok {
+			return c.error(decl.Token, "the body of function %q has type %q, but the function returns %q", decl.Name, decl.Body.Type().Name(), decl.ReturnType.Name())
+		}
+
 		if decl.Name == "main" {
 			c.foundMain = true
+			if !types.IsInteger(decl.ReturnType) && !decl.ReturnType.IsSameType(types.Bool) && !decl.ReturnType.IsSameType(types.Unit) {
+				return c.error(decl.Token, "the main function has to return an integer, a bool or (), but returns %q", decl.ReturnType.Name())
+			}
 		}
 
+		return nil
+	case *tast.ExternFunctionDeclaration:
+		return nil
+	case *tast.ConstDeclaration:
+		if err := c.checkExpression(c.functionVariables[decl.Name], decl.Value); err != nil {
+			return err
+		}
+
+		if !decl.Value.Type().IsSameType(decl.ConstType) {
+			return c.error(decl.Value.Tok(), "the constant %q has the type %q, but its value has the type %q", decl.Name, decl.ConstType.Name(), decl.Value.Type().Name())
+		}
+		return nil
+	case *tast.GlobalDeclaration:
+		if err := c.checkExpression(c.functionVariables[decl.Name], decl.Value); err != nil {
+			return err
+		}
+
+		if !decl.Value.Type().IsSameType(decl.GlobalType) {
+			return c.error(decl.Value.Tok(), "the global variable %q has the type %q, but its value has the type %q", decl.Name, decl.GlobalType.Name(), decl.Value.Type().Name())
+		}
+		return nil
+	case *tast.StructDeclaration, *tast.EnumDeclaration:
 		return nil
 	}
 	return errors.New("unhandled declaration in type checker")
@@ -89,8 +176,35 @@ func (c *Checker) checkExpression(vars Variables, expr tast.Expression) error {
 	switch expr := expr.(type) {
 	case *tast.IntegerExpression:
 		return nil
+	case *tast.FloatExpression:
+		return nil
 	case *tast.BooleanExpression:
 		return nil
+	case *tast.StringExpression:
+		return nil
+	case *tast.UnaryExpression:
+		err := c.checkExpression(vars, expr.Operand)
+		if err != nil {
+			return err
+		}
+
+		if !expr.Operand.Type().SupportsUnaryOperator(expr.Operator) {
+			return c.error(expr.Token, "the operator %q is not supported by the type %q", expr.Operator.SymbolString(), expr.Operand.Type().Name())
+		}
+		return nil
+	case *tast.CastExpression:
+		err := c.checkExpression(vars, expr.Expression)
+		if err != nil {
+			return err
+		}
+
+		// Integers and floats can be converted into each other, booleans only into integers
+		from := expr.Expression.Type()
+		isNumber := func(t types.Type) bool { return types.IsInteger(t) || types.IsFloat(t) }
+		if !isNumber(expr.TargetType) || !(isNumber(from) || (from.IsSameType(types.Bool) && types.IsInteger(expr.TargetType))) {
+			return c.error(expr.Token, "can not convert from %q to %q", from.Name(), expr.TargetType.Name())
+		}
+		return nil
 	case *tast.BinaryExpression:
 		lhsErr := c.checkExpression(vars, expr.Lhs)
 		rhsErr := c.checkExpression(vars, expr.Rhs)
@@ -129,28 +243,251 @@ func (c *Checker) checkExpression(vars Variables, expr tast.Expression) error {
 
 		elseErr := c.checkExpression(vars, expr.Else)
 		if thenErr == nil && elseErr == nil {
-			if !expr.Then.Type().IsSameType(expr.Else.Type()) {
+			if _, ok := types.Unify(expr.Then.Type(), expr.Else.Type()); !ok {
 				thenErr = c.error(expr.Token, "the then branch of type %q does not match with the else branch of type %q", expr.Then.Type().Name(), expr.Else.Type().Name())
 			}
 		}
 		return errors.Join(condErr, thenErr, elseErr)
 	case *tast.AssignmentExpression:
-		varRef, ok := expr.Lhs.(*tast.VariableReference)
-		if !ok {
+		if !isAssignable(expr.Lhs) {
 			return c.error(expr.Token, "not a valid assignment target")
 		}
 
-		if !expr.Lhs.Type().IsSameType(expr.Lhs.Type()) {
+		lhsErr := c.checkExpression(vars, expr.Lhs)
+		rhsErr := c.checkExpression(vars, expr.Rhs)
+		if lhsErr != nil || rhsErr != nil {
+			return errors.Join(lhsErr, rhsErr)
+		}
+
+		if !expr.AssignedType().IsSameType(expr.Lhs.Type()) {
 			return c.error(
 				expr.Rhs.Tok(),
-				"the assignment rhs has the wrong type, variable %q has type %q but got %q",
-				varRef.Identifier,
-				varRef.Type().Name(),
-				expr.Rhs.String(),
+				"the assignment rhs has the wrong type, %s has type %q but got %q",
+				expr.Lhs,
+				expr.Lhs.Type().Name(),
+				expr.AssignedType().Name(),
 			)
 		}
+		if expr.Compound && !expr.Lhs.Type().SupportsBinaryOperator(expr.Operator) {
+			return c.error(expr.Token, "the operator %q is not supported by the type %q", expr.Operator.SymbolString(), expr.Lhs.Type().Name())
+		}
+		return nil
+	case *tast.StructExpression:
+		errs := []error{}
+		initialized := make(map[string]bool)
+
+		for _, field := range expr.Fields {
+			if err := c.checkExpression(vars, field.Value); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			structField, ok := expr.StructType.Field(field.Name)
+			if !ok {
+				errs = append(errs, c.error(field.Token, "the struct %q has no field %q", expr.StructType.Name(), field.Name))
+				continue
+			}
+
+			if initialized[field.Name] {
+				errs = append(errs, c.error(field.Token, "the field %q is initialized more than once", field.Name))
+				continue
+			}
+			initialized[field.Name] = true
+
+			if !field.Value.Type().IsSameType(structField.Type) {
+				errs = append(errs, c.error(field.Token, "the field %q has type %q, but got %q", field.Name, structField.Type.Name(), field.Value.Type().Name()))
+			}
+		}
+
+		for _, field := range expr.StructType.Fields {
+			if !initialized[field.Name] && len(errs) == 0 {
+				errs = append(errs, c.error(expr.Token, "the field %q of struct %q is missing", field.Name, expr.StructType.Name()))
+			}
+		}
+
+		return errors.Join(errs...)
+	case *tast.FieldAccessExpression:
+		return c.checkExpression(vars, expr.Expression)
+	case *tast.ArrayExpression:
+		errs := []error{}
+		for _, element := range expr.Elements {
+			if err := c.checkExpression(vars, element); err != nil {
+				errs = append(errs, err)
+			} else if element.Type().IsSameType(types.Unit) {
+				errs = append(errs, c.error(element.Tok(), "the elements of an array can not have the type %q", element.Type().Name()))
+			} else if !element.Type().IsSameType(expr.ArrayType.Element) {
+				errs = append(errs, c.error(element.Tok(), "all elements of an array need the same type, expected %q but got %q", expr.ArrayType.Element.Name(), element.Type().Name()))
+			}
+		}
+		return errors.Join(errs...)
+	case *tast.IndexExpression:
+		if err := errors.Join(c.checkExpression(vars, expr.Expression), c.checkExpression(vars, expr.Index)); err != nil {
+			return err
+		}
+
+		if !types.IsInteger(expr.Index.Type()) {
+			return c.error(expr.Index.Tok(), "the index should be an integer, but got %q", expr.Index.Type().Name())
+		}
+
+		if array, ok := expr.Expression.Type().(*types.ArrayType); ok {
+			if index, ok := constantValue(expr.Index); ok && (index < 0 || index >= array.Length) {
+				return c.error(expr.Index.Tok(), "the index %d is out of bounds for the array of type %q", index, array.Name())
+			}
+		}
+		return nil
+	case *tast.SliceExpression:
+		errs := []error{c.checkExpression(vars, expr.Expression)}
+		for _, bound := range []tast.Expression{expr.Start, expr.End} {
+			if bound == nil {
+				continue
+			}
+
+			if err := c.checkExpression(vars, bound); err != nil {
+				errs = append(errs, err)
+			} else if !types.IsInteger(bound.Type()) {
+				errs = append(errs, c.error(bound.Tok(), "the bounds of a slice should be integers, but got %q", bound.Type().Name()))
+			}
+		}
+
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+
+		start, end := int64(0), int64(-1)
+		if array, ok := expr.Expression.Type().(*types.ArrayType); ok {
+			end = array.Length
+		}
+		if expr.Start != nil {
+			if value, ok := constantValue(expr.Start); ok {
+				start = value
+			}
+		}
+		if expr.End != nil {
+			end = -1
+			if value, ok := constantValue(expr.End); ok {
+				end = value
+			}
+		}
+
+		if start < 0 {
+			return c.error(expr.Token, "the start of the slice %d is negative", start)
+		}
+		if end >= 0 && start > end {
+			return c.error(expr.Token, "the start of the slice %d is after the end %d", start, end)
+		}
+		if array, ok := expr.Expression.Type().(*types.ArrayType); ok && (start > array.Length || end > array.Length) {
+			return c.error(expr.Token, "the slice is out of bounds for the array of type %q", array.Name())
+		}
+		return nil
+	case *tast.LenExpression:
+		if err := c.checkExpression(vars, expr.Expression); err != nil {
+			return err
+		}
+
+		if _, ok := elementType(expr.Expression.Type()); !ok {
+			return c.error(expr.Token, "len expects an array, a slice or a string, but got %q", expr.Expression.Type().Name())
+		}
+		return nil
+	case *tast.EnumExpression:
+		variant, _ := expr.EnumType.Variant(expr.Variant)
+		if len(expr.Arguments) != len(variant.Fields) {
+			return c.error(expr.Token, "the variant %q of enum %q has %d values, but got %d", expr.Variant, expr.EnumType.Name(), len(variant.Fields), len(expr.Arguments))
+		}
+
+		errs := []error{}
+		for i, field := range variant.Fields {
+			arg := expr.Arguments[i]
+			if err := c.checkExpression(vars, arg); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !arg.Type().IsSameType(field) {
+				errs = append(errs, c.error(arg.Tok(), "invalid type for variant %q, expected %q but got %q", expr.Variant, field.Name(), arg.Type().Name()))
+			}
+		}
+		return errors.Join(errs...)
+	case *tast.MatchExpression:
+		errs := []error{c.checkExpression(vars, expr.Expression)}
+		et := expr.Expression.Type().(*types.EnumType)
+
+		matched := make(map[string]bool)
+		exhaustive := false
+		for _, arm := range expr.Arms {
+			if exhaustive {
+				errs = append(errs, c.error(arm.Token, "the arm is unreachable, all variants are already matched"))
+			} else if matched[arm.Variant] {
+				errs = append(errs, c.error(arm.Token, "the variant %q is already matched", arm.Variant))
+			}
+
+			if arm.Variant == "_" {
+				exhaustive = true
+			} else {
+				matched[arm.Variant] = true
+				exhaustive = exhaustive || len(matched) == len(et.Variants)
+			}
+
+			if err := c.checkExpression(vars, arm.Body); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if _, ok := types.Unify(arm.Body.Type(), expr.ReturnType); !ok {
+				errs = append(errs, c.error(arm.Body.Tok(), "the arm of type %q does not match the first arm of type %q", arm.Body.Type().Name(), expr.ReturnType.Name()))
+			}
+		}
+
+		if !exhaustive {
+			missing := []string{}
+			for _, variant := range et.Variants {
+				if !matched[variant.Name] {
+					missing = append(missing, strconv.Quote(variant.Name))
+				}
+			}
+			errs = append(errs, c.error(expr.Token, "the match is not exhaustive, the variants %s are not matched", strings.Join(missing, ", ")))
+		}
+		return errors.Join(errs...)
+	case *tast.AddressOfExpression:
+		if err := c.checkExpression(vars, expr.Expression); err != nil {
+			return err
+		}
+
+		if !isAssignable(expr.Expression) {
+			return c.error(expr.Token, "the address can only be taken of variables, their fields and elements")
+		}
+		return nil
+	case *tast.DereferenceExpression:
+		return c.checkExpression(vars, expr.Expression)
+	case *tast.PrintExpression:
+		if err := c.checkExpression(vars, expr.Expression); err != nil {
+			return err
+		}
+
+		if !expr.Expression.Type().IsSameType(types.Str) {
+			return c.error(expr.Token, "print expects a string, but got %q", expr.Expression.Type().Name())
+		}
+		return nil
+	case *tast.PanicExpression:
+		if err := c.checkExpression(vars, expr.Message); err != nil {
+			return err
+		}
+
+		if !expr.Message.Type().IsSameType(types.Str) {
+			return c.error(expr.Token, "panic expects a string, but got %q", expr.Message.Type().Name())
+		}
+		return nil
+	case *tast.AssertExpression:
+		if err := c.checkExpression(vars, expr.Condition); err != nil {
+			return err
+		}
+
+		if !expr.Condition.Type().IsSameType(types.Bool) {
+			return c.error(expr.Token, "assert expects a boolean, but got %q", expr.Condition.Type().Name())
+		}
 		return nil
 	case *tast.VariableDeclaration:
+		if err := c.checkExpression(vars, expr.InitializingExpression); err != nil {
+			return err
+		}
+
 		if !expr.VariableType.IsSameType(expr.InitializingExpression.Type()) {
 			return c.error(expr.InitializingExpression.Tok(),
 				"initializing expression for variable %q has wrong type, expected %q but got %q",
@@ -159,27 +496,140 @@ func (c *Checker) checkExpression(vars Variables, expr tast.Expression) error {
 				expr.InitializingExpression.Type().Name(),
 			)
 		}
+
+		if expr.VariableType.IsSameType(types.Never) {
+			return c.error(expr.InitializingExpression.Tok(), "the initializing expression for variable %q never produces a value", expr.Identifier)
+		}
 		return nil
 	case *tast.VariableReference:
 		return nil
-	case *tast.FunctionCall:
-		functionType := vars[expr.Identifier].(*types.FunctionType)
-		if len(expr.Arguments) != len(functionType.Parameters) {
-			return c.error(expr.Token, "invalid amount of arguments for function %q, expected %d but got %d", expr.Identifier, len(functionType.Parameters), len(expr.Arguments))
+	case *tast.ConstReference, *tast.GlobalReference:
+		return nil
+	case *tast.WhileExpression:
+		condErr := c.checkExpression(vars, expr.Condition)
+		if condErr == nil {
+			if !expr.Condition.Type().IsSameType(types.Bool) {
+				condErr = c.error(expr.Token, "the condition in the while should be a boolean, but got %q", expr.Condition.Type().Name())
+			}
 		}
+		bodyErr := c.checkExpression(vars, expr.Body)
 
-		errs := []error{}
+		if expr.Else == nil {
+			return errors.Join(condErr, bodyErr)
+		}
 
-		for i, param := range functionType.Parameters {
-			e := expr.Arguments[i]
-			if !e.Type().IsSameType(param) {
-				errs = append(errs, c.error(e.Tok(), "invalid type for parameter, expected %q but got %q", param.Name(), e.Type().Name()))
+		return errors.Join(condErr, bodyErr, c.checkExpression(vars, expr.Else))
+	case *tast.ForExpression:
+		startErr := c.checkExpression(vars, expr.Start)
+		endErr := c.checkExpression(vars, expr.End)
+		var rangeErr error
+		if startErr == nil && endErr == nil {
+			if !types.IsInteger(expr.Start.Type()) {
+				rangeErr = c.error(expr.Start.Tok(), "the start of the range should be an integer, but got %q", expr.Start.Type().Name())
+			} else if !expr.Start.Type().IsSameType(expr.End.Type()) {
+				rangeErr = c.error(expr.End.Tok(), "the end of the range has type %q, but the start has type %q", expr.End.Type().Name(), expr.Start.Type().Name())
 			}
 		}
 
-		return errors.Join(errs...)
+		return errors.Join(startErr, endErr, rangeErr, c.checkExpression(vars, expr.Body))
+	case *tast.BreakExpression:
+		if expr.Value == nil {
+			if !expr.LoopType.IsSameType(types.Unit) {
+				return c.error(expr.Token, "the loop has type %q, but break has no value", expr.LoopType.Name())
+			}
+			return nil
+		}
+
+		err := c.checkExpression(vars, expr.Value)
+		if err != nil {
+			return err
+		}
+
+		if !expr.Value.Type().IsSameType(expr.LoopType) {
+			if expr.LoopType.IsSameType(types.Unit) {
+				return c.error(expr.Token, "break with a value of type %q requires the loop to have an else branch of the same type", expr.Value.Type().Name())
+			}
+			return c.error(expr.Token, "the break value of type %q does not match the loop type %q", expr.Value.Type().Name(), expr.LoopType.Name())
+		}
+		return nil
+	case *tast.ContinueExpression:
+		return nil
+	case *tast.ReturnExpression:
+		if expr.Value == nil {
+			if !expr.FunctionReturnType.IsSameType(types.Unit) {
+				return c.error(expr.Token, "the function returns %q, but return has no value", expr.FunctionReturnType.Name())
+			}
+			return nil
+		}
+
+		err := c.checkExpression(vars, expr.Value)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := types.Unify(expr.Value.Type(), expr.FunctionReturnType); !ok {
+			return c.error(expr.Token, "the return value of type %q does not match the return type %q of the function", expr.Value.Type().Name(), expr.FunctionReturnType.Name())
+		}
+		return nil
+	case *tast.FunctionCall:
+		functionType := vars[expr.Identifier].(*types.FunctionType)
+		return c.checkArguments(vars, expr.Token, expr.Identifier, functionType, expr.Arguments)
+	case *tast.FunctionReference:
+		return nil
+	case *tast.IndirectCall:
+		if err := c.checkExpression(vars, expr.Function); err != nil {
+			return err
+		}
+
+		functionType := expr.Function.Type().(*types.FunctionType)
+		return c.checkArguments(vars, expr.Token, expr.Function.String(), functionType, expr.Arguments)
+	case *tast.ClosureExpression:
+		if err := c.checkExpression(c.functionVariables[expr.Name], expr.Body); err != nil {
+			return err
+		}
+
+		if _, ok := types.Unify(expr.Body.Type(), expr.ClosureType.ReturnType); !ok {
+			return c.error(expr.Token, "the body of the closure has type %q, but the closure returns %q", expr.Body.Type().Name(), expr.ClosureType.ReturnType.Name())
+		}
+		return nil
 	default:
 		panic(fmt.Sprintf("unexpected tast.Expression: %#v", expr))
 	}
-	return fmt.Errorf("unhandled expression %T in type checker", expr)
+}
+
+// Checks the arguments of a call of the function name with the type functionType
+func (c *Checker) checkArguments(vars Variables, tok token.Token, name string, functionType *types.FunctionType, arguments []tast.Expression) error {
+	if len(arguments) != len(functionType.Parameters) {
+		return c.error(tok, "invalid amount of arguments for function %q, expected %d but got %d", name, len(functionType.Parameters), len(arguments))
+	}
+
+	errs := []error{}
+
+	for i, param := range functionType.Parameters {
+		e := arguments[i]
+		if err := c.checkExpression(vars, e); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !e.Type().IsSameType(param) {
+			errs = append(errs, c.error(e.Tok(), "invalid type for parameter, expected %q but got %q", param.Name(), e.Type().Name()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the value of integer constants, which are known at compile time
+func constantValue(expr tast.Expression) (int64, bool) {
+	switch expr := expr.(type) {
+	case *tast.IntegerExpression:
+		return expr.Value, true
+	case *tast.UnaryExpression:
+		if expr.Operator == ast.Negate {
+			if value, ok := constantValue(expr.Operand); ok {
+				return -value, true
+			}
+		}
+	}
+	return 0, false
 }
@@ -0,0 +1,384 @@
+package typechecker
+
+import (
+	"errors"
+	"math/big"
+
+	"robaertschi.xyz/robaertschi/tt/ast"
+	"robaertschi.xyz/robaertschi/tt/tast"
+	"robaertschi.xyz/robaertschi/tt/token"
+	"robaertschi.xyz/robaertschi/tt/types"
+)
+
+// The maximum depth of function calls while evaluating a constant, deeper calls are most likely an endless recursion
+const maxEvaluationDepth = 1000
+
+// Evaluates constants at compile time, values of every type are stored in an int64.
+// Signed integers are sign extended, unsigned integers zero extended and booleans are 0 or 1.
+type evaluator struct {
+	c         *Checker
+	functions map[string]*tast.FunctionDeclaration
+	constants map[string]*tast.ConstDeclaration
+	// The constants, which already have their value
+	evaluated map[string]bool
+	// The constants, which are currently evaluated, to find constants that depend on themselves
+	evaluating map[string]bool
+	depth      int
+}
+
+// A return expression leaves the function, it is passed up to the call like an error
+type returnValue struct {
+	value int64
+}
+
+func (r *returnValue) Error() string {
+	return "return outside of a function"
+}
+
+// Evaluates the value of every constant and the initial value of every global variable and stores it in the declaration
+func (c *Checker) evaluate(program *tast.Program) error {
+	e := &evaluator{
+		c:          c,
+		functions:  make(map[string]*tast.FunctionDeclaration),
+		constants:  make(map[string]*tast.ConstDeclaration),
+		evaluated:  make(map[string]bool),
+		evaluating: make(map[string]bool),
+	}
+
+	for _, decl := range program.Declarations {
+		switch decl := decl.(type) {
+		case *tast.FunctionDeclaration:
+			e.functions[decl.Name] = decl
+		case *tast.ConstDeclaration:
+			e.constants[decl.Name] = decl
+		}
+	}
+
+	errs := []error{}
+	for _, decl := range program.Declarations {
+		switch decl := decl.(type) {
+		case *tast.ConstDeclaration:
+			if _, err := e.constant(decl.Token, decl); err != nil {
+				errs = append(errs, err)
+			}
+		case *tast.GlobalDeclaration:
+			decl.Result = make([]byte, decl.GlobalType.Size())
+			if err := e.initialValue(decl.Result, decl.Value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Writes the value of expr into memory in little endian, arrays and structs are laid out like at runtime
+func (e *evaluator) initialValue(memory []byte, expr tast.Expression) error {
+	switch expr := expr.(type) {
+	case *tast.ArrayExpression:
+		size := expr.ArrayType.Element.Size()
+		for i, element := range expr.Elements {
+			offset := int64(i) * size
+			if err := e.initialValue(memory[offset:offset+size], element); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *tast.StructExpression:
+		for _, field := range expr.Fields {
+			f, _ := expr.StructType.Field(field.Name)
+			if err := e.initialValue(memory[f.Offset:f.Offset+f.Type.Size()], field.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	value, err := e.expression(make(map[string]int64), expr)
+	if err != nil {
+		return err
+	}
+	for i := range memory {
+		memory[i] = byte(value >> (8 * i))
+	}
+	return nil
+}
+
+func (e *evaluator) constant(tok token.Token, decl *tast.ConstDeclaration) (int64, error) {
+	if e.evaluated[decl.Name] {
+		return decl.Result, nil
+	}
+	if e.evaluating[decl.Name] {
+		return 0, e.c.error(tok, "the constant %q depends on itself", decl.Name)
+	}
+
+	e.evaluating[decl.Name] = true
+	value, err := e.expression(make(map[string]int64), decl.Value)
+	e.evaluating[decl.Name] = false
+	if err != nil {
+		return 0, err
+	}
+
+	decl.Result = value
+	e.evaluated[decl.Name] = true
+	return value, nil
+}
+
+func (e *evaluator) expression(vars map[string]int64, expr tast.Expression) (int64, error) {
+	switch expr := expr.(type) {
+	case *tast.IntegerExpression:
+		return expr.Value, nil
+	case *tast.BooleanExpression:
+		if expr.Value {
+			return 1, nil
+		}
+		return 0, nil
+	case *tast.ConstReference:
+		return e.constant(expr.Token, e.constants[expr.Identifier])
+	case *tast.GlobalReference:
+		return 0, e.c.error(expr.Token, "the global variable %q can change, so it can not be evaluated at compile time", expr.Identifier)
+	case *tast.VariableReference:
+		value, ok := vars[expr.Identifier]
+		if !ok {
+			return 0, e.c.error(expr.Token, "the variable %q can not be evaluated at compile time", expr.Identifier)
+		}
+		return value, nil
+	case *tast.VariableDeclaration:
+		value, err := e.expression(vars, expr.InitializingExpression)
+		if err != nil {
+			return 0, err
+		}
+		vars[expr.Identifier] = value
+		return 0, nil
+	case *tast.BlockExpression:
+		for _, expr := range expr.Expressions {
+			if _, err := e.expression(vars, expr); err != nil {
+				return 0, err
+			}
+		}
+		if expr.ReturnExpression != nil {
+			return e.expression(vars, expr.ReturnExpression)
+		}
+		return 0, nil
+	case *tast.IfExpression:
+		cond, err := e.expression(vars, expr.Condition)
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return e.expression(vars, expr.Then)
+		}
+		if expr.Else != nil {
+			return e.expression(vars, expr.Else)
+		}
+		return 0, nil
+	case *tast.UnaryExpression:
+		value, err := e.expression(vars, expr.Operand)
+		if err != nil {
+			return 0, err
+		}
+		t := expr.Operand.Type()
+
+		switch expr.Operator {
+		case ast.Negate:
+			return e.fromBig(expr.Token, new(big.Int).Neg(toBig(value, t)), t)
+		case ast.Complement:
+			return truncate(^value, t), nil
+		case ast.Not:
+			return 1 - value, nil
+		}
+	case *tast.CastExpression:
+		if types.IsFloat(expr.TargetType) {
+			return 0, e.c.error(expr.Token, "floats can not be evaluated at compile time")
+		}
+		value, err := e.expression(vars, expr.Expression)
+		if err != nil {
+			return 0, err
+		}
+		return truncate(value, expr.TargetType), nil
+	case *tast.BinaryExpression:
+		lhs, err := e.expression(vars, expr.Lhs)
+		if err != nil {
+			return 0, err
+		}
+
+		// The rhs of the logical operators is only evaluated if it decides the result
+		switch expr.Operator {
+		case ast.LogicalAnd:
+			if lhs == 0 {
+				return 0, nil
+			}
+			return e.expression(vars, expr.Rhs)
+		case ast.LogicalOr:
+			if lhs != 0 {
+				return 1, nil
+			}
+			return e.expression(vars, expr.Rhs)
+		}
+
+		rhs, err := e.expression(vars, expr.Rhs)
+		if err != nil {
+			return 0, err
+		}
+		return e.binary(expr.Token, expr.Operator, lhs, rhs, expr.Lhs.Type())
+	case *tast.FunctionCall:
+		function, ok := e.functions[expr.Identifier]
+		if !ok {
+			break
+		}
+		if e.depth >= maxEvaluationDepth {
+			return 0, e.c.error(expr.Token, "the calls of %q are nested deeper than %d while evaluating a constant", expr.Identifier, maxEvaluationDepth)
+		}
+
+		callVars := make(map[string]int64)
+		for i, arg := range expr.Arguments {
+			value, err := e.expression(vars, arg)
+			if err != nil {
+				return 0, err
+			}
+			callVars[function.Parameters[i].Name] = value
+		}
+
+		e.depth += 1
+		value, err := e.expression(callVars, function.Body)
+		e.depth -= 1
+
+		var r *returnValue
+		if errors.As(err, &r) {
+			return r.value, nil
+		}
+		return value, err
+	case *tast.ReturnExpression:
+		value := int64(0)
+		if expr.Value != nil {
+			var err error
+			value, err = e.expression(vars, expr.Value)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return 0, &returnValue{value: value}
+	}
+
+	return 0, e.c.error(expr.Tok(), "the expression can not be evaluated at compile time")
+}
+
+func (e *evaluator) binary(tok token.Token, op ast.BinaryOperator, lhs, rhs int64, t types.Type) (int64, error) {
+	bits := t.Size() * 8
+
+	switch op {
+	case ast.Add:
+		return e.fromBig(tok, new(big.Int).Add(toBig(lhs, t), toBig(rhs, t)), t)
+	case ast.Subtract:
+		return e.fromBig(tok, new(big.Int).Sub(toBig(lhs, t), toBig(rhs, t)), t)
+	case ast.Multiply:
+		return e.fromBig(tok, new(big.Int).Mul(toBig(lhs, t), toBig(rhs, t)), t)
+	case ast.Divide, ast.Modulo:
+		if rhs == 0 {
+			return 0, e.c.error(tok, "division by zero in a constant expression")
+		}
+		// Quo and Rem truncate like the division at runtime
+		if op == ast.Divide {
+			return e.fromBig(tok, new(big.Int).Quo(toBig(lhs, t), toBig(rhs, t)), t)
+		}
+		return e.fromBig(tok, new(big.Int).Rem(toBig(lhs, t), toBig(rhs, t)), t)
+	case ast.BitwiseAnd:
+		return lhs & rhs, nil
+	case ast.BitwiseOr:
+		return lhs | rhs, nil
+	case ast.BitwiseXor:
+		return lhs ^ rhs, nil
+	case ast.ShiftLeft, ast.ShiftRight, ast.ShiftRightLogical:
+		count := toBig(rhs, t)
+		if count.Sign() < 0 || count.Cmp(big.NewInt(bits)) >= 0 {
+			return 0, e.c.error(tok, "the shift count %s is out of range for the type %q", count, t.Name())
+		}
+
+		switch op {
+		case ast.ShiftLeft:
+			return truncate(lhs<<rhs, t), nil
+		case ast.ShiftRight:
+			if types.IsSigned(t) {
+				return lhs >> rhs, nil
+			}
+			return int64(uint64(lhs) >> rhs), nil
+		default:
+			return truncate(int64(uint64(truncate(lhs, unsignedOf(t)))>>rhs), t), nil
+		}
+	case ast.Equal:
+		return boolValue(lhs == rhs), nil
+	case ast.NotEqual:
+		return boolValue(lhs != rhs), nil
+	case ast.LessThan, ast.LessThanEqual, ast.GreaterThan, ast.GreaterThanEqual:
+		cmp := toBig(lhs, t).Cmp(toBig(rhs, t))
+		switch op {
+		case ast.LessThan:
+			return boolValue(cmp < 0), nil
+		case ast.LessThanEqual:
+			return boolValue(cmp <= 0), nil
+		case ast.GreaterThan:
+			return boolValue(cmp > 0), nil
+		default:
+			return boolValue(cmp >= 0), nil
+		}
+	}
+
+	return 0, e.c.error(tok, "the operator %q can not be evaluated at compile time", op.SymbolString())
+}
+
+// Converts the result of an operation back to the type t, a result that does not fit into t is an overflow
+func (e *evaluator) fromBig(tok token.Token, value *big.Int, t types.Type) (int64, error) {
+	bits := uint(t.Size() * 8)
+
+	lowest, highest := new(big.Int), new(big.Int).Lsh(big.NewInt(1), bits)
+	if types.IsSigned(t) {
+		lowest.Neg(new(big.Int).Lsh(big.NewInt(1), bits-1))
+		highest.Lsh(big.NewInt(1), bits-1)
+	}
+	highest.Sub(highest, big.NewInt(1))
+
+	if value.Cmp(lowest) < 0 || value.Cmp(highest) > 0 {
+		return 0, e.c.error(tok, "the result %s of the constant expression overflows the type %q", value, t.Name())
+	}
+
+	if types.IsSigned(t) {
+		return value.Int64(), nil
+	}
+	return int64(value.Uint64()), nil
+}
+
+func toBig(value int64, t types.Type) *big.Int {
+	if types.IsSigned(t) {
+		return big.NewInt(value)
+	}
+	return new(big.Int).SetUint64(uint64(value))
+}
+
+// Truncates the value to the size of t and extends it again, like a cast at runtime
+func truncate(value int64, t types.Type) int64 {
+	shift := 64 - t.Size()*8
+	if types.IsSigned(t) {
+		return value << shift >> shift
+	}
+	return int64(uint64(value) << shift >> shift)
+}
+
+// Returns the unsigned integer type with the same size as t
+func unsignedOf(t types.Type) types.Type {
+	switch t.Size() {
+	case 1:
+		return types.U8
+	case 2:
+		return types.U16
+	case 4:
+		return types.U32
+	}
+	return types.U64
+}
+
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -3,14 +3,363 @@ package typechecker
 import (
 	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
 	"robaertschi.xyz/robaertschi/tt/tast"
+	"robaertschi.xyz/robaertschi/tt/token"
 	"robaertschi.xyz/robaertschi/tt/types"
 )
 
+// Builtin functions, they are used if there is no function or variable with the same name
+var builtins = map[string]bool{
+	"assert": true,
+	"len":    true,
+	"panic":  true,
+	"print":  true,
+}
+
+// Splits an array type "[N]T" into T and N, slices "[]T" have the length -1
+func splitArrayType(t ast.Type) (ast.Type, int64, bool) {
+	s := string(t)
+	end := strings.IndexRune(s, ']')
+	if !strings.HasPrefix(s, "[") || end < 0 {
+		return "", 0, false
+	}
+
+	element := ast.Type(s[end+1:])
+	if end == 1 {
+		return element, -1, true
+	}
+
+	length, err := strconv.ParseInt(s[1:end], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return element, length, true
+}
+
+func arrayOf(element types.Type, length int64) types.Type {
+	if length < 0 {
+		return types.NewSlice(element)
+	}
+	return types.NewArray(element, length)
+}
+
+// Returns the type of the elements of an array, slice or string
+func elementType(t types.Type) (types.Type, bool) {
+	switch t := t.(type) {
+	case *types.ArrayType:
+		return t.Element, true
+	case *types.SliceType:
+		return t.Element, true
+	case *types.StringType:
+		return types.U8, true
+	}
+	return nil, false
+}
+
+// Only variables, their fields and elements can be assigned to. The elements of a slice
+// and the values behind pointers are always assignable, because they are not part of the value.
+// Strings are read-only.
+func isAssignable(expr tast.Expression) bool {
+	switch expr := expr.(type) {
+	case *tast.VariableReference, *tast.GlobalReference, *tast.DereferenceExpression:
+		return true
+	case *tast.FieldAccessExpression:
+		return isAssignable(expr.Expression)
+	case *tast.IndexExpression:
+		switch expr.Expression.Type().(type) {
+		case *types.SliceType:
+			return true
+		case *types.StringType:
+			return false
+		}
+		return isAssignable(expr.Expression)
+	}
+	return false
+}
+
+// Checks if global variables can have the type t, their initial value has to be known at compile time
+func isGlobalType(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.ArrayType:
+		return isGlobalType(t.Element)
+	case *types.StructType:
+		for _, field := range t.Fields {
+			if !isGlobalType(field.Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return types.IsInteger(t) || t.IsSameType(types.Bool)
+}
+
+// Returns the variable that contains the value of expr, if it is stored in one
+func rootVariable(expr tast.Expression) (string, bool) {
+	switch expr := expr.(type) {
+	case *tast.VariableReference:
+		return expr.Identifier, true
+	case *tast.FieldAccessExpression:
+		return rootVariable(expr.Expression)
+	case *tast.IndexExpression:
+		if _, ok := expr.Expression.Type().(*types.ArrayType); ok {
+			return rootVariable(expr.Expression)
+		}
+	}
+	return "", false
+}
+
+func (c *Checker) resolveType(t ast.Type) (types.Type, bool) {
+	if params, returnType, ok := ast.SplitFunctionType(t); ok {
+		parameters := []types.Type{}
+		for _, param := range params {
+			paramType, ok := c.resolveType(param)
+			if !ok {
+				return nil, false
+			}
+			parameters = append(parameters, paramType)
+		}
+		returnType, ok := c.resolveType(returnType)
+		if !ok {
+			return nil, false
+		}
+		return &types.FunctionType{ReturnType: returnType, Parameters: parameters}, true
+	}
+
+	if pointee, ok := strings.CutPrefix(string(t), "*"); ok {
+		pointeeType, ok := c.resolveType(ast.Type(pointee))
+		if !ok {
+			return nil, false
+		}
+		return types.NewPointer(pointeeType), true
+	}
+
+	if element, length, ok := splitArrayType(t); ok {
+		elementType, ok := c.resolveType(element)
+		if !ok {
+			return nil, false
+		}
+		return arrayOf(elementType, length), true
+	}
+
+	if t, ok := types.From(t); ok {
+		return t, true
+	}
+
+	if t, ok := c.typeParameters[string(t)]; ok {
+		return t, true
+	}
+	if st, ok := c.structs[string(t)]; ok {
+		return st, true
+	}
+	if et, ok := c.enums[string(t)]; ok {
+		return et, true
+	}
+	return nil, false
+}
+
+// Returns the name and the kind of a struct or enum declaration
+func typeDeclaration(decl ast.Declaration) (name string, kind string, ok bool) {
+	switch decl := decl.(type) {
+	case *ast.StructDeclaration:
+		return decl.Name, "struct", true
+	case *ast.EnumDeclaration:
+		return decl.Name, "enum", true
+	}
+	return "", "", false
+}
+
+// Resolves all struct and enum declarations before anything else, so that they can be used everywhere
+func (c *Checker) inferTypeDeclarations(program *ast.Program) error {
+	c.structs = make(map[string]*types.StructType)
+	c.enums = make(map[string]*types.EnumType)
+	declarations := make(map[string]ast.Declaration)
+	errs := []error{}
+
+	for _, decl := range program.Declarations {
+		name, kind, ok := typeDeclaration(decl)
+		if !ok {
+			continue
+		}
+
+		if _, ok := types.From(ast.Type(name)); ok {
+			errs = append(errs, c.error(decl.Tok(), "the %s %q has the name of a builtin type", kind, name))
+		} else if _, ok := declarations[name]; ok {
+			errs = append(errs, c.error(decl.Tok(), "duplicate type name %q", name))
+		} else {
+			declarations[name] = decl
+		}
+	}
+
+	// The types exist before their fields are resolved, so that pointers can refer to them
+	for name, decl := range declarations {
+		switch decl.(type) {
+		case *ast.StructDeclaration:
+			c.structs[name] = types.NewStruct(name, nil)
+		case *ast.EnumDeclaration:
+			c.enums[name] = types.NewEnum(name, nil)
+		}
+	}
+
+	resolving := make(map[string]bool)
+	for _, decl := range program.Declarations {
+		if name, _, ok := typeDeclaration(decl); ok && declarations[name] == decl {
+			_, err := c.resolveTypeDeclaration(declarations, resolving, decl)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Checker) resolveTypeDeclaration(declarations map[string]ast.Declaration, resolving map[string]bool, decl ast.Declaration) (types.Type, error) {
+	switch decl := decl.(type) {
+	case *ast.StructDeclaration:
+		return c.resolveStruct(declarations, resolving, decl)
+	case *ast.EnumDeclaration:
+		return c.resolveEnum(declarations, resolving, decl)
+	}
+	panic(fmt.Sprintf("unexpected type declaration %T", decl))
+}
+
+func (c *Checker) resolveStruct(declarations map[string]ast.Declaration, resolving map[string]bool, decl *ast.StructDeclaration) (*types.StructType, error) {
+	st := c.structs[decl.Name]
+	// A struct without fields is an error, so it is not resolved yet
+	if st.Fields != nil {
+		return st, nil
+	}
+	if resolving[decl.Name] {
+		return nil, c.error(decl.Token, "the struct %q contains itself", decl.Name)
+	}
+	resolving[decl.Name] = true
+	defer delete(resolving, decl.Name)
+
+	if len(decl.Fields) == 0 {
+		return nil, c.error(decl.Token, "the struct %q has no fields", decl.Name)
+	}
+
+	fields := []types.StructField{}
+	for i, field := range decl.Fields {
+		for _, other := range decl.Fields[:i] {
+			if other.Name == field.Name {
+				return nil, c.error(decl.Token, "duplicate field %q in struct %q", field.Name, decl.Name)
+			}
+		}
+
+		t, err := c.resolveFieldType(declarations, resolving, decl.Token, fmt.Sprintf("field %q", field.Name), field.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.IsSameType(types.Unit) {
+			return nil, c.error(decl.Token, "the field %q can not have the type %q", field.Name, t.Name())
+		}
+
+		fields = append(fields, types.StructField{Name: field.Name, Type: t})
+	}
+
+	st.SetFields(fields)
+	return st, nil
+}
+
+func (c *Checker) resolveEnum(declarations map[string]ast.Declaration, resolving map[string]bool, decl *ast.EnumDeclaration) (*types.EnumType, error) {
+	et := c.enums[decl.Name]
+	// An enum without variants is an error, so it is not resolved yet
+	if et.Variants != nil {
+		return et, nil
+	}
+	if resolving[decl.Name] {
+		return nil, c.error(decl.Token, "the enum %q contains itself", decl.Name)
+	}
+	resolving[decl.Name] = true
+	defer delete(resolving, decl.Name)
+
+	if len(decl.Variants) == 0 {
+		return nil, c.error(decl.Token, "the enum %q has no variants", decl.Name)
+	}
+
+	variants := []types.EnumVariant{}
+	for i, variant := range decl.Variants {
+		for _, other := range decl.Variants[:i] {
+			if other.Name == variant.Name {
+				return nil, c.error(decl.Token, "duplicate variant %q in enum %q", variant.Name, decl.Name)
+			}
+		}
+
+		// "_" is the wildcard of a match
+		if variant.Name == "_" {
+			return nil, c.error(decl.Token, "a variant of enum %q can not be called %q", decl.Name, variant.Name)
+		}
+
+		fields := []types.Type{}
+		for _, field := range variant.Fields {
+			t, err := c.resolveFieldType(declarations, resolving, decl.Token, fmt.Sprintf("variant %q", variant.Name), field)
+			if err != nil {
+				return nil, err
+			}
+
+			if t.IsSameType(types.Unit) {
+				return nil, c.error(decl.Token, "the variant %q can not contain the type %q", variant.Name, t.Name())
+			}
+			fields = append(fields, t)
+		}
+
+		variants = append(variants, types.EnumVariant{Name: variant.Name, Fields: fields})
+	}
+
+	et.SetVariants(variants)
+	return et, nil
+}
+
+// Resolves the type t of a field or payload, structs and enums are resolved first if they are used.
+// Pointers and functions do not need the layout of the type, so they can refer to any struct or enum.
+func (c *Checker) resolveFieldType(declarations map[string]ast.Declaration, resolving map[string]bool, tok token.Token, usage string, t ast.Type) (types.Type, error) {
+	if strings.HasPrefix(string(t), "*") || strings.HasPrefix(string(t), "fn(") {
+		pointer, ok := c.resolveType(t)
+		if !ok {
+			return nil, c.error(tok, "could not find the type %q for %s", t, usage)
+		}
+		return pointer, nil
+	}
+
+	if element, length, ok := splitArrayType(t); ok {
+		elementType, err := c.resolveFieldType(declarations, resolving, tok, usage, element)
+		if err != nil {
+			return nil, err
+		}
+		return arrayOf(elementType, length), nil
+	}
+
+	if t, ok := types.From(t); ok {
+		return t, nil
+	}
+
+	decl, ok := declarations[string(t)]
+	if !ok {
+		return nil, c.error(tok, "could not find the type %q for %s", t, usage)
+	}
+
+	return c.resolveTypeDeclaration(declarations, resolving, decl)
+}
+
 func (c *Checker) inferTypes(program *ast.Program) (*tast.Program, error) {
+	if err := c.inferTypeDeclarations(program); err != nil {
+		return nil, err
+	}
+
 	c.functionVariables = make(map[string]Variables)
+	c.constants = make(map[string]types.Type)
+	c.globals = make(map[string]types.Type)
+	c.generics = make(map[string]*ast.FunctionDeclaration)
+	c.instanceTypes = make(map[string]*types.FunctionType)
+	c.instances = nil
+	c.closureCounts = make(map[string]int)
+	c.captures = nil
 	decls := []tast.Declaration{}
 	errs := []error{}
 	vars := make(Variables)
@@ -20,33 +369,66 @@ func (c *Checker) inferTypes(program *ast.Program) (*tast.Program, error) {
 	for _, decl := range program.Declarations {
 		switch decl := decl.(type) {
 		case *ast.FunctionDeclaration:
-			parameters := []tast.Parameter{}
-			for _, param := range decl.Parameters {
-				t, ok := types.From(param.Type)
-				if !ok {
-					return nil, c.error(decl.Token, "could not find the type %q for argument %q", param.Type, param.Name)
+			// The types of a generic function are only known, when it is instantiated
+			if len(decl.TypeParameters) > 0 {
+				if decl.Name == "main" {
+					return nil, c.error(decl.Token, "the main function can not have type parameters")
 				}
-				vars[param.Name] = t
-				parameters = append(parameters, tast.Parameter{Name: param.Name, Type: t})
+				c.generics[decl.Name] = decl
+				continue
 			}
 
-			t, ok := types.From(decl.ReturnType)
-			if !ok {
-				return nil, c.error(decl.Token, "invalid type %q", decl.ReturnType)
+			parameters, t, err := c.inferSignature(decl.Token, decl.Parameters, decl.ReturnType)
+			if err != nil {
+				return nil, err
 			}
 
-			parameterTypes := []types.Type{}
+			vars[decl.Name] = t
+			funcToParams[decl.Name] = parameters
+		case *ast.ExternFunctionDeclaration:
+			parameters, t, err := c.inferSignature(decl.Token, decl.Parameters, decl.ReturnType)
+			if err != nil {
+				return nil, err
+			}
 
-			for _, param := range parameters {
-				parameterTypes = append(parameterTypes, param.Type)
+			// Multiple modules can declare the same extern function, but all of them need the same types
+			if previous, ok := vars[decl.Name]; ok && previous.Name() != t.Name() {
+				return nil, c.error(decl.Token, "the extern function %q has the type %q, but it is declared with the type %q in another module", decl.Name, t.Name(), previous.Name())
 			}
 
-			vars[decl.Name] = &types.FunctionType{ReturnType: t, Parameters: parameterTypes}
+			vars[decl.Name] = t
 			funcToParams[decl.Name] = parameters
+		case *ast.ConstDeclaration:
+			t, ok := c.resolveType(decl.Type)
+			if !ok {
+				return nil, c.error(decl.Token, "could not find the type %q for the constant %q", decl.Type, decl.Name)
+			}
+			if !types.IsInteger(t) && !t.IsSameType(types.Bool) {
+				return nil, c.error(decl.Token, "the constant %q has to be an integer or a bool, but has the type %q", decl.Name, t.Name())
+			}
+
+			vars[decl.Name] = t
+			c.constants[decl.Name] = t
+		case *ast.GlobalDeclaration:
+			t, ok := c.resolveType(decl.Type)
+			if !ok {
+				return nil, c.error(decl.Token, "could not find the type %q for the global variable %q", decl.Type, decl.Name)
+			}
+			if !isGlobalType(t) {
+				return nil, c.error(decl.Token, "the global variable %q has the type %q, but only integers, bools and arrays and structs of them are supported", decl.Name, t.Name())
+			}
+
+			vars[decl.Name] = t
+			c.globals[decl.Name] = t
 		}
 	}
 
+	c.declarations = vars
 	for _, decl := range program.Declarations {
+		// Generic functions are inferred at their calls
+		if decl, ok := decl.(*ast.FunctionDeclaration); ok && len(decl.TypeParameters) > 0 {
+			continue
+		}
 		decl, err := c.inferDeclaration(funcToParams, copyVars(vars), decl)
 		if err == nil {
 			decls = append(decls, decl)
@@ -55,37 +437,389 @@ func (c *Checker) inferTypes(program *ast.Program) (*tast.Program, error) {
 		}
 	}
 
+	// The instances of the generic functions are added after the other declarations
+	decls = append(decls, c.instances...)
 	return &tast.Program{Declarations: decls}, errors.Join(errs...)
 }
 
+// Resolves the types of the parameters and the return type of a function
+func (c *Checker) inferSignature(tok token.Token, params []ast.Parameter, returnType ast.Type) ([]tast.Parameter, *types.FunctionType, error) {
+	parameters := []tast.Parameter{}
+	for _, param := range params {
+		t, ok := c.resolveType(param.Type)
+		if !ok {
+			return nil, nil, c.error(tok, "could not find the type %q for argument %q", param.Type, param.Name)
+		}
+		parameters = append(parameters, tast.Parameter{Name: param.Name, Type: t})
+	}
+
+	t, ok := c.resolveType(returnType)
+	if !ok {
+		return nil, nil, c.error(tok, "invalid type %q", returnType)
+	}
+
+	parameterTypes := []types.Type{}
+
+	for _, param := range parameters {
+		parameterTypes = append(parameterTypes, param.Type)
+	}
+
+	return parameters, &types.FunctionType{ReturnType: t, Parameters: parameterTypes}, nil
+}
+
 func (c *Checker) inferDeclaration(funcToParams map[string][]tast.Parameter, vars Variables, decl ast.Declaration) (tast.Declaration, error) {
 	switch decl := decl.(type) {
+	case *ast.ExternFunctionDeclaration:
+		return &tast.ExternFunctionDeclaration{Token: decl.Token, Name: decl.Name, Parameters: funcToParams[decl.Name], ReturnType: vars[decl.Name].(*types.FunctionType).ReturnType}, nil
 	case *ast.FunctionDeclaration:
-		body, err := c.inferExpression(vars, decl.Body)
+		return c.inferFunction(vars, decl, decl.Name, funcToParams[decl.Name])
+	case *ast.ConstDeclaration:
+		c.addressTaken = make(map[string]bool)
+		c.returnType = nil
+		c.function = decl.Name
+		value, err := c.inferExpression(vars, decl.Value, c.constants[decl.Name])
+		c.functionVariables[decl.Name] = vars
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &tast.ConstDeclaration{Token: decl.Token, Name: decl.Name, ConstType: c.constants[decl.Name], Value: value}, nil
+	case *ast.GlobalDeclaration:
+		c.addressTaken = make(map[string]bool)
+		c.returnType = nil
+		c.function = decl.Name
+		value, err := c.inferExpression(vars, decl.Value, c.globals[decl.Name])
 		c.functionVariables[decl.Name] = vars
 
 		if err != nil {
 			return nil, err
 		}
 
-		return &tast.FunctionDeclaration{Token: decl.Token, Parameters: funcToParams[decl.Name], Body: body, ReturnType: vars[decl.Name], Name: decl.Name}, nil
+		return &tast.GlobalDeclaration{Token: decl.Token, Name: decl.Name, GlobalType: c.globals[decl.Name], Value: value}, nil
+	case *ast.StructDeclaration:
+		return &tast.StructDeclaration{Token: decl.Token, StructType: c.structs[decl.Name]}, nil
+	case *ast.EnumDeclaration:
+		return &tast.EnumDeclaration{Token: decl.Token, EnumType: c.enums[decl.Name]}, nil
 	}
 	return nil, errors.New("unhandled declaration in type inferer")
 }
 
-func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Expression, error) {
+// Infers the body of the function decl, which has the symbol name. The type of the function is vars[name].
+func (c *Checker) inferFunction(vars Variables, decl *ast.FunctionDeclaration, name string, parameters []tast.Parameter) (tast.Declaration, error) {
+	for _, param := range parameters {
+		vars[param.Name] = param.Type
+	}
+
+	c.addressTaken = make(map[string]bool)
+	returnType := vars[name].(*types.FunctionType).ReturnType
+	c.returnType = returnType
+	c.function = name
+	body, err := c.inferExpression(vars, decl.Body, returnType)
+	c.functionVariables[name] = vars
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tast.FunctionDeclaration{Token: decl.Token, Parameters: parameters, Body: body, ReturnType: returnType, Name: name, AddressTaken: c.addressTaken}, nil
+}
+
+// Infers the type of expr, expected is the type the surrounding expression expects or nil if it expects none.
+// Integer literals without a suffix get the expected type, the checker reports the other types, which do not match.
+func (c *Checker) inferExpression(vars Variables, expr ast.Expression, expected types.Type) (tast.Expression, error) {
 	switch expr := expr.(type) {
 	case *ast.IntegerExpression:
-		return &tast.IntegerExpression{Token: expr.Token, Value: expr.Value}, nil
+		return c.inferInteger(expr, expected, false)
+	case *ast.FloatExpression:
+		return &tast.FloatExpression{Token: expr.Token, Value: expr.Value}, nil
 	case *ast.BooleanExpression:
 		return &tast.BooleanExpression{Token: expr.Token, Value: expr.Value}, nil
+	case *ast.StringExpression:
+		return &tast.StringExpression{Token: expr.Token, Value: expr.Value}, nil
 	case *ast.ErrorExpression:
 		return nil, c.error(expr.InvalidToken, "invalid expression")
+	case *ast.UnaryExpression:
+		var operand tast.Expression
+		var err error
+		if integer, ok := expr.Operand.(*ast.IntegerExpression); ok && expr.Operator == ast.Negate {
+			operand, err = c.inferInteger(integer, expected, true)
+		} else {
+			operand, err = c.inferExpression(vars, expr.Operand, expected)
+		}
+		if err != nil {
+			return &tast.UnaryExpression{}, err
+		}
+
+		return &tast.UnaryExpression{Operand: operand, Operator: expr.Operator, Token: expr.Token, ResultType: operand.Type()}, nil
+	case *ast.CastExpression:
+		inner, err := c.inferExpression(vars, expr.Expression, nil)
+		if err != nil {
+			return &tast.CastExpression{}, err
+		}
+
+		t, ok := c.resolveType(expr.Type)
+		if !ok {
+			return &tast.CastExpression{}, c.error(expr.Token, "could not find the type %q", expr.Type)
+		}
+
+		return &tast.CastExpression{Token: expr.Token, Expression: inner, TargetType: t}, nil
+	case *ast.StructExpression:
+		st, ok := c.structs[expr.Name]
+		if !ok {
+			return &tast.StructExpression{}, c.error(expr.Token, "could not find the struct %q", expr.Name)
+		}
+
+		fields := []tast.StructExpressionField{}
+		errs := []error{}
+		for _, field := range expr.Fields {
+			var fieldType types.Type
+			if f, ok := st.Field(field.Name); ok {
+				fieldType = f.Type
+			}
+			value, err := c.inferExpression(vars, field.Value, fieldType)
+			errs = append(errs, err)
+			fields = append(fields, tast.StructExpressionField{Token: field.Token, Name: field.Name, Value: value})
+		}
+
+		return &tast.StructExpression{Token: expr.Token, StructType: st, Fields: fields}, errors.Join(errs...)
+	case *ast.FieldAccessExpression:
+		inner, err := c.inferExpression(vars, expr.Expression, nil)
+		if err != nil {
+			return &tast.FieldAccessExpression{}, err
+		}
+
+		// Fields can be accessed through a pointer to a struct
+		if pointer, ok := inner.Type().(*types.PointerType); ok {
+			if _, ok := pointer.Pointee.(*types.StructType); ok {
+				inner = &tast.DereferenceExpression{Token: expr.Token, Expression: inner, ResultType: pointer.Pointee}
+			}
+		}
+
+		st, ok := inner.Type().(*types.StructType)
+		if !ok {
+			return &tast.FieldAccessExpression{}, c.error(expr.Token, "the type %q has no fields", inner.Type().Name())
+		}
+
+		field, ok := st.Field(expr.Field)
+		if !ok {
+			return &tast.FieldAccessExpression{}, c.error(expr.Token, "the struct %q has no field %q", st.Name(), expr.Field)
+		}
+
+		return &tast.FieldAccessExpression{Token: expr.Token, Expression: inner, Field: expr.Field, FieldType: field.Type}, nil
+	case *ast.EnumExpression:
+		et, ok := c.enums[expr.Enum]
+		if !ok {
+			return &tast.EnumExpression{}, c.error(expr.Token, "could not find the enum %q", expr.Enum)
+		}
+		variant, ok := et.Variant(expr.Variant)
+		if !ok {
+			return &tast.EnumExpression{}, c.error(expr.Token, "the enum %q has no variant %q", expr.Enum, expr.Variant)
+		}
+
+		args := []tast.Expression{}
+		errs := []error{}
+		for i, arg := range expr.Arguments {
+			var fieldType types.Type
+			if i < len(variant.Fields) {
+				fieldType = variant.Fields[i]
+			}
+			value, err := c.inferExpression(vars, arg, fieldType)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			args = append(args, value)
+		}
+
+		return &tast.EnumExpression{Token: expr.Token, EnumType: et, Variant: expr.Variant, Arguments: args}, errors.Join(errs...)
+	case *ast.MatchExpression:
+		inner, err := c.inferExpression(vars, expr.Expression, nil)
+		if err != nil {
+			return &tast.MatchExpression{}, err
+		}
+
+		et, ok := inner.Type().(*types.EnumType)
+		if !ok {
+			return &tast.MatchExpression{}, c.error(expr.Token, "the type %q can not be matched, only enums can", inner.Type().Name())
+		}
+
+		errs := []error{}
+		valid := make([]bool, len(expr.Arms))
+		for i, arm := range expr.Arms {
+			if arm.Variant == "_" {
+				if len(arm.Bindings) > 0 {
+					errs = append(errs, c.error(arm.Token, "the wildcard %q has no values to bind", arm.Variant))
+					continue
+				}
+			} else {
+				variant, ok := et.Variant(arm.Variant)
+				if !ok {
+					errs = append(errs, c.error(arm.Token, "the enum %q has no variant %q", et.Name(), arm.Variant))
+					continue
+				}
+				if len(arm.Bindings) != len(variant.Fields) {
+					errs = append(errs, c.error(arm.Token, "the variant %q has %d values, but %d are bound", arm.Variant, len(variant.Fields), len(arm.Bindings)))
+					continue
+				}
+
+				for i, binding := range arm.Bindings {
+					if binding != "_" {
+						vars[binding] = variant.Fields[i]
+					}
+				}
+			}
+			valid[i] = true
+		}
+
+		// Without an expected type, the first arm with a type decides the type of the untyped arms
+		bodies := make([]tast.Expression, len(expr.Arms))
+		for _, untyped := range []bool{false, true} {
+			for i, arm := range expr.Arms {
+				if !valid[i] || isUntypedInteger(arm.Body) != untyped {
+					continue
+				}
+				body, err := c.inferExpression(vars, arm.Body, expected)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				bodies[i] = body
+				if expected == nil && !body.Type().IsSameType(types.Never) {
+					expected = body.Type()
+				}
+			}
+		}
+
+		arms := []tast.MatchArm{}
+		for i, arm := range expr.Arms {
+			if bodies[i] != nil {
+				arms = append(arms, tast.MatchArm{Token: arm.Token, Variant: arm.Variant, Bindings: arm.Bindings, Body: bodies[i]})
+			}
+		}
+
+		// The type of the first arm, which produces a value, is the type of the match, the checker makes sure the other arms have the same type
+		var returnType types.Type = types.Unit
+		if len(arms) > 0 {
+			returnType = types.Never
+			for _, arm := range arms {
+				if !arm.Body.Type().IsSameType(types.Never) {
+					returnType = arm.Body.Type()
+					break
+				}
+			}
+		}
+
+		return &tast.MatchExpression{Token: expr.Token, Expression: inner, Arms: arms, ReturnType: returnType}, errors.Join(errs...)
+	case *ast.AddressOfExpression:
+		inner, err := c.inferExpression(vars, expr.Expression, nil)
+		if err != nil {
+			return &tast.AddressOfExpression{}, err
+		}
+
+		if _, ok := rootVariable(inner); ok && expr.Immutable {
+			variable, _ := assignedVariable(expr.Expression)
+			decl := expr.Declaration.Loc
+			return &tast.AddressOfExpression{}, c.error(
+				expr.Token,
+				"the address of the variable %q can not be taken, it is declared without mut at %s:%d:%d",
+				variable.Token.Literal, decl.File, decl.Line, decl.Col,
+			)
+		}
+		if v, ok := rootVariable(inner); ok {
+			c.addressTaken[v] = true
+		}
+		return &tast.AddressOfExpression{Token: expr.Token, Expression: inner, PointerType: types.NewPointer(inner.Type())}, nil
+	case *ast.DereferenceExpression:
+		inner, err := c.inferExpression(vars, expr.Expression, nil)
+		if err != nil {
+			return &tast.DereferenceExpression{}, err
+		}
+
+		pointer, ok := inner.Type().(*types.PointerType)
+		if !ok {
+			return &tast.DereferenceExpression{}, c.error(expr.Token, "the type %q can not be dereferenced, only pointers can", inner.Type().Name())
+		}
+		return &tast.DereferenceExpression{Token: expr.Token, Expression: inner, ResultType: pointer.Pointee}, nil
+	case *ast.ArrayExpression:
+		var elementType types.Type
+		if array, ok := expected.(*types.ArrayType); ok {
+			elementType = array.Element
+		}
+
+		// Without an expected array type, the first element with a type decides the type of the untyped elements
+		elements := make([]tast.Expression, len(expr.Elements))
+		errs := []error{}
+		for _, untyped := range []bool{false, true} {
+			for i, element := range expr.Elements {
+				if isUntypedInteger(element) != untyped {
+					continue
+				}
+				inferred, err := c.inferExpression(vars, element, elementType)
+				errs = append(errs, err)
+				elements[i] = inferred
+				if err == nil && elementType == nil {
+					elementType = inferred.Type()
+				}
+			}
+		}
+
+		if err := errors.Join(errs...); err != nil {
+			return &tast.ArrayExpression{}, err
+		}
+
+		return &tast.ArrayExpression{Token: expr.Token, Elements: elements, ArrayType: types.NewArray(elements[0].Type(), int64(len(elements)))}, nil
+	case *ast.IndexExpression:
+		inner, innerErr := c.inferExpression(vars, expr.Expression, nil)
+		index, indexErr := c.inferExpression(vars, expr.Index, nil)
+		if err := errors.Join(innerErr, indexErr); err != nil {
+			return &tast.IndexExpression{}, err
+		}
+
+		element, ok := elementType(inner.Type())
+		if !ok {
+			return &tast.IndexExpression{}, c.error(expr.Token, "the type %q can not be indexed", inner.Type().Name())
+		}
+
+		return &tast.IndexExpression{Token: expr.Token, Expression: inner, Index: index, ElementType: element}, nil
+	case *ast.SliceExpression:
+		inner, err := c.inferExpression(vars, expr.Expression, nil)
+		if err != nil {
+			return &tast.SliceExpression{}, err
+		}
+
+		slice := &tast.SliceExpression{Token: expr.Token, Expression: inner}
+		errs := []error{}
+		if expr.Start != nil {
+			slice.Start, err = c.inferExpression(vars, expr.Start, nil)
+			errs = append(errs, err)
+		}
+		if expr.End != nil {
+			slice.End, err = c.inferExpression(vars, expr.End, nil)
+			errs = append(errs, err)
+		}
+
+		element, ok := elementType(inner.Type())
+		if !ok {
+			errs = append(errs, c.error(expr.Token, "the type %q can not be sliced", inner.Type().Name()))
+		} else if types.Str.IsSameType(inner.Type()) {
+			slice.SliceType = types.Str
+			slice.ElementType = element
+		} else {
+			slice.SliceType = types.NewSlice(element)
+			slice.ElementType = element
+		}
+
+		return slice, errors.Join(errs...)
 	case *ast.BinaryExpression:
-		lhs, lhsErr := c.inferExpression(vars, expr.Lhs)
-		rhs, rhsErr := c.inferExpression(vars, expr.Rhs)
+		// The operands of a comparison do not have the type of the result
+		operandType := expected
+		if expr.Operator.IsBooleanOperator() {
+			operandType = nil
+		}
+		lhs, rhs, err := c.inferOperands(vars, expr.Lhs, expr.Rhs, operandType)
 		var resultType types.Type
-		if lhsErr == nil && rhsErr == nil {
+		if err == nil {
 			if expr.Operator.IsBooleanOperator() {
 				resultType = types.Bool
 			} else {
@@ -93,13 +827,13 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 			}
 		}
 
-		return &tast.BinaryExpression{Lhs: lhs, Rhs: rhs, Operator: expr.Operator, Token: expr.Token, ResultType: resultType}, errors.Join(lhsErr, rhsErr)
+		return &tast.BinaryExpression{Lhs: lhs, Rhs: rhs, Operator: expr.Operator, Token: expr.Token, ResultType: resultType}, err
 	case *ast.BlockExpression:
 		expressions := []tast.Expression{}
 		errs := []error{}
 
 		for _, expr := range expr.Expressions {
-			newExpr, err := c.inferExpression(vars, expr)
+			newExpr, err := c.inferExpression(vars, expr, nil)
 			if err != nil {
 				errs = append(errs, err)
 			} else {
@@ -110,7 +844,7 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 		var returnExpr tast.Expression
 		var returnType types.Type
 		if expr.ReturnExpression != nil {
-			expr, err := c.inferExpression(vars, expr.ReturnExpression)
+			expr, err := c.inferExpression(vars, expr.ReturnExpression, expected)
 			returnExpr = expr
 			if err != nil {
 				errs = append(errs, err)
@@ -119,6 +853,13 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 			}
 		} else {
 			returnType = types.Unit
+			// The end of the block is never reached, if one of the expressions never produces a value
+			for _, expr := range expressions {
+				if expr.Type().IsSameType(types.Never) {
+					returnType = types.Never
+					break
+				}
+			}
 		}
 
 		return &tast.BlockExpression{
@@ -129,28 +870,116 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 		}, errors.Join(errs...)
 
 	case *ast.IfExpression:
-		cond, condErr := c.inferExpression(vars, expr.Condition)
-		then, thenErr := c.inferExpression(vars, expr.Then)
-
+		cond, condErr := c.inferExpression(vars, expr.Condition, types.Bool)
 		if expr.Else != nil {
-			elseExpr, elseErr := c.inferExpression(vars, expr.Else)
+			// Both branches need the same type, so an untyped integer branch gets the type of the other one
+			then, elseExpr, branchErr := c.inferOperands(vars, expr.Then, expr.Else, expected)
 
-			return &tast.IfExpression{Token: expr.Token, Condition: cond, Then: then, Else: elseExpr, ReturnType: then.Type()}, errors.Join(condErr, thenErr, elseErr)
+			returnType := then.Type()
+			if branchErr == nil {
+				if t, ok := types.Unify(then.Type(), elseExpr.Type()); ok {
+					returnType = t
+				}
+			}
+
+			return &tast.IfExpression{Token: expr.Token, Condition: cond, Then: then, Else: elseExpr, ReturnType: returnType}, errors.Join(condErr, branchErr)
 		}
+
+		then, thenErr := c.inferExpression(vars, expr.Then, expected)
 		return &tast.IfExpression{Token: expr.Token, Condition: cond, Then: then, Else: nil, ReturnType: types.Unit}, errors.Join(condErr, thenErr)
 	case *ast.AssignmentExpression:
-		varRef, ok := expr.Lhs.(*ast.VariableReference)
-		if !ok {
-			return &tast.AssignmentExpression{}, c.error(expr.Token, "not a valid assignment target")
+		// The lhs is inferred first, so that the rhs can have its type
+		lhs, err := c.inferExpression(vars, expr.Lhs, nil)
+		if err != nil {
+			return &tast.AssignmentExpression{}, err
 		}
 
-		rhs, err := c.inferExpression(vars, expr.Rhs)
+		rhs, err := c.inferExpression(vars, expr.Rhs, lhs.Type())
 		if err != nil {
 			return &tast.AssignmentExpression{}, err
 		}
 
-		varRefT, err := c.inferExpression(vars, varRef)
-		return &tast.AssignmentExpression{Lhs: varRefT, Rhs: rhs, Token: expr.Token}, err
+		if !isAssignable(lhs) {
+			return &tast.AssignmentExpression{}, c.error(expr.Token, "not a valid assignment target")
+		}
+		// The closure only has a copy of the captured variable, assigning it would not change the variable
+		if v, ok := rootVariable(lhs); ok && c.captures[v] {
+			return &tast.AssignmentExpression{}, c.error(expr.Token, "the captured variable %q can not be assigned to", v)
+		}
+		if _, ok := rootVariable(lhs); ok && expr.Immutable {
+			variable, _ := assignedVariable(expr.Lhs)
+			decl := expr.Declaration.Loc
+			return &tast.AssignmentExpression{}, c.error(
+				expr.Token,
+				"the variable %q is not mutable, it is declared without mut at %s:%d:%d",
+				variable.Token.Literal, decl.File, decl.Line, decl.Col,
+			)
+		}
+		return &tast.AssignmentExpression{Lhs: lhs, Rhs: rhs, Token: expr.Token, Compound: expr.Compound, Operator: expr.Operator}, nil
+	case *ast.WhileExpression:
+		cond, condErr := c.inferExpression(vars, expr.Condition, types.Bool)
+
+		var elseExpr tast.Expression
+		var elseErr error
+		var loopType types.Type = types.Unit
+		if expr.Else != nil {
+			elseExpr, elseErr = c.inferExpression(vars, expr.Else, expected)
+			if elseErr == nil {
+				loopType = elseExpr.Type()
+			}
+		}
+
+		c.loopTypes = append(c.loopTypes, loopType)
+		body, bodyErr := c.inferExpression(vars, expr.Body, nil)
+		c.loopTypes = c.loopTypes[:len(c.loopTypes)-1]
+
+		return &tast.WhileExpression{Token: expr.Token, Condition: cond, Body: body, Else: elseExpr, ReturnType: loopType}, errors.Join(condErr, bodyErr, elseErr)
+	case *ast.ForExpression:
+		start, end, err := c.inferOperands(vars, expr.Start, expr.End, nil)
+		if err != nil {
+			return &tast.ForExpression{}, err
+		}
+
+		vars[expr.Identifier] = start.Type()
+
+		c.loopTypes = append(c.loopTypes, types.Unit)
+		body, bodyErr := c.inferExpression(vars, expr.Body, nil)
+		c.loopTypes = c.loopTypes[:len(c.loopTypes)-1]
+
+		return &tast.ForExpression{
+			Token:        expr.Token,
+			Identifier:   expr.Identifier,
+			VariableType: start.Type(),
+			Start:        start,
+			End:          end,
+			Inclusive:    expr.Inclusive,
+			Body:         body,
+		}, bodyErr
+	case *ast.BreakExpression:
+		be := &tast.BreakExpression{Token: expr.Token, LoopType: c.loopTypes[len(c.loopTypes)-1]}
+
+		if expr.Value != nil {
+			value, err := c.inferExpression(vars, expr.Value, be.LoopType)
+			be.Value = value
+			return be, err
+		}
+
+		return be, nil
+	case *ast.ContinueExpression:
+		return &tast.ContinueExpression{Token: expr.Token}, nil
+	case *ast.ReturnExpression:
+		if c.returnType == nil {
+			return &tast.ReturnExpression{}, c.error(expr.Token, "return outside of a function")
+		}
+		re := &tast.ReturnExpression{Token: expr.Token, FunctionReturnType: c.returnType}
+
+		if expr.Value != nil {
+			value, err := c.inferExpression(vars, expr.Value, c.returnType)
+			re.Value = value
+			return re, err
+		}
+
+		return re, nil
 	case *ast.VariableDeclaration:
 		vd := &tast.VariableDeclaration{}
 		var t types.Type
@@ -158,18 +987,18 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 
 		if expr.Type != "" {
 			var ok bool
-			t, ok = types.From(expr.Type)
+			t, ok = c.resolveType(expr.Type)
 			if !ok {
 				return vd, c.error(expr.Token, "could not find the type %q", expr.Type)
 			}
 			var err error
-			initializingExpr, err = c.inferExpression(vars, expr.InitializingExpression)
+			initializingExpr, err = c.inferExpression(vars, expr.InitializingExpression, t)
 			if err != nil {
 				return vd, err
 			}
 		} else {
 			var err error
-			initializingExpr, err = c.inferExpression(vars, expr.InitializingExpression)
+			initializingExpr, err = c.inferExpression(vars, expr.InitializingExpression, nil)
 			if err != nil {
 				return vd, err
 			}
@@ -185,6 +1014,21 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 		vd.Identifier = expr.Identifier
 		return vd, nil
 	case *ast.VariableReference:
+		// Variables are renamed by the variable resolution, so only constants and globals keep their name
+		if t, ok := c.constants[expr.Identifier]; ok {
+			return &tast.ConstReference{Token: expr.Token, Identifier: expr.Identifier, ConstType: t}, nil
+		}
+		if t, ok := c.globals[expr.Identifier]; ok {
+			return &tast.GlobalReference{Token: expr.Token, Identifier: expr.Identifier, GlobalType: t}, nil
+		}
+		if _, ok := c.generics[expr.Identifier]; ok {
+			return &tast.FunctionReference{}, c.error(expr.Token, "the generic function %q can only be called", expr.Identifier)
+		}
+		// Local variables are renamed, so only the functions keep their name
+		if t, ok := c.declarations[expr.Identifier].(*types.FunctionType); ok {
+			return &tast.FunctionReference{Token: expr.Token, Identifier: expr.Identifier, FunctionType: t}, nil
+		}
+
 		vr := &tast.VariableReference{Identifier: expr.Identifier, Token: expr.Token}
 
 		t, ok := vars[expr.Identifier]
@@ -196,6 +1040,13 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 
 		return vr, nil
 	case *ast.FunctionCall:
+		if generic, ok := c.generics[expr.Identifier]; ok {
+			return c.inferGenericCall(vars, expr, generic)
+		}
+		if _, ok := vars[expr.Identifier]; !ok && builtins[expr.Identifier] {
+			return c.inferBuiltin(vars, expr)
+		}
+
 		fc := &tast.FunctionCall{Identifier: expr.Identifier, Token: expr.Token}
 
 		t, ok := vars[expr.Identifier]
@@ -208,26 +1059,401 @@ func (c *Checker) inferExpression(vars Variables, expr ast.Expression) (tast.Exp
 			return fc, c.error(expr.Token, "tried to call non function variable %q with type %q", expr.Identifier, t.Name())
 		}
 
+		args, err := c.inferArguments(vars, expr.Arguments, funcType.Parameters)
+
+		// A local variable holds a function value, which is called indirectly
+		if _, ok := c.declarations[expr.Identifier]; !ok {
+			function := &tast.VariableReference{Token: expr.Token, Identifier: expr.Identifier, VariableType: funcType}
+			return &tast.IndirectCall{Token: expr.Token, Function: function, Arguments: args, ReturnType: funcType.ReturnType}, err
+		}
+
 		fc.ReturnType = funcType.ReturnType
+		fc.Arguments = args
 
-		args := []tast.Expression{}
-		errs := []error{}
+		return fc, err
+	case *ast.CallExpression:
+		function, err := c.inferExpression(vars, expr.Function, nil)
+		if err != nil {
+			return &tast.IndirectCall{}, err
+		}
 
-		for _, arg := range expr.Arguments {
-			inferredArg, err := c.inferExpression(vars, arg)
-			errs = append(errs, err)
+		funcType, ok := function.Type().(*types.FunctionType)
+		if !ok {
+			return &tast.IndirectCall{}, c.error(expr.Token, "the type %q can not be called, only functions can", function.Type().Name())
+		}
+
+		args, err := c.inferArguments(vars, expr.Arguments, funcType.Parameters)
+		return &tast.IndirectCall{Token: expr.Token, Function: function, Arguments: args, ReturnType: funcType.ReturnType}, err
+	case *ast.ClosureExpression:
+		return c.inferClosure(vars, expr)
 
+	default:
+		panic(fmt.Sprintf("unexpected ast.Expression: %#v", expr))
+	}
+}
+
+// Infers the arguments of a call with the types of the parameters, the arguments with errors are left out
+func (c *Checker) inferArguments(vars Variables, arguments []ast.Expression, parameters []types.Type) ([]tast.Expression, error) {
+	args := []tast.Expression{}
+	errs := []error{}
+
+	for i, arg := range arguments {
+		var expected types.Type
+		if i < len(parameters) {
+			expected = parameters[i]
+		}
+		inferredArg, err := c.inferExpression(vars, arg, expected)
+		errs = append(errs, err)
+
+		if err == nil {
+			args = append(args, inferredArg)
+		}
+	}
+
+	return args, errors.Join(errs...)
+}
+
+// Infers an integer literal, a literal without a suffix has the expected type if it is an integer, otherwise i64.
+// A negated literal can also be the smallest value of a signed type.
+func (c *Checker) inferInteger(expr *ast.IntegerExpression, expected types.Type, negated bool) (tast.Expression, error) {
+	// The parser already checked, that the literals with a suffix fit into their type
+	if expr.Type != "" {
+		t, _ := types.From(expr.Type)
+		return &tast.IntegerExpression{Token: expr.Token, Value: expr.Value, ValueType: t}, nil
+	}
+
+	var t types.Type = types.I64
+	if expected != nil && types.IsInteger(expected) {
+		t = expected
+	}
+
+	limit := uint64(math.MaxUint64) >> (64 - 8*t.Size())
+	if types.IsSigned(t) {
+		limit >>= 1
+		if negated {
+			limit += 1
+		}
+	}
+	// The parser keeps the bits of the values above the maximum of i64
+	if uint64(expr.Value) > limit {
+		literal := expr.Token.Literal
+		if negated {
+			literal = "-" + literal
+		}
+		return &tast.IntegerExpression{}, c.caretError(expr.Token, "the integer literal %s does not fit into %s", literal, t.Name())
+	}
+	return &tast.IntegerExpression{Token: expr.Token, Value: expr.Value, ValueType: t}, nil
+}
+
+// Reports if expr is an integer literal without a suffix, an arithmetic operation of them or a block, if or match,
+// which only results in them. Its type comes from the context.
+func isUntypedInteger(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntegerExpression:
+		return expr.Type == ""
+	case *ast.UnaryExpression:
+		return expr.Operator != ast.Not && isUntypedInteger(expr.Operand)
+	case *ast.BinaryExpression:
+		return !expr.Operator.IsBooleanOperator() && isUntypedInteger(expr.Lhs) && isUntypedInteger(expr.Rhs)
+	case *ast.BlockExpression:
+		return expr.ReturnExpression != nil && isUntypedInteger(expr.ReturnExpression)
+	case *ast.IfExpression:
+		return expr.Else != nil && isUntypedInteger(expr.Then) && isUntypedInteger(expr.Else)
+	case *ast.MatchExpression:
+		return len(expr.Arms) > 0 && !slices.ContainsFunc(expr.Arms, func(arm ast.MatchArm) bool { return !isUntypedInteger(arm.Body) })
+	}
+	return false
+}
+
+// Infers two operands, which need the same type. An untyped integer operand gets the type of the other operand,
+// otherwise the rhs gets the type of the lhs. An operand of type ! does not decide the type of the other one.
+func (c *Checker) inferOperands(vars Variables, lhs, rhs ast.Expression, expected types.Type) (tast.Expression, tast.Expression, error) {
+	if isUntypedInteger(lhs) && !isUntypedInteger(rhs) {
+		right, rhsErr := c.inferExpression(vars, rhs, expected)
+		if rhsErr == nil && !right.Type().IsSameType(types.Never) {
+			expected = right.Type()
+		}
+		left, lhsErr := c.inferExpression(vars, lhs, expected)
+		return left, right, errors.Join(lhsErr, rhsErr)
+	}
+
+	left, lhsErr := c.inferExpression(vars, lhs, expected)
+	if lhsErr == nil && !left.Type().IsSameType(types.Never) {
+		expected = left.Type()
+	}
+	right, rhsErr := c.inferExpression(vars, rhs, expected)
+	return left, right, errors.Join(lhsErr, rhsErr)
+}
+
+// Infers a closure, it is a function of its own, which is named after the function it is in.
+// The closure can use all variables of the function, the used ones are captured by the variable resolution.
+func (c *Checker) inferClosure(vars Variables, closure *ast.ClosureExpression) (tast.Expression, error) {
+	parameters, t, err := c.inferSignature(closure.Token, closure.Parameters, closure.ReturnType)
+	if err != nil {
+		return &tast.ClosureExpression{}, err
+	}
+
+	captures := []tast.Parameter{}
+	capturesSet := make(map[string]bool)
+	for _, capture := range closure.Captures {
+		captures = append(captures, tast.Parameter{Name: capture, Type: vars[capture]})
+		capturesSet[capture] = true
+	}
+
+	name := fmt.Sprintf("%s.closure.%d", c.function, c.closureCounts[c.function])
+	c.closureCounts[c.function] += 1
+
+	// The closure is inferred in the middle of another function
+	function, returnType, addressTaken, loopTypes, outerCaptures := c.function, c.returnType, c.addressTaken, c.loopTypes, c.captures
+	defer func() {
+		c.function, c.returnType, c.addressTaken, c.loopTypes, c.captures = function, returnType, addressTaken, loopTypes, outerCaptures
+	}()
+	c.function, c.returnType, c.addressTaken, c.loopTypes, c.captures = name, t.ReturnType, make(map[string]bool), nil, capturesSet
+
+	closureVars := copyVars(vars)
+	for _, param := range parameters {
+		closureVars[param.Name] = param.Type
+	}
+
+	body, err := c.inferExpression(closureVars, closure.Body, t.ReturnType)
+	c.functionVariables[name] = closureVars
+
+	return &tast.ClosureExpression{
+		Token:        closure.Token,
+		Name:         name,
+		Parameters:   parameters,
+		Captures:     captures,
+		Body:         body,
+		ClosureType:  t,
+		AddressTaken: c.addressTaken,
+	}, err
+}
+
+// Infers the call of a generic function, the type parameters are inferred from the types of the arguments.
+// The call is replaced by a call of the instance for these types.
+func (c *Checker) inferGenericCall(vars Variables, call *ast.FunctionCall, generic *ast.FunctionDeclaration) (tast.Expression, error) {
+	fc := &tast.FunctionCall{Identifier: call.Identifier, Token: call.Token}
+
+	if len(call.Arguments) != len(generic.Parameters) {
+		return fc, c.error(call.Token, "invalid amount of arguments for function %q, expected %d but got %d", call.Identifier, len(generic.Parameters), len(call.Arguments))
+	}
+
+	// The arguments with a type bind the type parameters first, so that the untyped integers can get their types
+	args := make([]tast.Expression, len(call.Arguments))
+	errs := []error{}
+	bindings := make(map[string]types.Type)
+	for _, untyped := range []bool{false, true} {
+		for i, arg := range call.Arguments {
+			if isUntypedInteger(arg) != untyped {
+				continue
+			}
+			param := generic.Parameters[i].Type
+			inferredArg, err := c.inferExpression(vars, arg, c.parameterType(generic.TypeParameters, bindings, param))
+			errs = append(errs, err)
+			args[i] = inferredArg
 			if err == nil {
-				args = append(args, inferredArg)
+				bindTypeParameters(generic.TypeParameters, bindings, param, inferredArg.Type())
 			}
 		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fc, err
+	}
 
-		fc.Arguments = args
+	typeArgs := []types.Type{}
+	for _, typeParam := range generic.TypeParameters {
+		t, ok := bindings[typeParam]
+		if !ok {
+			return fc, c.error(call.Token, "could not infer the type parameter %q of the function %q", typeParam, call.Identifier)
+		}
+		typeArgs = append(typeArgs, t)
+	}
 
-		return fc, errors.Join(errs...)
+	name, t, err := c.instantiate(generic, typeArgs)
+	if err != nil {
+		return fc, err
+	}
 
-	default:
-		panic(fmt.Sprintf("unexpected ast.Expression: %#v", expr))
+	vars[name] = t
+	fc.Identifier = name
+	fc.ReturnType = t.ReturnType
+	fc.Arguments = args
+	return fc, nil
+}
+
+// Returns the type of the parameter of a generic function, if it is known. A type parameter is only known,
+// if it is already bound, and other types are only known, if they do not contain type parameters.
+func (c *Checker) parameterType(typeParams []string, bindings map[string]types.Type, param ast.Type) types.Type {
+	if slices.Contains(typeParams, string(param)) {
+		return bindings[string(param)]
+	}
+	if slices.ContainsFunc(typeParams, func(typeParam string) bool { return strings.Contains(string(param), typeParam) }) {
+		return nil
+	}
+	t, _ := c.resolveType(param)
+	return t
+}
+
+// Binds the type parameters in the type param of a parameter to the parts of the type arg of its argument.
+// The first argument with a type decides the type of a type parameter, the checker reports the arguments with other types.
+func bindTypeParameters(typeParams []string, bindings map[string]types.Type, param ast.Type, arg types.Type) {
+	if slices.Contains(typeParams, string(param)) {
+		if _, ok := bindings[string(param)]; !ok && !arg.IsSameType(types.Never) {
+			bindings[string(param)] = arg
+		}
+		return
+	}
+
+	if pointee, ok := strings.CutPrefix(string(param), "*"); ok {
+		if pointer, ok := arg.(*types.PointerType); ok {
+			bindTypeParameters(typeParams, bindings, ast.Type(pointee), pointer.Pointee)
+		}
+		return
+	}
+
+	if params, returnType, ok := ast.SplitFunctionType(param); ok {
+		if function, ok := arg.(*types.FunctionType); ok && len(params) == len(function.Parameters) {
+			for i, p := range params {
+				bindTypeParameters(typeParams, bindings, p, function.Parameters[i])
+			}
+			bindTypeParameters(typeParams, bindings, returnType, function.ReturnType)
+		}
+		return
+	}
+
+	if element, length, ok := splitArrayType(param); ok {
+		switch arg := arg.(type) {
+		case *types.SliceType:
+			if length < 0 {
+				bindTypeParameters(typeParams, bindings, element, arg.Element)
+			}
+		case *types.ArrayType:
+			if length == arg.Length {
+				bindTypeParameters(typeParams, bindings, element, arg.Element)
+			}
+		}
+	}
+}
+
+// The maximum number of instances, which are inferred inside of each other, more are most likely an endless instantiation
+const maxInstantiationDepth = 100
+
+// Infers the instance of the generic function for the type arguments, every instance is only inferred once.
+// Returns the symbol and the type of the instance.
+func (c *Checker) instantiate(generic *ast.FunctionDeclaration, typeArgs []types.Type) (string, *types.FunctionType, error) {
+	name := instanceName(generic.Name, typeArgs)
+	if t, ok := c.instanceTypes[name]; ok {
+		return name, t, nil
+	}
+
+	if c.instantiationDepth >= maxInstantiationDepth {
+		return "", nil, c.error(generic.Token, "the instances of %q are nested deeper than %d, the function most likely instantiates itself endlessly", generic.Name, maxInstantiationDepth)
+	}
+
+	// The instance is inferred in the middle of another function
+	typeParameters, returnType, addressTaken, loopTypes, function, captures := c.typeParameters, c.returnType, c.addressTaken, c.loopTypes, c.function, c.captures
+	defer func() {
+		c.typeParameters, c.returnType, c.addressTaken, c.loopTypes, c.function, c.captures = typeParameters, returnType, addressTaken, loopTypes, function, captures
+		c.instantiationDepth -= 1
+	}()
+	c.instantiationDepth += 1
+	c.loopTypes = nil
+	c.captures = nil
+	c.typeParameters = make(map[string]types.Type)
+	for i, typeParam := range generic.TypeParameters {
+		c.typeParameters[typeParam] = typeArgs[i]
+	}
+
+	parameters, t, err := c.inferSignature(generic.Token, generic.Parameters, generic.ReturnType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Known before the body is inferred, so that the instance can call itself
+	c.instanceTypes[name] = t
+	vars := copyVars(c.declarations)
+	vars[name] = t
+
+	decl, err := c.inferFunction(vars, generic, name, parameters)
+	if err != nil {
+		return "", nil, err
+	}
+	c.instances = append(c.instances, decl)
+	return name, t, nil
+}
+
+// Returns the symbol of the instance of a generic function, the type arguments are appended to the name.
+// "fn max[T]" becomes "max.i64" for i64, the names of the types are encoded by mangleType.
+func instanceName(name string, typeArgs []types.Type) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, t := range typeArgs {
+		b.WriteString(".")
+		b.WriteString(mangleType(t))
+	}
+	return b.String()
+}
+
+// Encodes a type with the characters, which are allowed in symbols. Pointers are prefixed with "P",
+// slices with "S" and arrays with "A" and their length. Functions are prefixed with "F" and the number of
+// their parameters, followed by the parameters and the return type. The names of structs and enums are
+// prefixed with their length, so that they can not be confused with the other types.
+func mangleType(t types.Type) string {
+	switch t := t.(type) {
+	case *types.PointerType:
+		return "P" + mangleType(t.Pointee)
+	case *types.SliceType:
+		return "S" + mangleType(t.Element)
+	case *types.ArrayType:
+		return fmt.Sprintf("A%d_%s", t.Length, mangleType(t.Element))
+	case *types.StructType, *types.EnumType:
+		return fmt.Sprintf("%d%s", len(t.Name()), t.Name())
+	case *types.FunctionType:
+		var b strings.Builder
+		fmt.Fprintf(&b, "F%d_", len(t.Parameters))
+		for _, param := range t.Parameters {
+			b.WriteString(mangleType(param))
+		}
+		b.WriteString(mangleType(t.ReturnType))
+		return b.String()
+	}
+
+	if t.IsSameType(types.Unit) {
+		return "unit"
+	}
+	return t.Name()
+}
+
+func (c *Checker) inferBuiltin(vars Variables, call *ast.FunctionCall) (tast.Expression, error) {
+	switch call.Identifier {
+	case "len":
+		if len(call.Arguments) != 1 {
+			return &tast.LenExpression{}, c.error(call.Token, "invalid amount of arguments for function %q, expected 1 but got %d", call.Identifier, len(call.Arguments))
+		}
+
+		arg, err := c.inferExpression(vars, call.Arguments[0], nil)
+		return &tast.LenExpression{Token: call.Token, Expression: arg}, err
+	case "print":
+		if len(call.Arguments) != 1 {
+			return &tast.PrintExpression{}, c.error(call.Token, "invalid amount of arguments for function %q, expected 1 but got %d", call.Identifier, len(call.Arguments))
+		}
+
+		arg, err := c.inferExpression(vars, call.Arguments[0], nil)
+		return &tast.PrintExpression{Token: call.Token, Expression: arg}, err
+	case "panic":
+		if len(call.Arguments) != 1 {
+			return &tast.PanicExpression{}, c.error(call.Token, "invalid amount of arguments for function %q, expected 1 but got %d", call.Identifier, len(call.Arguments))
+		}
+
+		arg, err := c.inferExpression(vars, call.Arguments[0], nil)
+		return &tast.PanicExpression{Token: call.Token, Message: arg}, err
+	case "assert":
+		if len(call.Arguments) != 1 {
+			return &tast.AssertExpression{}, c.error(call.Token, "invalid amount of arguments for function %q, expected 1 but got %d", call.Identifier, len(call.Arguments))
+		}
+
+		arg, err := c.inferExpression(vars, call.Arguments[0], types.Bool)
+		return &tast.AssertExpression{Token: call.Token, Condition: arg}, err
 	}
-	return nil, fmt.Errorf("unhandled expression in type inferer")
+	panic(fmt.Sprintf("unknown builtin function %q", call.Identifier))
 }
@@ -0,0 +1,125 @@
+package typechecker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"robaertschi.xyz/robaertschi/tt/ast"
+	"robaertschi.xyz/robaertschi/tt/lexer"
+	"robaertschi.xyz/robaertschi/tt/parser"
+	"robaertschi.xyz/robaertschi/tt/token"
+)
+
+type checkerTest struct {
+	input string
+	// Empty, if the program should be accepted
+	expectedError string
+}
+
+func runCheckerTest(t *testing.T, test checkerTest) {
+	t.Helper()
+
+	_, err := New().CheckProgram(parse(t, test.input))
+	if test.expectedError == "" {
+		if err != nil {
+			t.Errorf("expected %q to be accepted, but got the error %q", test.input, err)
+		}
+		return
+	}
+
+	if err == nil {
+		t.Errorf("expected %q to fail with %q, but it was accepted", test.input, test.expectedError)
+		return
+	}
+	if !strings.Contains(err.Error(), test.expectedError) {
+		t.Errorf("expected %q to fail with %q, but got %q", test.input, test.expectedError, err)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l, err := lexer.New(input, "test.tt")
+	if err != nil {
+		t.Fatalf("lexer error: %q", err)
+	}
+	l.WithErrorCallback(func(l token.Loc, s string, a ...any) {
+		t.Errorf("Lexer error callback called: %s:%d:%d %s", l.File, l.Line, l.Col, fmt.Sprintf(s, a...))
+	})
+
+	p := parser.New(l)
+	p.WithErrorCallback(func(tok token.Token, s string, a ...any) {
+		t.Errorf("Parser error callback called: %s:%d:%d %s", tok.Loc.File, tok.Loc.Line, tok.Loc.Col, fmt.Sprintf(s, a...))
+	})
+	return p.ParseProgram()
+}
+
+func TestImmutableVariables(t *testing.T) {
+	tests := []checkerTest{
+		{
+			input:         "fn main(): i64 = { x := 1; x = 2; x };",
+			expectedError: `test.tt:1:29 the variable "x" is not mutable, it is declared without mut at test.tt:1:19`,
+		},
+		{
+			input:         "fn main(): i64 = { x := 1; p := &x; *p = 2; x };",
+			expectedError: `test.tt:1:32 the address of the variable "x" can not be taken, it is declared without mut at test.tt:1:19`,
+		},
+		{
+			input:         "struct P { x: i64 }; fn main(): i64 = { a := P { x: 1 }; p := &a.x; *p };",
+			expectedError: `the address of the variable "a" can not be taken`,
+		},
+		{
+			input:         "fn set(x: i64): i64 = { x = 2; x }; fn main(): i64 = set(1);",
+			expectedError: `test.tt:1:26 the variable "x" is not mutable, it is declared without mut at test.tt:1:7`,
+		},
+		{input: "fn main(): i64 = { mut x := 1; p := &x; *p = 2; x };"},
+		{input: "struct P { x: i64 }; fn set(p: *P): i64 = { q := &p.x; *q = 2; p.x }; fn main(): i64 = { mut a := P { x: 1 }; set(&a) };"},
+	}
+
+	for _, test := range tests {
+		runCheckerTest(t, test)
+	}
+}
+
+func TestUntypedIntegers(t *testing.T) {
+	tests := []checkerTest{
+		{input: "fn max[T](a: T, b: T): T = if a > b { a } else { b }; fn main(): i64 = { a: u8 = 1; max(a, 200) as i64 };"},
+		{input: "fn max[T](a: T, b: T): T = if a > b { a } else { b }; fn main(): i64 = { a: u8 = 1; max(200, a) as i64 };"},
+		{input: "fn get[T](a: T, b: u8): T = a; fn main(): i64 = get(1, 255);"},
+		{input: "fn main(): i64 = { a: u8 = 1; c := true; (if c { a } else { 1 }) as i64 };"},
+		{input: "fn main(): i64 = { a: u8 = 1; c := true; (if c { 1 } else { a }) as i64 };"},
+		{input: "fn f(c: bool): u8 = if c { return 1 } else { 200 }; fn main(): i64 = f(false) as i64;"},
+		{input: "enum E { A, B }; fn main(): i64 = { a: u8 = 1; (match E::A { A => 1, B => a }) as i64 };"},
+		{
+			input:         "fn max[T](a: T, b: T): T = if a > b { a } else { b }; fn main(): i64 = { a: u8 = 1; max(a, 300) as i64 };",
+			expectedError: "the integer literal 300 does not fit into u8",
+		},
+		{
+			input:         "fn main(): i64 = { a: u8 = 1; c := true; (if c { a } else { 256 }) as i64 };",
+			expectedError: "the integer literal 256 does not fit into u8",
+		},
+	}
+
+	for _, test := range tests {
+		runCheckerTest(t, test)
+	}
+}
+
+func TestIntegerLiteralRange(t *testing.T) {
+	tests := []checkerTest{
+		{
+			input:         "fn main(): i64 = 9223372036854775808;",
+			expectedError: "test.tt:1:17 the integer literal 9223372036854775808 does not fit into i64\nfn main(): i64 = 9223372036854775808;\n                 ^^^^^^^^^^^^^^^^^^^",
+		},
+		{
+			input:         "fn main(): i64 = { a: u8 = 256; a as i64 };",
+			expectedError: "test.tt:1:27 the integer literal 256 does not fit into u8\nfn main(): i64 = { a: u8 = 256; a as i64 };\n                           ^^^",
+		},
+		{input: "fn main(): i64 = { a: u8 = 255; a as i64 };"},
+	}
+
+	for _, test := range tests {
+		runCheckerTest(t, test)
+	}
+}
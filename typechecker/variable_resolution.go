@@ -3,19 +3,38 @@ package typechecker
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
 	"robaertschi.xyz/robaertschi/tt/token"
+	"robaertschi.xyz/robaertschi/tt/types"
 )
 
 type Var struct {
 	Name             string
 	FromCurrentScope bool
+	// Declarations of imported modules, which are not public, can not be used
+	Private bool
+	// Set for parameters and variables of functions, the declarations of modules are not local
+	Local bool
+	// The number of closures around the declaration of a local variable
+	Closures int
+	// Local variables can only be assigned to, if they are declared with mut
+	Mutable     bool
+	Declaration token.Token
 }
 
 type Scope struct {
 	Variables map[string]Var
 	UniqueId  int64
+	// If the scope is inside of a loop, allows break and continue
+	InLoop bool
+	// The structs and enums, they are the same in every scope of a module
+	Types map[string]Var
+	// The closures the scope is in, the innermost closure is last
+	Closures []*ast.ClosureExpression
 }
 
 func errorf(t token.Token, format string, args ...any) error {
@@ -26,10 +45,10 @@ func copyScope(s *Scope) Scope {
 	newVars := make(map[string]Var)
 
 	for k, v := range s.Variables {
-		newVars[k] = Var{Name: v.Name, FromCurrentScope: false}
+		newVars[k] = Var{Name: v.Name, FromCurrentScope: false, Private: v.Private, Local: v.Local, Closures: v.Closures, Mutable: v.Mutable, Declaration: v.Declaration}
 	}
 
-	return Scope{Variables: newVars, UniqueId: s.UniqueId}
+	return Scope{Variables: newVars, UniqueId: s.UniqueId, InLoop: s.InLoop, Types: s.Types, Closures: s.Closures}
 }
 
 func (s *Scope) Get(name string) (Var, bool) {
@@ -42,6 +61,44 @@ func (s *Scope) Set(name string, uniqName string) {
 	s.Variables[name] = Var{Name: uniqName, FromCurrentScope: true}
 }
 
+// Replaces the name of a struct or enum in t with its symbol, builtin and unknown types are kept
+func (s *Scope) resolveType(tok token.Token, t ast.Type) (ast.Type, error) {
+	prefix, name := "", string(t)
+	for {
+		if params, returnType, ok := ast.SplitFunctionType(ast.Type(name)); ok {
+			for i, param := range params {
+				resolved, err := s.resolveType(tok, param)
+				if err != nil {
+					return t, err
+				}
+				params[i] = resolved
+			}
+			resolved, err := s.resolveType(tok, returnType)
+			if err != nil {
+				return t, err
+			}
+			return ast.Type(prefix) + ast.FunctionType(params, resolved), nil
+		}
+
+		if rest, ok := strings.CutPrefix(name, "*"); ok {
+			prefix, name = prefix+"*", rest
+		} else if end := strings.Index(name, "]"); strings.HasPrefix(name, "[") && end >= 0 {
+			prefix, name = prefix+name[:end+1], name[end+1:]
+		} else {
+			break
+		}
+	}
+
+	v, ok := s.Types[name]
+	if !ok {
+		return t, nil
+	}
+	if v.Private {
+		return t, errorf(tok, "%q is not public", name)
+	}
+	return ast.Type(prefix + v.Name), nil
+}
+
 func (s *Scope) Has(name string) bool {
 	_, ok := s.Variables[name]
 	return ok
@@ -61,46 +118,285 @@ func (s *Scope) Uniq(name string) string {
 	return uniqName
 }
 
-func (s *Scope) SetUniq(name string) string {
+// Declares the local variable name with a unique name, tok is the declaration
+func (s *Scope) SetUniq(name string, tok token.Token, mutable bool) string {
 	uniq := s.Uniq(name)
-	s.Set(name, uniq)
+	s.Variables[name] = Var{Name: uniq, FromCurrentScope: true, Local: true, Closures: len(s.Closures), Mutable: mutable, Declaration: tok}
 	return uniq
 }
 
-func VarResolve(p *ast.Program) (map[string]Scope, error) {
-	functionToScope := make(map[string]Scope)
-	functions := Scope{Variables: make(map[string]Var)}
+// Returns the variable an assignment to expr changes, if expr is not a variable behind a pointer
+func assignedVariable(expr ast.Expression) (*ast.VariableReference, bool) {
+	switch expr := expr.(type) {
+	case *ast.VariableReference:
+		return expr, true
+	case *ast.FieldAccessExpression:
+		return assignedVariable(expr.Expression)
+	case *ast.IndexExpression:
+		return assignedVariable(expr.Expression)
+	}
+	return nil, false
+}
 
-	for _, d := range p.Declarations {
+// Returns the variable name. If it is a local variable outside of the closures the scope is in, they capture it.
+func (s *Scope) use(tok token.Token, name string) (Var, error) {
+	v, ok := s.Get(name)
+	if !ok {
+		return v, errorf(tok, "variable %q is not declared", name)
+	}
+	if v.Private {
+		return v, errorf(tok, "%q is not public", name)
+	}
+
+	if v.Local {
+		for _, closure := range s.Closures[v.Closures:] {
+			if !slices.Contains(closure.Captures, v.Name) {
+				closure.Captures = append(closure.Captures, v.Name)
+			}
+		}
+	}
+	return v, nil
+}
+
+// The declarations of a module
+type module struct {
+	// Maps the names of the functions, constants, global variables and types to their symbols
+	scope Scope
+	// The names of the declarations, which other modules can use
+	public map[string]bool
+}
+
+// Returns the prefix of the symbols of a module, "module1_module2_" for the module in "module1/module2.tt".
+// The main module has no prefix, its declarations keep their names.
+func symbolPrefix(path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return strings.Join(path, "_") + "_"
+}
+
+// Collects the declarations of a module, symbols contains the symbols of the previous modules to find collisions.
+// A symbol maps to true if it belongs to an extern function, these can be declared by multiple modules.
+func declareModule(m *ast.Module, symbols map[string]bool) (*module, error) {
+	prefix := symbolPrefix(m.Path)
+	result := &module{
+		scope:  Scope{Variables: make(map[string]Var), Types: make(map[string]Var)},
+		public: make(map[string]bool),
+	}
+	// The kind of every name, to report duplicates
+	kinds := make(map[string]string)
+
+	for _, d := range m.Program.Declarations {
+		var name, kind string
+		var public, extern bool
 		switch d := d.(type) {
 		case *ast.FunctionDeclaration:
-			functions.Set(d.Name, d.Name)
+			name, kind, public = d.Name, "function", d.Public
+		case *ast.ExternFunctionDeclaration:
+			name, kind, public, extern = d.Name, "function", d.Public, true
+		case *ast.ConstDeclaration:
+			name, kind, public = d.Name, "constant", d.Public
+		case *ast.GlobalDeclaration:
+			name, kind, public = d.Name, "global variable", d.Public
+		case *ast.StructDeclaration:
+			name, public = d.Name, d.Public
+		case *ast.EnumDeclaration:
+			name, public = d.Name, d.Public
 		default:
+			continue
+		}
+		result.public[name] = public
+
+		if typeName, typeKind, ok := typeDeclaration(d); ok {
+			if _, ok := types.From(ast.Type(typeName)); ok {
+				return nil, errorf(d.Tok(), "the %s %q has the name of a builtin type", typeKind, typeName)
+			}
+			result.scope.Types[name] = Var{Name: prefix + name}
+			continue
+		}
+
+		if other, ok := kinds[name]; ok {
+			if other == kind {
+				return nil, errorf(d.Tok(), "duplicate %s name %q", kind, name)
+			}
+			return nil, errorf(d.Tok(), "the %s %q has the name of a %s", kind, name, other)
+		}
+		kinds[name] = kind
+
+		// Extern functions are defined outside of the program, so they keep their names
+		symbol := prefix + name
+		if extern {
+			symbol = name
+		}
+		if otherExtern, ok := symbols[symbol]; ok && (!otherExtern || !extern) {
+			return nil, errorf(d.Tok(), "the symbol %q of the %s %q is already used by another module", symbol, kind, name)
+		}
+		symbols[symbol] = extern
+		result.scope.Set(name, symbol)
+	}
+
+	return result, nil
+}
+
+// Resolves the names of all modules and merges them into one program, the first module is the main module.
+// Every module has its own scope, the declarations of imported modules are available as "module.name".
+// Functions, constants, global variables, structs and enums are renamed to their symbols.
+func VarResolve(modules []*ast.Module) (*ast.Program, error) {
+	program := &ast.Program{}
+	declared := make(map[string]*module)
+	symbols := make(map[string]bool)
+
+	for _, m := range modules {
+		result, err := declareModule(m, symbols)
+		if err != nil {
+			return program, err
+		}
+		declared[strings.Join(m.Path, ".")] = result
+	}
+
+	for _, m := range modules {
+		own := declared[strings.Join(m.Path, ".")]
+		// Functions, constants and global variables are visible everywhere
+		functions := copyScope(&own.scope)
+		functions.Types = make(map[string]Var)
+		maps.Copy(functions.Types, own.scope.Types)
+
+		for _, d := range m.Program.Declarations {
+			d, ok := d.(*ast.ImportDeclaration)
+			if !ok {
+				continue
+			}
+
+			imported, ok := declared[strings.Join(d.Path, ".")]
+			if !ok {
+				return program, errorf(d.Token, "the module %q was not found", strings.Join(d.Path, "."))
+			}
+			for name, v := range imported.scope.Variables {
+				functions.Variables[d.Name()+"."+name] = Var{Name: v.Name, Private: !imported.public[name]}
+			}
+			for name, v := range imported.scope.Types {
+				functions.Types[d.Name()+"."+name] = Var{Name: v.Name, Private: !imported.public[name]}
+			}
+		}
+
+		if err := resolveDeclarations(&functions, m.Program); err != nil {
+			return program, err
+		}
+
+		for _, d := range m.Program.Declarations {
+			switch d.(type) {
+			case *ast.ModuleDeclaration, *ast.ImportDeclaration:
+				continue
+			}
+			program.Declarations = append(program.Declarations, d)
 		}
 	}
 
+	return program, nil
+}
+
+// Resolves the names in all declarations of a module and renames the declarations to their symbols
+func resolveDeclarations(functions *Scope, p *ast.Program) error {
+	functionToScope := make(map[string]Scope)
+
 	for _, d := range p.Declarations {
 		switch d := d.(type) {
 		case *ast.FunctionDeclaration:
 			_, ok := functionToScope[d.Name]
 			if ok {
-				return functionToScope, errorf(d.Token, "duplicate function name %q", d.Name)
+				return errorf(d.Token, "duplicate function name %q", d.Name)
 			}
 
-			s := copyScope(&functions)
+			s := copyScope(functions)
+			// The type parameters hide the structs and enums with the same name and keep their names
+			if len(d.TypeParameters) > 0 {
+				s.Types = maps.Clone(functions.Types)
+				for _, typeParam := range d.TypeParameters {
+					if _, ok := types.From(ast.Type(typeParam)); ok {
+						return errorf(d.Token, "the type parameter %q has the name of a builtin type", typeParam)
+					}
+					s.Types[typeParam] = Var{Name: typeParam}
+				}
+			}
 			for i, param := range d.Parameters {
-				uniq := s.SetUniq(param.Name)
-				d.Parameters[i].Name = uniq
+				t, err := s.resolveType(d.Token, param.Type)
+				if err != nil {
+					return err
+				}
+				uniq := s.SetUniq(param.Name, param.Token, false)
+				d.Parameters[i] = ast.Parameter{Token: param.Token, Name: uniq, Type: t}
 			}
-			err := VarResolveExpr(&s, d.Body)
+			t, err := s.resolveType(d.Token, d.ReturnType)
+			if err != nil {
+				return err
+			}
+			d.ReturnType = t
+
+			err = VarResolveExpr(&s, d.Body)
 			functionToScope[d.Name] = s
 			if err != nil {
-				return functionToScope, err
+				return err
 			}
+			d.Name = functions.Variables[d.Name].Name
+		case *ast.ExternFunctionDeclaration:
+			for i, param := range d.Parameters {
+				t, err := functions.resolveType(d.Token, param.Type)
+				if err != nil {
+					return err
+				}
+				d.Parameters[i].Type = t
+			}
+			t, err := functions.resolveType(d.Token, d.ReturnType)
+			if err != nil {
+				return err
+			}
+			d.ReturnType = t
+		case *ast.ConstDeclaration:
+			s := copyScope(functions)
+			t, err := s.resolveType(d.Token, d.Type)
+			if err != nil {
+				return err
+			}
+			d.Type = t
+			if err := VarResolveExpr(&s, d.Value); err != nil {
+				return err
+			}
+			d.Name = functions.Variables[d.Name].Name
+		case *ast.GlobalDeclaration:
+			s := copyScope(functions)
+			t, err := s.resolveType(d.Token, d.Type)
+			if err != nil {
+				return err
+			}
+			d.Type = t
+			if err := VarResolveExpr(&s, d.Value); err != nil {
+				return err
+			}
+			d.Name = functions.Variables[d.Name].Name
+		case *ast.StructDeclaration:
+			for i, field := range d.Fields {
+				t, err := functions.resolveType(d.Token, field.Type)
+				if err != nil {
+					return err
+				}
+				d.Fields[i].Type = t
+			}
+			d.Name = functions.Types[d.Name].Name
+		case *ast.EnumDeclaration:
+			for _, variant := range d.Variants {
+				for i, field := range variant.Fields {
+					t, err := functions.resolveType(d.Token, field)
+					if err != nil {
+						return err
+					}
+					variant.Fields[i] = t
+				}
+			}
+			d.Name = functions.Types[d.Name].Name
 		}
 	}
 
-	return functionToScope, nil
+	return nil
 }
 
 func VarResolveExpr(s *Scope, e ast.Expression) error {
@@ -109,6 +405,13 @@ func VarResolveExpr(s *Scope, e ast.Expression) error {
 		// NOTE: The Checker will take care of this
 		return nil
 	case *ast.AssignmentExpression:
+		// NOTE: Indexing a slice changes the elements and not the variable, the typechecker knows if it is a slice
+		if variable, ok := assignedVariable(e.Lhs); ok {
+			if v, ok := s.Get(variable.Identifier); ok && v.Local && !v.Mutable {
+				e.Immutable, e.Declaration = true, v.Declaration
+			}
+		}
+
 		err := VarResolveExpr(s, e.Lhs)
 		if err != nil {
 			return err
@@ -118,6 +421,103 @@ func VarResolveExpr(s *Scope, e ast.Expression) error {
 			return err
 		}
 
+	case *ast.UnaryExpression:
+		return VarResolveExpr(s, e.Operand)
+	case *ast.CastExpression:
+		t, err := s.resolveType(e.Token, e.Type)
+		if err != nil {
+			return err
+		}
+		e.Type = t
+		return VarResolveExpr(s, e.Expression)
+	case *ast.FieldAccessExpression:
+		return VarResolveExpr(s, e.Expression)
+	case *ast.AddressOfExpression:
+		// NOTE: The variable could be changed through the pointer, so it has to be mutable like for an assignment
+		if variable, ok := assignedVariable(e.Expression); ok {
+			if v, ok := s.Get(variable.Identifier); ok && v.Local && !v.Mutable {
+				e.Immutable, e.Declaration = true, v.Declaration
+			}
+		}
+		return VarResolveExpr(s, e.Expression)
+	case *ast.DereferenceExpression:
+		return VarResolveExpr(s, e.Expression)
+	case *ast.StructExpression:
+		name, err := s.resolveType(e.Token, ast.Type(e.Name))
+		if err != nil {
+			return err
+		}
+		e.Name = string(name)
+		for _, field := range e.Fields {
+			err := VarResolveExpr(s, field.Value)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.EnumExpression:
+		name, err := s.resolveType(e.Token, ast.Type(e.Enum))
+		if err != nil {
+			return err
+		}
+		e.Enum = string(name)
+		for _, arg := range e.Arguments {
+			err := VarResolveExpr(s, arg)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.MatchExpression:
+		err := VarResolveExpr(s, e.Expression)
+		if err != nil {
+			return err
+		}
+
+		for i, arm := range e.Arms {
+			armS := copyScope(s)
+			for j, binding := range arm.Bindings {
+				if binding == "_" {
+					continue
+				}
+				if armS.HasInCurrent(binding) {
+					return errorf(arm.Token, "variable %q redefined", binding)
+				}
+				e.Arms[i].Bindings[j] = armS.SetUniq(binding, arm.Token, false)
+			}
+
+			err := VarResolveExpr(&armS, arm.Body)
+			if err != nil {
+				return err
+			}
+			// The bindings of different arms can have different types, so they need different names
+			s.UniqueId = armS.UniqueId
+		}
+	case *ast.ArrayExpression:
+		for _, element := range e.Elements {
+			err := VarResolveExpr(s, element)
+			if err != nil {
+				return err
+			}
+		}
+	case *ast.IndexExpression:
+		err := VarResolveExpr(s, e.Expression)
+		if err != nil {
+			return err
+		}
+		return VarResolveExpr(s, e.Index)
+	case *ast.SliceExpression:
+		err := VarResolveExpr(s, e.Expression)
+		if err != nil {
+			return err
+		}
+		if e.Start != nil {
+			err = VarResolveExpr(s, e.Start)
+			if err != nil {
+				return err
+			}
+		}
+		if e.End != nil {
+			return VarResolveExpr(s, e.End)
+		}
 	case *ast.BinaryExpression:
 		err := VarResolveExpr(s, e.Lhs)
 		if err != nil {
@@ -136,6 +536,8 @@ func VarResolveExpr(s *Scope, e ast.Expression) error {
 		if e.ReturnExpression != nil {
 			errs = append(errs, VarResolveExpr(&newS, e.ReturnExpression))
 		}
+		// Sibling blocks can declare variables of different types, so they need different names
+		s.UniqueId = newS.UniqueId
 
 		return errors.Join(errs...)
 	case *ast.IfExpression:
@@ -149,6 +551,7 @@ func VarResolveExpr(s *Scope, e ast.Expression) error {
 		if err != nil {
 			return err
 		}
+		s.UniqueId = thenS.UniqueId
 
 		elseS := copyScope(s)
 		if e.Else != nil {
@@ -157,30 +560,145 @@ func VarResolveExpr(s *Scope, e ast.Expression) error {
 				return err
 			}
 		}
+		s.UniqueId = elseS.UniqueId
+	case *ast.WhileExpression:
+		err := VarResolveExpr(s, e.Condition)
+		if err != nil {
+			return err
+		}
+
+		bodyS := copyScope(s)
+		bodyS.InLoop = true
+		err = VarResolveExpr(&bodyS, e.Body)
+		if err != nil {
+			return err
+		}
+		s.UniqueId = bodyS.UniqueId
+
+		if e.Else != nil {
+			elseS := copyScope(s)
+			err = VarResolveExpr(&elseS, e.Else)
+			if err != nil {
+				return err
+			}
+			s.UniqueId = elseS.UniqueId
+		}
+	case *ast.ForExpression:
+		err := VarResolveExpr(s, e.Start)
+		if err != nil {
+			return err
+		}
+		err = VarResolveExpr(s, e.End)
+		if err != nil {
+			return err
+		}
+
+		bodyS := copyScope(s)
+		bodyS.InLoop = true
+		e.Identifier = bodyS.SetUniq(e.Identifier, e.Token, false)
+		err = VarResolveExpr(&bodyS, e.Body)
+		if err != nil {
+			return err
+		}
+		s.UniqueId = bodyS.UniqueId
+	case *ast.BreakExpression:
+		if !s.InLoop {
+			return errorf(e.Token, "break outside of a loop")
+		}
+
+		if e.Value != nil {
+			return VarResolveExpr(s, e.Value)
+		}
+	case *ast.ContinueExpression:
+		if !s.InLoop {
+			return errorf(e.Token, "continue outside of a loop")
+		}
+	case *ast.ReturnExpression:
+		if e.Value != nil {
+			return VarResolveExpr(s, e.Value)
+		}
 	case *ast.VariableDeclaration:
 		if s.HasInCurrent(e.Identifier) {
 			return errorf(e.Token, "variable %q redefined", e.Identifier)
 		}
 
-		e.Identifier = s.SetUniq(e.Identifier)
+		t, err := s.resolveType(e.Token, e.Type)
+		if err != nil {
+			return err
+		}
+		e.Type = t
+
+		// NOTE: Resolve the initializer before the variable exists, it can not reference itself
+		err = VarResolveExpr(s, e.InitializingExpression)
+		if err != nil {
+			return err
+		}
+
+		e.Identifier = s.SetUniq(e.Identifier, e.Token, e.Mutable)
 	case *ast.VariableReference:
-		v, ok := s.Get(e.Identifier)
-		if !ok {
-			return errorf(e.Token, "variable %q is not declared", e.Identifier)
+		v, err := s.use(e.Token, e.Identifier)
+		if err != nil {
+			return err
 		}
 
 		e.Identifier = v.Name
 	case *ast.BooleanExpression:
 	case *ast.IntegerExpression:
+	case *ast.FloatExpression:
+	case *ast.StringExpression:
 	case *ast.FunctionCall:
-		newName, ok := s.Get(e.Identifier)
-		if !ok {
+		var newName Var
+		if s.Has(e.Identifier) {
+			v, err := s.use(e.Token, e.Identifier)
+			if err != nil {
+				return err
+			}
+			newName = v
+		} else if builtins[e.Identifier] {
+			newName = Var{Name: e.Identifier}
+		} else {
 			return errorf(e.Token, "function %q not found", e.Identifier)
 		}
 		for _, arg := range e.Arguments {
-			VarResolveExpr(s, arg)
+			if err := VarResolveExpr(s, arg); err != nil {
+				return err
+			}
 		}
 		e.Identifier = newName.Name
+	case *ast.CallExpression:
+		err := VarResolveExpr(s, e.Function)
+		if err != nil {
+			return err
+		}
+		for _, arg := range e.Arguments {
+			if err := VarResolveExpr(s, arg); err != nil {
+				return err
+			}
+		}
+	case *ast.ClosureExpression:
+		// The closure is a function of its own, loops around it can not be left from inside of it
+		closureS := copyScope(s)
+		closureS.InLoop = false
+		closureS.Closures = append(slices.Clip(s.Closures), e)
+		for i, param := range e.Parameters {
+			t, err := closureS.resolveType(e.Token, param.Type)
+			if err != nil {
+				return err
+			}
+			e.Parameters[i] = ast.Parameter{Token: param.Token, Name: closureS.SetUniq(param.Name, param.Token, false), Type: t}
+		}
+		t, err := closureS.resolveType(e.Token, e.ReturnType)
+		if err != nil {
+			return err
+		}
+		e.ReturnType = t
+
+		err = VarResolveExpr(&closureS, e.Body)
+		if err != nil {
+			return err
+		}
+		// The variables of the closure and the function are in the same function, so they need different names
+		s.UniqueId = closureS.UniqueId
 	default:
 		panic(fmt.Sprintf("unexpected ast.Expression: %#v", e))
 	}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"strings"
 
 	"robaertschi.xyz/robaertschi/tt/ast"
@@ -10,31 +11,84 @@ type Type interface {
 	// Checks if the two types are the same
 	IsSameType(Type) bool
 	SupportsBinaryOperator(op ast.BinaryOperator) bool
+	SupportsUnaryOperator(op ast.UnaryOperator) bool
 	Name() string
+	// The size in bytes
+	Size() int64
+	// The alignment in bytes
+	Alignment() int64
 }
 
 type TypeId struct {
 	id   int64
 	name string
+	size int64
+	// Only used by integer types
+	integer bool
+	signed  bool
+	// Only used by floating point types
+	float bool
 }
 
 const (
 	UnitId int64 = iota
 	I64Id
 	BoolId
+	I8Id
+	I16Id
+	I32Id
+	U8Id
+	U16Id
+	U32Id
+	U64Id
+	NeverId
+	F64Id
 )
 
 var (
-	Unit = New(UnitId, "()")
-	I64  = New(I64Id, "i64")
-	Bool = New(BoolId, "bool")
+	Unit = New(UnitId, "()", 0)
+	I64  = NewInteger(I64Id, "i64", 8, true)
+	Bool = New(BoolId, "bool", 1)
+	I8   = NewInteger(I8Id, "i8", 1, true)
+	I16  = NewInteger(I16Id, "i16", 2, true)
+	I32  = NewInteger(I32Id, "i32", 4, true)
+	U8   = NewInteger(U8Id, "u8", 1, false)
+	U16  = NewInteger(U16Id, "u16", 2, false)
+	U32  = NewInteger(U32Id, "u32", 4, false)
+	U64  = NewInteger(U64Id, "u64", 8, false)
+	// The type of expressions, which never produce a value, like return
+	Never = New(NeverId, "!", 0)
+	F64   = NewFloat(F64Id, "f64", 8)
 )
 
 func (ti *TypeId) SupportsBinaryOperator(op ast.BinaryOperator) bool {
-	if ti == Bool && !op.IsBooleanOperator() {
-		return false
+	if ti.integer {
+		return !op.IsLogicalOperator()
 	}
-	return true
+	if ti.float {
+		switch op {
+		case ast.Add, ast.Subtract, ast.Multiply, ast.Divide:
+			return true
+		}
+		return op.IsBooleanOperator() && !op.IsLogicalOperator()
+	}
+	if ti == Bool {
+		return op == ast.Equal || op == ast.NotEqual || op.IsLogicalOperator()
+	}
+	return false
+}
+
+func (ti *TypeId) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	if ti.integer {
+		return (op == ast.Negate && ti.signed) || op == ast.Complement
+	}
+	if ti.float {
+		return op == ast.Negate
+	}
+	if ti == Bool {
+		return op == ast.Not
+	}
+	return false
 }
 
 func (ti *TypeId) IsSameType(t Type) bool {
@@ -49,6 +103,40 @@ func (ti *TypeId) Name() string {
 	return ti.name
 }
 
+func (ti *TypeId) Size() int64 {
+	return ti.size
+}
+
+func (ti *TypeId) Alignment() int64 {
+	return max(ti.size, 1)
+}
+
+func (ti *TypeId) IsInteger() bool {
+	return ti.integer
+}
+
+func (ti *TypeId) IsSigned() bool {
+	return ti.signed
+}
+
+// Checks if t is any of the integer types
+func IsInteger(t Type) bool {
+	ti, ok := t.(*TypeId)
+	return ok && ti.integer
+}
+
+// Checks if t is a signed integer type
+func IsSigned(t Type) bool {
+	ti, ok := t.(*TypeId)
+	return ok && ti.signed
+}
+
+// Checks if t is a floating point type
+func IsFloat(t Type) bool {
+	ti, ok := t.(*TypeId)
+	return ok && ti.float
+}
+
 type FunctionType struct {
 	ReturnType Type
 	Parameters []Type
@@ -58,6 +146,10 @@ func (ft *FunctionType) SupportsBinaryOperator(op ast.BinaryOperator) bool {
 	return false
 }
 
+func (ft *FunctionType) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	return false
+}
+
 func (ft *FunctionType) IsSameType(t Type) bool {
 	if ft2, ok := t.(*FunctionType); ok {
 		if !ft.ReturnType.IsSameType(ft2.ReturnType) {
@@ -73,10 +165,26 @@ func (ft *FunctionType) IsSameType(t Type) bool {
 				return false
 			}
 		}
+		return true
 	}
 	return false
 }
 
+// The offsets of the parts of a function value, the address of the code and the address of the environment of a closure.
+// Functions, which are not closures, have no environment, it is zero.
+const (
+	FunctionCodeOffset        int64 = 0
+	FunctionEnvironmentOffset int64 = 8
+)
+
+func (ft *FunctionType) Size() int64 {
+	return 16
+}
+
+func (ft *FunctionType) Alignment() int64 {
+	return 8
+}
+
 func (ft *FunctionType) Name() string {
 	b := strings.Builder{}
 
@@ -94,10 +202,343 @@ func (ft *FunctionType) Name() string {
 	return b.String()
 }
 
+type StructField struct {
+	Name string
+	Type Type
+	// The position of the field from the start of the struct in bytes
+	Offset int64
+}
+
+type StructType struct {
+	name      string
+	Fields    []StructField
+	size      int64
+	alignment int64
+}
+
+// Creates a new struct and calculates the layout the same way C does,
+// every field is aligned to its alignment and the size is padded to the largest alignment
+func NewStruct(name string, fields []StructField) *StructType {
+	st := &StructType{name: name}
+	st.SetFields(fields)
+	return st
+}
+
+// Sets the fields and calculates the layout again. This allows structs to be created,
+// before their fields are known, so that they can contain pointers to themselves.
+func (st *StructType) SetFields(fields []StructField) {
+	st.Fields = fields
+	st.alignment = 1
+
+	offset := int64(0)
+	for i, field := range st.Fields {
+		alignment := field.Type.Alignment()
+		offset = alignTo(offset, alignment)
+		st.Fields[i].Offset = offset
+		offset += field.Type.Size()
+		st.alignment = max(st.alignment, alignment)
+	}
+	st.size = alignTo(offset, st.alignment)
+}
+
+func alignTo(value int64, alignment int64) int64 {
+	return (value + alignment - 1) / alignment * alignment
+}
+
+func (st *StructType) SupportsBinaryOperator(op ast.BinaryOperator) bool {
+	return false
+}
+
+func (st *StructType) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	return false
+}
+
+func (st *StructType) IsSameType(t Type) bool {
+	return st == t
+}
+
+func (st *StructType) Name() string {
+	return st.name
+}
+
+func (st *StructType) Size() int64 {
+	return st.size
+}
+
+func (st *StructType) Alignment() int64 {
+	return st.alignment
+}
+
+func (st *StructType) Field(name string) (StructField, bool) {
+	for _, field := range st.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return StructField{}, false
+}
+
+type EnumVariant struct {
+	Name string
+	// The number, which is stored in the tag of a value of this variant
+	Tag int64
+	// The types of the payload, it is stored after the tag
+	Fields []Type
+	// The position of each payload value from the start of the enum in bytes
+	Offsets []int64
+}
+
+// An enum is a tag, which is the number of the variant, followed by the payload of the variant.
+// The payloads of all variants share the same memory.
+type EnumType struct {
+	name      string
+	Variants  []EnumVariant
+	size      int64
+	alignment int64
+}
+
+// The tag is always an i64 at the start of the enum
+const EnumTagOffset int64 = 0
+
+func NewEnum(name string, variants []EnumVariant) *EnumType {
+	et := &EnumType{name: name}
+	et.SetVariants(variants)
+	return et
+}
+
+// Sets the variants and calculates the layout, the payload of every variant is laid out like a struct after the tag
+func (et *EnumType) SetVariants(variants []EnumVariant) {
+	et.Variants = variants
+	et.alignment = I64.Alignment()
+
+	size := I64.Size()
+	for i, variant := range et.Variants {
+		et.Variants[i].Tag = int64(i)
+		et.Variants[i].Offsets = []int64{}
+
+		offset := I64.Size()
+		for _, field := range variant.Fields {
+			offset = alignTo(offset, field.Alignment())
+			et.Variants[i].Offsets = append(et.Variants[i].Offsets, offset)
+			offset += field.Size()
+			et.alignment = max(et.alignment, field.Alignment())
+		}
+		size = max(size, offset)
+	}
+	et.size = alignTo(size, et.alignment)
+}
+
+func (et *EnumType) SupportsBinaryOperator(op ast.BinaryOperator) bool {
+	return false
+}
+
+func (et *EnumType) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	return false
+}
+
+func (et *EnumType) IsSameType(t Type) bool {
+	return et == t
+}
+
+func (et *EnumType) Name() string {
+	return et.name
+}
+
+func (et *EnumType) Size() int64 {
+	return et.size
+}
+
+func (et *EnumType) Alignment() int64 {
+	return et.alignment
+}
+
+func (et *EnumType) Variant(name string) (EnumVariant, bool) {
+	for _, variant := range et.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return EnumVariant{}, false
+}
+
+type ArrayType struct {
+	Element Type
+	Length  int64
+}
+
+func NewArray(element Type, length int64) *ArrayType {
+	return &ArrayType{Element: element, Length: length}
+}
+
+func (at *ArrayType) SupportsBinaryOperator(op ast.BinaryOperator) bool {
+	return false
+}
+
+func (at *ArrayType) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	return false
+}
+
+func (at *ArrayType) IsSameType(t Type) bool {
+	other, ok := t.(*ArrayType)
+	return ok && at.Length == other.Length && at.Element.IsSameType(other.Element)
+}
+
+func (at *ArrayType) Name() string {
+	return fmt.Sprintf("[%d]%s", at.Length, at.Element.Name())
+}
+
+func (at *ArrayType) Size() int64 {
+	return at.Length * at.Element.Size()
+}
+
+func (at *ArrayType) Alignment() int64 {
+	return at.Element.Alignment()
+}
+
+type PointerType struct {
+	Pointee Type
+}
+
+func NewPointer(pointee Type) *PointerType {
+	return &PointerType{Pointee: pointee}
+}
+
+// Pointers can only be compared, there is no pointer arithmetic
+func (pt *PointerType) SupportsBinaryOperator(op ast.BinaryOperator) bool {
+	return op == ast.Equal || op == ast.NotEqual
+}
+
+func (pt *PointerType) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	return false
+}
+
+func (pt *PointerType) IsSameType(t Type) bool {
+	other, ok := t.(*PointerType)
+	return ok && pt.Pointee.IsSameType(other.Pointee)
+}
+
+func (pt *PointerType) Name() string {
+	return "*" + pt.Pointee.Name()
+}
+
+func (pt *PointerType) Size() int64 {
+	return 8
+}
+
+func (pt *PointerType) Alignment() int64 {
+	return 8
+}
+
+// The offsets of the fields of a slice or a string, they are laid out like a struct with a pointer to the first element and the length
+const (
+	SlicePointerOffset int64 = 0
+	SliceLengthOffset  int64 = 8
+)
+
+type SliceType struct {
+	Element Type
+}
+
+func NewSlice(element Type) *SliceType {
+	return &SliceType{Element: element}
+}
+
+func (st *SliceType) SupportsBinaryOperator(op ast.BinaryOperator) bool {
+	return false
+}
+
+func (st *SliceType) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	return false
+}
+
+func (st *SliceType) IsSameType(t Type) bool {
+	other, ok := t.(*SliceType)
+	return ok && st.Element.IsSameType(other.Element)
+}
+
+func (st *SliceType) Name() string {
+	return "[]" + st.Element.Name()
+}
+
+func (st *SliceType) Size() int64 {
+	return 16
+}
+
+func (st *SliceType) Alignment() int64 {
+	return 8
+}
+
+// A string is a read-only slice of bytes, which are UTF-8 encoded
+type StringType struct{}
+
+var Str = register(&StringType{})
+
+func (st *StringType) SupportsBinaryOperator(op ast.BinaryOperator) bool {
+	return false
+}
+
+func (st *StringType) SupportsUnaryOperator(op ast.UnaryOperator) bool {
+	return false
+}
+
+func (st *StringType) IsSameType(t Type) bool {
+	_, ok := t.(*StringType)
+	return ok
+}
+
+func (st *StringType) Name() string {
+	return "str"
+}
+
+func (st *StringType) Size() int64 {
+	return 16
+}
+
+func (st *StringType) Alignment() int64 {
+	return 8
+}
+
+// Returns the common type of two branches, a branch that never produces a value takes the type of the other one
+func Unify(a, b Type) (Type, bool) {
+	if a.IsSameType(Never) {
+		return b, true
+	}
+	if b.IsSameType(Never) || a.IsSameType(b) {
+		return a, true
+	}
+	return nil, false
+}
+
+// Aggregates are stored in memory and copied as a whole
+func IsAggregate(t Type) bool {
+	switch t.(type) {
+	case *StructType, *EnumType, *ArrayType, *SliceType, *StringType, *FunctionType:
+		return true
+	}
+	return false
+}
+
 var types map[string]Type = make(map[string]Type)
 
-func New(id int64, name string) Type {
-	typeId := &TypeId{id: id, name: name}
+func register(t Type) Type {
+	types[t.Name()] = t
+	return t
+}
+
+func New(id int64, name string, size int64) Type {
+	typeId := &TypeId{id: id, name: name, size: size}
+	types[name] = typeId
+	return typeId
+}
+
+func NewInteger(id int64, name string, size int64, signed bool) Type {
+	typeId := &TypeId{id: id, name: name, size: size, integer: true, signed: signed}
+	types[name] = typeId
+	return typeId
+}
+
+func NewFloat(id int64, name string, size int64) Type {
+	typeId := &TypeId{id: id, name: name, size: size, float: true}
 	types[name] = typeId
 	return typeId
 }